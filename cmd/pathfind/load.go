@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/legamerdc/pathfinding/groute"
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/tiled"
+)
+
+// loadMap reads a map file. format "auto" picks the decoder by extension:
+// .txt and .map are the grid text format, .png is an image mask, .grid the
+// binary form written by grid.Local.WriteTo and .tmx / .tmj Tiled maps.
+// opt applies to images. A text map with S and G marks also returns the
+// query between them. Blocks absent from a .grid file are loaded empty.
+func loadMap(name, format string, kind groute.Kind, opt grid.ImageOptions) (*grid.Local, []query, error) {
+	if format == "auto" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".txt", ".map":
+			format = "ascii"
+		case ".png":
+			format = "png"
+		case ".grid":
+			format = "grid"
+		case ".tmx", ".tmj":
+			format = "tiled"
+		default:
+			return nil, nil, fmt.Errorf("%s: cannot guess format, use -format", name)
+		}
+	}
+	if format == "tiled" {
+		tm, err := tiled.LoadFile(name, tiled.Options{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if tm.Hex != (kind == groute.Hex) {
+			return nil, nil, fmt.Errorf("%s: map orientation does not match -kind %s", name, kind)
+		}
+		return tm.Blocked, nil, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	switch format {
+	case "grid":
+		m, err := grid.ReadLocal(bufio.NewReader(f))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for i := int32(0); i < m.Nx; i++ {
+			for j := int32(0); j < m.Ny; j++ {
+				if m.GetGrid(i, j) == nil {
+					m.SetGrid(i, j, new(grid.Grid))
+				}
+			}
+		}
+		return m, nil, nil
+	case "ascii":
+		tm, err := grid.ParseText(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		var marked []query
+		if tm.HasStart && tm.HasGoal {
+			marked = append(marked, query{
+				sx: float64(tm.Start.X), sy: float64(tm.Start.Y),
+				ex: float64(tm.Goal.X), ey: float64(tm.Goal.Y),
+			})
+		}
+		return tm.Map, marked, nil
+	case "png":
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		m, _, _ := grid.FromImage(img, opt)
+		return m, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unknown format %q", format)
+}
@@ -0,0 +1,337 @@
+// Command pathfind runs path queries against a map file and prints the
+// results as JSON.
+//
+// Examples:
+//
+//	pathfind -map level.txt -from 0,0 -to 40,12
+//	pathfind -map level.png -kind hex -queries queries.txt -render routes.svg
+//	pathfind -map level.grid -natural -cost octile -from 0.5,0.5 -to 30.5,2.5
+//
+// Without -from/-to or -queries, the S and G marks of a text map are used.
+// A query file holds one query per line as "sx sy ex ey", separated by
+// spaces or commas. Empty lines and lines starting with '#' are ignored.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/legamerdc/pathfinding/groute"
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/hex"
+	"github.com/legamerdc/pathfinding/groute/jps"
+	"github.com/legamerdc/pathfinding/groute/sq"
+)
+
+type options struct {
+	mapFile   string
+	format    string
+	threshold uint
+	scale     int
+	kind      string
+	from, to  string
+	queries   string
+	natural   bool
+	cost      string
+	queue     string
+	nodes     int
+	render    string
+	cell      float64
+}
+
+type query struct {
+	sx, sy, ex, ey float64
+}
+
+type result struct {
+	From     [2]float64   `json:"from"`
+	To       [2]float64   `json:"to"`
+	Found    bool         `json:"found"`
+	Path     [][2]float64 `json:"path,omitempty"`
+	Cost     float64      `json:"cost"`
+	Expanded int          `json:"expanded"`
+	Micros   float64      `json:"time_us"`
+}
+
+type stats struct {
+	Queries      int     `json:"queries"`
+	Found        int     `json:"found"`
+	TotalMicros  float64 `json:"total_time_us"`
+	MeanMicros   float64 `json:"mean_time_us"`
+	MaxMicros    float64 `json:"max_time_us"`
+	MeanCost     float64 `json:"mean_cost"`
+	MeanExpanded float64 `json:"mean_expanded"`
+}
+
+type report struct {
+	Map struct {
+		File   string `json:"file"`
+		Kind   string `json:"kind"`
+		Width  int32  `json:"width"`
+		Height int32  `json:"height"`
+	} `json:"map"`
+	Results []result `json:"results"`
+	Stats   stats    `json:"stats"`
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.mapFile, "map", "", "map file (required)")
+	flag.StringVar(&o.format, "format", "auto", "map format: auto, ascii, png, grid or tiled")
+	flag.UintVar(&o.threshold, "threshold", 128, "png: gray level below which a pixel is blocked")
+	flag.IntVar(&o.scale, "scale", 1, "png: pixels per cell side")
+	flag.StringVar(&o.kind, "kind", "sq", "grid kind: sq or hex")
+	flag.StringVar(&o.from, "from", "", "start point x,y of a single query")
+	flag.StringVar(&o.to, "to", "", "end point x,y of a single query")
+	flag.StringVar(&o.queries, "queries", "", "file with one query per line: sx sy ex ey")
+	flag.BoolVar(&o.natural, "natural", false, "sq: return continuous paths from SolveNatural")
+	flag.StringVar(&o.cost, "cost", "approx", "sq cost model: approx, octile or octile-euclid")
+	flag.StringVar(&o.queue, "queue", "heap", "open list: heap, quad or bucket")
+	flag.IntVar(&o.nodes, "nodes", 0, "search node capacity, 0 means one per cell")
+	flag.StringVar(&o.render, "render", "", "write a .png or .svg picture of the map and paths")
+	flag.Float64Var(&o.cell, "cell", 12, "render: cell size in pixels")
+	flag.Parse()
+
+	if err := run(o, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "pathfind:", err)
+		os.Exit(1)
+	}
+}
+
+func run(o options, out io.Writer) error {
+	if o.mapFile == "" {
+		return errors.New("-map is required")
+	}
+	kind, ok := groute.ParseKind(o.kind)
+	if !ok {
+		return fmt.Errorf("unknown kind %q", o.kind)
+	}
+	if o.natural && kind != groute.Square {
+		return errors.New("-natural is only supported for sq maps")
+	}
+	if o.threshold == 0 || o.threshold > 255 {
+		return errors.New("-threshold must be in 1..255")
+	}
+	opt := grid.ImageOptions{Scale: o.scale, Threshold: uint8(o.threshold)}
+	m, marked, err := loadMap(o.mapFile, o.format, kind, opt)
+	if err != nil {
+		return err
+	}
+	qs, err := loadQueries(o, marked)
+	if err != nil {
+		return err
+	}
+	p, err := newPlanner(o, kind, m)
+	if err != nil {
+		return err
+	}
+
+	var rep report
+	rep.Map.File = o.mapFile
+	rep.Map.Kind = kind.String()
+	rep.Map.Width, rep.Map.Height = m.Nx*16, m.Ny*16
+	rep.Results = make([]result, 0, len(qs))
+	for _, q := range qs {
+		rep.Results = append(rep.Results, p.run(q))
+	}
+	rep.Stats = summarize(rep.Results)
+
+	if o.render != "" {
+		if err = render(o.render, kind, m, rep.Results, o.cell, o.natural); err != nil {
+			return err
+		}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// counter is a jps.Tracer that only counts expanded nodes.
+type counter struct {
+	closed int
+}
+
+func (c *counter) Opened(_, _, _, _, _, _ int32)      {}
+func (c *counter) Closed(_, _, _ int32)               { c.closed++ }
+func (c *counter) Jump(jps.JumpScan)                  {}
+func (c *counter) Forced(_, _, _ int32, _ jps.DirSet) {}
+
+type planner struct {
+	natural bool
+	sq      *sq.WorkSpace
+	hex     *hex.WorkSpace
+	count   counter
+}
+
+func newPlanner(o options, kind groute.Kind, m *grid.Local) (*planner, error) {
+	size := o.nodes
+	if size <= 0 {
+		size = int(m.Nx*16) * int(m.Ny*16)
+	}
+	var queue jps.QueueKind
+	switch o.queue {
+	case "heap":
+		queue = jps.QueueHeap
+	case "quad":
+		queue = jps.QueueQuadHeap
+	case "bucket":
+		queue = jps.QueueBucket
+	default:
+		return nil, fmt.Errorf("unknown queue %q", o.queue)
+	}
+
+	p := &planner{natural: o.natural}
+	if kind == groute.Hex {
+		p.hex = hex.NewWorkSpace(size)
+		p.hex.Reset(m)
+		p.hex.SetQueue(queue)
+		p.hex.SetTracer(&p.count)
+		return p, nil
+	}
+
+	var cost sq.CostModel
+	switch o.cost {
+	case "approx":
+		cost = sq.CostApprox
+	case "octile":
+		cost = sq.CostOctile
+	case "octile-euclid":
+		cost = sq.CostOctileEuclidH
+	default:
+		return nil, fmt.Errorf("unknown cost model %q", o.cost)
+	}
+	p.sq = sq.NewWorkSpace(size)
+	p.sq.Reset(m)
+	p.sq.SetQueue(queue)
+	p.sq.SetCostModel(cost)
+	p.sq.SetTracer(&p.count)
+	return p, nil
+}
+
+func (p *planner) run(q query) result {
+	r := result{From: [2]float64{q.sx, q.sy}, To: [2]float64{q.ex, q.ey}}
+	p.count.closed = 0
+	start := time.Now()
+	switch {
+	case p.natural:
+		path, ok := p.sq.SolveNatural(q.sx, q.sy, q.ex, q.ey)
+		r.Found = ok
+		for i, pt := range path {
+			r.Path = append(r.Path, [2]float64{pt.X, pt.Y})
+			if i > 0 {
+				r.Cost += math.Hypot(pt.X-path[i-1].X, pt.Y-path[i-1].Y)
+			}
+		}
+	default:
+		sx, sy := int32(math.Floor(q.sx)), int32(math.Floor(q.sy))
+		ex, ey := int32(math.Floor(q.ex)), int32(math.Floor(q.ey))
+		var path []grid.PathGrid
+		if p.sq != nil {
+			path, r.Found = p.sq.Solve(sx, sy, ex, ey)
+			r.Cost = p.sq.PathCost()
+		} else {
+			path, r.Found = p.hex.Solve(sx, sy, ex, ey)
+			r.Cost = p.hex.PathCost()
+		}
+		for _, pt := range path {
+			r.Path = append(r.Path, [2]float64{float64(pt.X), float64(pt.Y)})
+		}
+	}
+	r.Micros = float64(time.Since(start).Nanoseconds()) / 1e3
+	r.Expanded = p.count.closed
+	if !r.Found {
+		r.Cost = 0
+	}
+	return r
+}
+
+func summarize(rs []result) (s stats) {
+	s.Queries = len(rs)
+	var cost, expanded float64
+	for _, r := range rs {
+		s.TotalMicros += r.Micros
+		s.MaxMicros = max(s.MaxMicros, r.Micros)
+		expanded += float64(r.Expanded)
+		if r.Found {
+			s.Found++
+			cost += r.Cost
+		}
+	}
+	if s.Queries > 0 {
+		s.MeanMicros = s.TotalMicros / float64(s.Queries)
+		s.MeanExpanded = expanded / float64(s.Queries)
+	}
+	if s.Found > 0 {
+		s.MeanCost = cost / float64(s.Found)
+	}
+	return s
+}
+
+// loadQueries collects the queries given by flags, falling back to the
+// queries marked in the map.
+func loadQueries(o options, marked []query) ([]query, error) {
+	var qs []query
+	if o.from != "" || o.to != "" {
+		q, err := parseQuery(o.from + "," + o.to)
+		if err != nil {
+			return nil, fmt.Errorf("-from/-to: %w", err)
+		}
+		qs = append(qs, q)
+	}
+	if o.queries != "" {
+		f, err := os.Open(o.queries)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for line := 1; sc.Scan(); line++ {
+			text := strings.TrimSpace(sc.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			q, err := parseQuery(text)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", o.queries, line, err)
+			}
+			qs = append(qs, q)
+		}
+		if err = sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(qs) == 0 {
+		qs = marked
+	}
+	if len(qs) == 0 {
+		return nil, errors.New("no query: use -from/-to or -queries")
+	}
+	return qs, nil
+}
+
+func parseQuery(s string) (query, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(fields) != 4 {
+		return query{}, fmt.Errorf("want 4 coordinates, got %q", s)
+	}
+	var v [4]float64
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return query{}, err
+		}
+		v[i] = x
+	}
+	return query{sx: v[0], sy: v[1], ex: v[2], ey: v[3]}, nil
+}
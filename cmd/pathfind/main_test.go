@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute"
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want query
+		ok   bool
+	}{
+		{"1 2 3 4", query{1, 2, 3, 4}, true},
+		{"1,2,3,4", query{1, 2, 3, 4}, true},
+		{" 0.5,\t1.5  2.5 , 3.5 ", query{0.5, 1.5, 2.5, 3.5}, true},
+		{"-1 0 0 -2", query{-1, 0, 0, -2}, true},
+		{"1 2 3", query{}, false},
+		{"1 2 3 4 5", query{}, false},
+		{"1 2 x 4", query{}, false},
+		{"", query{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseQuery(tt.in)
+		if !tt.ok {
+			assert.Error(t, err, "%q", tt.in)
+			continue
+		}
+		require.NoError(t, err, "%q", tt.in)
+		assert.Equal(t, tt.want, got, "%q", tt.in)
+	}
+}
+
+func TestLoadQueries(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.txt")
+	require.NoError(t, os.WriteFile(bad, []byte("# ok\n1 2 3 4\n1 2 3\n"), 0o644))
+	marked := []query{{9, 9, 8, 8}}
+
+	tests := []struct {
+		name   string
+		o      options
+		marked []query
+		want   []query
+		err    string
+	}{
+		{"from/to", options{from: "1,2", to: "3,4"}, marked, []query{{1, 2, 3, 4}}, ""},
+		{"marked", options{}, marked, marked, ""},
+		{"file", options{queries: "testdata/queries.txt"}, marked, []query{
+			{0, 0, 13, 6}, {0, 6, 15, 0}, {3, 3, 3, 3}, {0, 0, 7, 3}, {0, 0, 6, 3},
+		}, ""},
+		{"flags and file", options{from: "5 5", to: "6 6", queries: "testdata/queries.txt"}, nil, []query{
+			{5, 5, 6, 6}, {0, 0, 13, 6}, {0, 6, 15, 0}, {3, 3, 3, 3}, {0, 0, 7, 3}, {0, 0, 6, 3},
+		}, ""},
+		{"half query", options{from: "1,2"}, nil, nil, "-from/-to"},
+		{"bad line", options{queries: bad}, nil, nil, bad + ":3"},
+		{"missing file", options{queries: filepath.Join(dir, "none.txt")}, nil, nil, "none.txt"},
+		{"none", options{}, nil, nil, "no query"},
+	}
+	for _, tt := range tests {
+		got, err := loadQueries(tt.o, tt.marked)
+		if tt.err != "" {
+			assert.ErrorContains(t, err, tt.err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+// 二进制地图中缺失的块按空块加载
+func TestLoadMap_SparseGrid(t *testing.T) {
+	m := grid.NewLocal(2, 1)
+	m.SetGrid(0, 0, new(grid.Grid))
+	m.Set(3, 0)
+	name := filepath.Join(t.TempDir(), "sparse.grid")
+	f, err := os.Create(name)
+	require.NoError(t, err)
+	_, err = m.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	got, _, err := loadMap(name, "auto", groute.Square, grid.ImageOptions{})
+	require.NoError(t, err)
+	assert.False(t, got.Available(3, 0))
+	assert.True(t, got.Available(20, 5))
+
+	var out bytes.Buffer
+	o := options{mapFile: name, format: "auto", threshold: 128, kind: "sq",
+		from: "0,0", to: "31,15", cost: "approx", queue: "heap"}
+	require.NoError(t, run(o, &out))
+	var rep report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &rep))
+	assert.True(t, rep.Results[0].Found)
+}
+
+// 固定输入下的完整输出与 testdata 中的 golden 文件比对，计时字段清零
+func TestRun_Golden(t *testing.T) {
+	queries := "testdata/queries.txt"
+	tests := []struct {
+		golden string
+		o      options
+	}{
+		{"maze_sq.golden", options{kind: "sq", cost: "approx", queue: "heap", queries: queries}},
+		{"maze_octile.golden", options{kind: "sq", cost: "octile", queue: "quad", queries: queries}},
+		{"maze_natural.golden", options{kind: "sq", cost: "approx", queue: "heap", natural: true,
+			queries: "testdata/natural.txt"}},
+		{"maze_hex.golden", options{kind: "hex", queue: "bucket", queries: queries}},
+	}
+	for _, tt := range tests {
+		o := tt.o
+		o.mapFile, o.format, o.threshold = "testdata/maze.txt", "auto", 128
+		var out bytes.Buffer
+		require.NoError(t, run(o, &out), tt.golden)
+
+		var rep report
+		require.NoError(t, json.Unmarshal(out.Bytes(), &rep))
+		for i := range rep.Results {
+			rep.Results[i].Micros = 0
+		}
+		rep.Stats.TotalMicros, rep.Stats.MeanMicros, rep.Stats.MaxMicros = 0, 0, 0
+		got, err := json.MarshalIndent(rep, "", "  ")
+		require.NoError(t, err)
+		got = append(got, '\n')
+
+		name := filepath.Join("testdata", tt.golden)
+		if *update {
+			require.NoError(t, os.WriteFile(name, got, 0o644))
+			continue
+		}
+		want, err := os.ReadFile(name)
+		require.NoError(t, err, "run with -update to create it")
+		assert.Equal(t, string(want), string(got), tt.golden)
+	}
+}
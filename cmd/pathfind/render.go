@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/legamerdc/pathfinding/groute"
+	"github.com/legamerdc/pathfinding/groute/grid"
+)
+
+var pathColors = []color.RGBA{
+	{R: 0xe6, G: 0x19, B: 0x4b, A: 0xff},
+	{R: 0x3c, G: 0xb4, B: 0x4b, A: 0xff},
+	{R: 0x43, G: 0x63, B: 0xd8, A: 0xff},
+	{R: 0xf5, G: 0x82, B: 0x31, A: 0xff},
+	{R: 0x91, G: 0x1e, B: 0xb4, A: 0xff},
+	{R: 0x42, G: 0xd4, B: 0xf4, A: 0xff},
+}
+
+// layout maps cell and path coordinates to picture coordinates.
+type layout struct {
+	kind    groute.Kind
+	cell    float64
+	natural bool
+}
+
+// center returns the picture position of cell (x, y). Hex rows use the odd-r
+// offset layout of package hex.
+func (l layout) center(x, y int32) (float64, float64) {
+	if l.kind == groute.Hex {
+		w := math.Sqrt(3) / 2 * l.cell
+		return w / 2 * float64(2*x+(y&1)+1), l.cell * (0.75*float64(y) + 0.5)
+	}
+	return (float64(x) + 0.5) * l.cell, (float64(y) + 0.5) * l.cell
+}
+
+// point converts a path point. Natural paths are already continuous.
+func (l layout) point(p [2]float64) (float64, float64) {
+	if l.natural {
+		return p[0] * l.cell, p[1] * l.cell
+	}
+	return l.center(int32(p[0]), int32(p[1]))
+}
+
+func (l layout) size(m *grid.Local) (int, int) {
+	w, h := m.Nx*16, m.Ny*16
+	if l.kind == groute.Hex {
+		x, y := l.center(w, h-1)
+		return int(math.Ceil(x)), int(math.Ceil(y + l.cell/2))
+	}
+	return int(float64(w) * l.cell), int(float64(h) * l.cell)
+}
+
+// corners returns the outline of cell (x, y).
+func (l layout) corners(x, y int32) [][2]float64 {
+	cx, cy := l.center(x, y)
+	if l.kind == groute.Hex {
+		pts := make([][2]float64, 6)
+		for i := range pts {
+			a := math.Pi/6 + float64(i)*math.Pi/3
+			pts[i] = [2]float64{cx + l.cell/2*math.Cos(a), cy + l.cell/2*math.Sin(a)}
+		}
+		return pts
+	}
+	h := l.cell / 2
+	return [][2]float64{{cx - h, cy - h}, {cx + h, cy - h}, {cx + h, cy + h}, {cx - h, cy + h}}
+}
+
+// render draws the blocked cells and every found path to a .png or .svg file.
+// natural tells that paths hold continuous SolveNatural points.
+func render(name string, kind groute.Kind, m *grid.Local, rs []result, cell float64, natural bool) error {
+	l := layout{kind: kind, cell: cell, natural: natural}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		return renderPNG(name, l, m, rs)
+	case ".svg":
+		return renderSVG(name, l, m, rs)
+	}
+	return fmt.Errorf("%s: render supports .png and .svg", name)
+}
+
+func renderPNG(name string, l layout, m *grid.Local, rs []result) error {
+	w, h := l.size(m)
+	ctx := gg.NewContext(w, h)
+	ctx.SetColor(color.White)
+	ctx.Clear()
+	ctx.SetColor(color.Gray{Y: 0x40})
+	eachBlocked(m, func(x, y int32) {
+		for _, p := range l.corners(x, y) {
+			ctx.LineTo(p[0], p[1])
+		}
+		ctx.ClosePath()
+		ctx.Fill()
+	})
+
+	ctx.SetLineWidth(max(1, l.cell/4))
+	for i, r := range rs {
+		if !r.Found {
+			continue
+		}
+		ctx.SetColor(pathColors[i%len(pathColors)])
+		for _, p := range r.Path {
+			ctx.LineTo(l.point(p))
+		}
+		ctx.Stroke()
+		x, y := l.point(r.Path[0])
+		ctx.DrawCircle(x, y, l.cell/3)
+		ctx.Fill()
+	}
+	return ctx.SavePNG(name)
+}
+
+func renderSVG(name string, l layout, m *grid.Local, rs []result) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	wr := bufio.NewWriter(f)
+
+	w, h := l.size(m)
+	fmt.Fprintf(wr, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", w, h)
+	fmt.Fprintf(wr, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n<g fill=\"#404040\">\n")
+	eachBlocked(m, func(x, y int32) {
+		wr.WriteString("<polygon points=\"")
+		for i, p := range l.corners(x, y) {
+			if i > 0 {
+				wr.WriteByte(' ')
+			}
+			fmt.Fprintf(wr, "%.2f,%.2f", p[0], p[1])
+		}
+		wr.WriteString("\"/>\n")
+	})
+	wr.WriteString("</g>\n")
+
+	for i, r := range rs {
+		if !r.Found {
+			continue
+		}
+		c := pathColors[i%len(pathColors)]
+		fmt.Fprintf(wr, "<polyline fill=\"none\" stroke=\"#%02x%02x%02x\" stroke-width=\"%.2f\" points=\"",
+			c.R, c.G, c.B, max(1, l.cell/4))
+		for j, p := range r.Path {
+			if j > 0 {
+				wr.WriteByte(' ')
+			}
+			x, y := l.point(p)
+			fmt.Fprintf(wr, "%.2f,%.2f", x, y)
+		}
+		wr.WriteString("\"/>\n")
+	}
+	wr.WriteString("</svg>\n")
+	if err = wr.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func eachBlocked(m *grid.Local, f func(x, y int32)) {
+	for y := int32(0); y < m.Ny*16; y++ {
+		for x := int32(0); x < m.Nx*16; x++ {
+			if !m.Available(x, y) {
+				f(x, y)
+			}
+		}
+	}
+}
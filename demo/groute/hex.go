@@ -28,17 +28,10 @@ var (
 
 func hexDemo() {
 	bg := gg.NewContext(1000, 1000)
+	trace := gg.NewContext(1000, 1000)
 	ws := CreateMap()
-	for i := int32(0); i < 16*nx; i++ {
-		for j := int32(0); j < 16*ny; j++ {
-			cx, cy := center(i, j)
-			if ws.Map.Available(i, j) {
-				drawHexagon(bg, ox+cx, oy+cy, 10, colorG)
-			} else {
-				drawHexagon(bg, ox+cx, oy+cy, 10, colorB)
-			}
-		}
-	}
+	drawHexMap(bg, ws)
+	drawHexMap(trace, ws)
 	fmt.Println("start solve")
 	start := time.Now()
 	var (
@@ -51,15 +44,54 @@ func hexDemo() {
 		fmt.Println("no path: ", time.Since(start))
 	} else {
 		fmt.Println("end solve: ", time.Since(start))
-		for i := 1; i < len(path); i++ {
-			x1, y1 := center(path[i-1].X, path[i-1].Y)
-			x2, y2 := center(path[i].X, path[i].Y)
-			drawLine(bg, ox+x1, oy+y1, ox+x2, oy+y2, colorR)
+	}
+
+	rec := newTraceRecorder()
+	ws.SetTracer(rec)
+	ws.Solve(0, 0, 16*nx-1, 16*ny-1)
+	ws.SetTracer(nil)
+	drawTrace(trace, rec, hexPos)
+
+	if ok {
+		drawHexPath(bg, path)
+		drawHexPath(trace, path)
+	}
+
+	if *replayGIF {
+		anim := gg.NewContext(1000, 1000)
+		drawHexMap(anim, ws)
+		g := replay(anim, rec, hexPos, replayOpt, func(ctx *gg.Context) {
+			drawHexPath(ctx, path)
+		})
+		if err := saveGIF("hex_replay.gif", g); err != nil {
+			fmt.Println("write replay: ", err)
 		}
 	}
 
 	//drawHexagon(bg, 200, 200, 10, color.RGBA{R: 144, G: 238, B: 144, A: 128})
 	_ = bg.SavePNG("out.png")
+	_ = trace.SavePNG("hex_trace.png")
+}
+
+func drawHexMap(ctx *gg.Context, ws *hex.WorkSpace) {
+	for i := int32(0); i < 16*nx; i++ {
+		for j := int32(0); j < 16*ny; j++ {
+			cx, cy := center(i, j)
+			if ws.Map.Available(i, j) {
+				drawHexagon(ctx, ox+cx, oy+cy, 10, colorG)
+			} else {
+				drawHexagon(ctx, ox+cx, oy+cy, 10, colorB)
+			}
+		}
+	}
+}
+
+func drawHexPath(ctx *gg.Context, path []grid.PathGrid) {
+	for i := 1; i < len(path); i++ {
+		x1, y1 := hexPos(path[i-1].X, path[i-1].Y)
+		x2, y2 := hexPos(path[i].X, path[i].Y)
+		drawLine(ctx, x1, y1, x2, y2, colorR)
+	}
 }
 
 func CreateMap() *hex.WorkSpace {
@@ -90,6 +122,12 @@ func center(x, y int32) (cx, cy float64) {
 	return
 }
 
+// hexPos returns the drawing center of cell (x, y).
+func hexPos(x, y int32) (float64, float64) {
+	cx, cy := center(x, y)
+	return ox + cx, oy + cy
+}
+
 // drawHexagon draw a hexagon at pos (x, y) with size, fill it with color and draw line use color.Black
 func drawHexagon(ctx *gg.Context, x, y, size float64, fill color.Color) {
 	// Number of sides in a hexagon
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image/color"
 
 	"github.com/fogleman/gg"
 )
 
+var (
+	replayGIF = flag.Bool("gif", false, "also write animated search replays hex_replay.gif and sq_replay.gif")
+	replayOpt replayOptions
+)
+
 func main() {
+	flag.IntVar(&replayOpt.fps, "fps", 10, "replay frame rate")
+	flag.IntVar(&replayOpt.skip, "skip", 1, "node expansions per replay frame")
+	flag.Parse()
+
 	fmt.Println("Running hex demo...")
 	hexDemo()
 
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"os"
+
+	"github.com/fogleman/gg"
+)
+
+// replayOptions controls the animated search replay.
+type replayOptions struct {
+	fps  int // frames per second
+	skip int // node expansions per frame
+}
+
+// replay records the search of rec step by step on top of the map already
+// drawn into ctx. Every `skip` expansions become one frame; each of the
+// finals, e.g. the grid path and the natural path, adds a held frame.
+func replay(ctx *gg.Context, rec *traceRecorder, pos func(x, y int32) (float64, float64), opt replayOptions, finals ...func(*gg.Context)) *gif.GIF {
+	var (
+		out    = &gif.GIF{}
+		img    = ctx.Image().(*image.RGBA)
+		delay  = 100 / max(opt.fps, 1)
+		skip   = max(opt.skip, 1)
+		closed int
+		dirty  image.Rectangle // area changed since the last frame
+	)
+	// Frames after the first only carry the changed area; the decoder
+	// draws them at their bounds over the previous frame.
+	frame := func(area image.Rectangle, d int) {
+		out.Image = append(out.Image, paletted(img.SubImage(area.Intersect(img.Bounds())).(*image.RGBA)))
+		out.Delay = append(out.Delay, d)
+		dirty = image.Rectangle{}
+	}
+	touch := func(x1, y1, x2, y2 int32) {
+		ax, ay := pos(x1, y1)
+		bx, by := pos(x2, y2)
+		dirty = dirty.Union(image.Rect(int(ax), int(ay), int(bx), int(by)).Canon().Inset(-6))
+	}
+
+	frame(img.Bounds(), delay)
+	for _, e := range rec.events {
+		switch e.kind {
+		case traceOpened:
+			drawOpenNode(ctx, e.pos, pos)
+			touch(e.pos.X, e.pos.Y, e.pos.X, e.pos.Y)
+		case traceClosed:
+			drawClosedNode(ctx, e.pos, pos)
+			touch(e.pos.X, e.pos.Y, e.pos.X, e.pos.Y)
+			if closed++; closed%skip == 0 {
+				frame(dirty, delay)
+			}
+		case traceJump:
+			drawJumpRay(ctx, e.scan, pos)
+			touch(e.scan.X, e.scan.Y, e.scan.EndX, e.scan.EndY)
+		case traceForced:
+			drawForcedNode(ctx, e.pos, pos)
+			touch(e.pos.X, e.pos.Y, e.pos.X, e.pos.Y)
+		}
+	}
+	if !dirty.Empty() {
+		frame(dirty, delay)
+	}
+	for _, f := range finals {
+		f(ctx)
+		frame(img.Bounds(), max(delay, 100))
+	}
+	out.Delay[len(out.Delay)-1] = 300
+	return out
+}
+
+// paletted flattens img onto a white background, the way image viewers show
+// the PNG outputs, and converts it to the Plan9 palette. Drawings use few
+// distinct colours, so nearest-colour lookups are cached per RGBA value.
+func paletted(img *image.RGBA) *image.Paletted {
+	b := img.Bounds()
+	p := image.NewPaletted(b, palette.Plan9)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		src := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		dst := p.Pix[p.PixOffset(b.Min.X, y):p.PixOffset(b.Max.X, y)]
+		for i := range dst {
+			c := src[i*4 : i*4+4 : i*4+4]
+			key := uint32(c[0])<<24 | uint32(c[1])<<16 | uint32(c[2])<<8 | uint32(c[3])
+			idx, ok := paletteCache[key]
+			if !ok {
+				idx = uint8(p.Palette.Index(overWhite(color.RGBA{R: c[0], G: c[1], B: c[2], A: c[3]})))
+				paletteCache[key] = idx
+			}
+			dst[i] = idx
+		}
+	}
+	return p
+}
+
+var paletteCache = make(map[uint32]uint8)
+
+func overWhite(c color.RGBA) color.RGBA {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	blend := func(v uint8) uint8 {
+		return uint8((int(v)*int(n.A) + 255*(255-int(n.A))) / 255)
+	}
+	return color.RGBA{R: blend(n.R), G: blend(n.G), B: blend(n.B), A: 255}
+}
+
+func saveGIF(file string, g *gif.GIF) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = gif.EncodeAll(f, g); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
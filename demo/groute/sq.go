@@ -29,17 +29,10 @@ var (
 
 func sqDemo() {
 	bg := gg.NewContext(1000, 1000)
+	trace := gg.NewContext(1000, 1000)
 	ws := createSqMap()
-	for i := int32(0); i < 16*sqNx; i++ {
-		for j := int32(0); j < 16*sqNy; j++ {
-			cx, cy := sqCenter(i, j)
-			if ws.Map.Available(i, j) {
-				drawSquare(bg, sqOx+cx, sqOy+cy, sqSize, sqColorG)
-			} else {
-				drawSquare(bg, sqOx+cx, sqOy+cy, sqSize, sqColorB)
-			}
-		}
-	}
+	drawSqMap(bg, ws)
+	drawSqMap(trace, ws)
 	fmt.Println("start solve")
 	start := time.Now()
 	var (
@@ -53,23 +46,60 @@ func sqDemo() {
 		fmt.Println("no path: ", time.Since(start))
 	} else {
 		fmt.Println("end solve: ", time.Since(start))
-		for i := 1; i < len(path); i++ {
-			x1, y1 := sqCenter(path[i-1].X, path[i-1].Y)
-			x2, y2 := sqCenter(path[i].X, path[i].Y)
-			drawLine(bg, sqOx+x1, sqOy+y1, sqOx+x2, sqOy+y2, sqColorR)
-		}
+	}
+
+	rec := newTraceRecorder()
+	ws.SetTracer(rec)
+	ws.Solve(0, 0, 16*sqNx-1, 16*sqNy-1)
+	ws.SetTracer(nil)
+	drawTrace(trace, rec, sqPos)
+
+	if ok {
+		drawSqPath(bg, path)
+		drawSqPath(trace, path)
 	}
 
 	naturalPath, ok = ws.SolveNatural(0.2, 0.2, float64(16*sqNx)-0.2, float64(16*sqNy)-0.2)
 	if ok {
-		for i := 1; i < len(naturalPath); i++ {
-			x1, y1 := sqPoint(naturalPath[i-1].X, naturalPath[i-1].Y)
-			x2, y2 := sqPoint(naturalPath[i].X, naturalPath[i].Y)
-			drawLine(bg, sqOx+x1, sqOy+y1, sqOx+x2, sqOy+y2, sqColorN)
+		drawNaturalPath(bg, naturalPath)
+	}
+
+	if *replayGIF {
+		anim := gg.NewContext(1000, 1000)
+		drawSqMap(anim, ws)
+		g := replay(anim, rec, sqPos, replayOpt, func(ctx *gg.Context) {
+			drawSqPath(ctx, path)
+		}, func(ctx *gg.Context) {
+			drawNaturalPath(ctx, naturalPath)
+		})
+		if err := saveGIF("sq_replay.gif", g); err != nil {
+			fmt.Println("write replay: ", err)
 		}
 	}
 
 	_ = bg.SavePNG("sq_out.png")
+	_ = trace.SavePNG("sq_trace.png")
+}
+
+func drawSqMap(ctx *gg.Context, ws *sq.WorkSpace) {
+	for i := int32(0); i < 16*sqNx; i++ {
+		for j := int32(0); j < 16*sqNy; j++ {
+			cx, cy := sqCenter(i, j)
+			if ws.Map.Available(i, j) {
+				drawSquare(ctx, sqOx+cx, sqOy+cy, sqSize, sqColorG)
+			} else {
+				drawSquare(ctx, sqOx+cx, sqOy+cy, sqSize, sqColorB)
+			}
+		}
+	}
+}
+
+func drawSqPath(ctx *gg.Context, path []grid.PathGrid) {
+	for i := 1; i < len(path); i++ {
+		x1, y1 := sqPos(path[i-1].X, path[i-1].Y)
+		x2, y2 := sqPos(path[i].X, path[i].Y)
+		drawLine(ctx, x1, y1, x2, y2, sqColorR)
+	}
 }
 
 func createSqMap() *sq.WorkSpace {
@@ -100,6 +130,20 @@ func sqCenter(x, y int32) (cx, cy float64) {
 	return
 }
 
+func drawNaturalPath(ctx *gg.Context, path []grid.PathPoint) {
+	for i := 1; i < len(path); i++ {
+		x1, y1 := sqPoint(path[i-1].X, path[i-1].Y)
+		x2, y2 := sqPoint(path[i].X, path[i].Y)
+		drawLine(ctx, sqOx+x1, sqOy+y1, sqOx+x2, sqOy+y2, sqColorN)
+	}
+}
+
+// sqPos returns the drawing center of cell (x, y).
+func sqPos(x, y int32) (float64, float64) {
+	cx, cy := sqCenter(x, y)
+	return sqOx + cx, sqOy + cy
+}
+
 func sqPoint(x, y float64) (cx, cy float64) {
 	cx = (x - 0.5) * float64(sqSize)
 	cy = (y - 0.5) * float64(sqSize)
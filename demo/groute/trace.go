@@ -0,0 +1,126 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/fogleman/gg"
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/jps"
+)
+
+var (
+	traceColorClosed = color.RGBA{R: 255, G: 140, B: 0, A: 200}
+	traceColorOpen   = color.RGBA{R: 30, G: 144, B: 255, A: 255}
+	traceColorRay    = color.RGBA{R: 148, G: 0, B: 211, A: 160}
+	traceColorMiss   = color.RGBA{R: 128, G: 128, B: 128, A: 90}
+	traceColorForced = color.RGBA{R: 255, G: 215, B: 0, A: 255}
+)
+
+type traceKind uint8
+
+const (
+	traceOpened traceKind = iota
+	traceClosed
+	traceJump
+	traceForced
+)
+
+// traceEvent is one tracer callback in search order.
+type traceEvent struct {
+	kind traceKind
+	pos  grid.Gpos
+	scan jps.JumpScan
+}
+
+// traceRecorder collects the events of one search for drawing.
+type traceRecorder struct {
+	open   map[grid.Gpos]struct{}
+	closed []grid.Gpos
+	jumps  []jps.JumpScan
+	forced []grid.Gpos
+	events []traceEvent
+}
+
+func newTraceRecorder() *traceRecorder {
+	return &traceRecorder{open: make(map[grid.Gpos]struct{})}
+}
+
+// Opened implements jps.Tracer.
+func (r *traceRecorder) Opened(x, y, _, _, _, _ int32) {
+	p := grid.Gpos{X: x, Y: y}
+	r.open[p] = struct{}{}
+	r.events = append(r.events, traceEvent{kind: traceOpened, pos: p})
+}
+
+// Closed implements jps.Tracer.
+func (r *traceRecorder) Closed(x, y, _ int32) {
+	p := grid.Gpos{X: x, Y: y}
+	delete(r.open, p)
+	r.closed = append(r.closed, p)
+	r.events = append(r.events, traceEvent{kind: traceClosed, pos: p})
+}
+
+// Jump implements jps.Tracer.
+func (r *traceRecorder) Jump(scan jps.JumpScan) {
+	r.jumps = append(r.jumps, scan)
+	r.events = append(r.events, traceEvent{kind: traceJump, scan: scan})
+}
+
+// Forced implements jps.Tracer.
+func (r *traceRecorder) Forced(x, y, _ int32, _ jps.DirSet) {
+	p := grid.Gpos{X: x, Y: y}
+	r.forced = append(r.forced, p)
+	r.events = append(r.events, traceEvent{kind: traceForced, pos: p})
+}
+
+// drawTrace draws jump rays, expanded nodes, forced neighbours and the final
+// open list. pos maps a cell to its drawing center.
+func drawTrace(ctx *gg.Context, r *traceRecorder, pos func(x, y int32) (float64, float64)) {
+	for _, j := range r.jumps {
+		drawJumpRay(ctx, j, pos)
+	}
+	for _, p := range r.closed {
+		drawClosedNode(ctx, p, pos)
+	}
+	for _, p := range r.forced {
+		drawForcedNode(ctx, p, pos)
+	}
+	for p := range r.open {
+		drawOpenNode(ctx, p, pos)
+	}
+}
+
+func drawJumpRay(ctx *gg.Context, j jps.JumpScan, pos func(x, y int32) (float64, float64)) {
+	x1, y1 := pos(j.X, j.Y)
+	x2, y2 := pos(j.EndX, j.EndY)
+	if j.Result == jps.JumpBlocked {
+		ctx.SetColor(traceColorMiss)
+	} else {
+		ctx.SetColor(traceColorRay)
+	}
+	ctx.SetLineWidth(1)
+	ctx.DrawLine(x1, y1, x2, y2)
+	ctx.Stroke()
+}
+
+func drawClosedNode(ctx *gg.Context, p grid.Gpos, pos func(x, y int32) (float64, float64)) {
+	x, y := pos(p.X, p.Y)
+	ctx.SetColor(traceColorClosed)
+	ctx.DrawCircle(x, y, 4)
+	ctx.Fill()
+}
+
+func drawForcedNode(ctx *gg.Context, p grid.Gpos, pos func(x, y int32) (float64, float64)) {
+	x, y := pos(p.X, p.Y)
+	ctx.SetColor(traceColorForced)
+	ctx.DrawRectangle(x-1.5, y-1.5, 3, 3)
+	ctx.Fill()
+}
+
+func drawOpenNode(ctx *gg.Context, p grid.Gpos, pos func(x, y int32) (float64, float64)) {
+	x, y := pos(p.X, p.Y)
+	ctx.SetColor(traceColorOpen)
+	ctx.SetLineWidth(1.5)
+	ctx.DrawCircle(x, y, 4)
+	ctx.Stroke()
+}
@@ -0,0 +1,99 @@
+package graph
+
+type NodeStatus int32
+
+const (
+	NodeNew = NodeStatus(iota)
+	NodeOpen
+	NodeClose
+)
+
+// Node is the search state of one graph vertex.
+type Node[K comparable] struct {
+	ID     K
+	Parent int32 // pool index of the predecessor, -1 for the root
+	Cost   float64
+	Total  float64
+	Status NodeStatus
+	index  int32
+	self   int32
+}
+
+// GetHeapIndex returns the node index used by the heap implementation.
+func (n *Node[K]) GetHeapIndex() int32 {
+	return n.index
+}
+
+// SetHeapIndex updates the node index used by the heap implementation.
+func (n *Node[K]) SetHeapIndex(index int32) {
+	n.index = index
+}
+
+// Compare orders nodes by total estimated path cost.
+func (n *Node[K]) Compare(other *Node[K]) int32 {
+	switch {
+	case n.Total < other.Total:
+		return -1
+	case n.Total > other.Total:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// NodePool is a fixed-capacity store of search nodes keyed by vertex ID.
+type NodePool[K comparable] struct {
+	mNode []Node[K] // size = maxNodes
+	mHash map[K]int32
+
+	maxNodes, nodeCnt int32
+}
+
+// NewNodePool creates a reusable pool with capacity for maxNodes search nodes.
+func NewNodePool[K comparable](maxNodes int32) *NodePool[K] {
+	return &NodePool[K]{
+		mNode:    make([]Node[K], maxNodes),
+		mHash:    make(map[K]int32, maxNodes),
+		maxNodes: maxNodes,
+	}
+}
+
+// Clear resets the pool so it can be reused by another search.
+func (p *NodePool[K]) Clear() {
+	clear(p.mHash)
+	p.nodeCnt = 0
+}
+
+// GetNode returns the node of vertex k, allocating it if needed and capacity remains.
+func (p *NodePool[K]) GetNode(k K) *Node[K] {
+	i, ok := p.mHash[k]
+	if ok {
+		return &p.mNode[i]
+	}
+
+	if p.nodeCnt >= p.maxNodes {
+		return nil
+	}
+	i = p.nodeCnt
+	p.mNode[i] = Node[K]{
+		ID:     k,
+		Parent: -1,
+		self:   i,
+	}
+	p.mHash[k] = i
+	p.nodeCnt++
+	return &p.mNode[i]
+}
+
+// FindNode returns the existing node of vertex k, or nil if it has not been allocated.
+func (p *NodePool[K]) FindNode(k K) *Node[K] {
+	if i, ok := p.mHash[k]; ok {
+		return &p.mNode[i]
+	}
+	return nil
+}
+
+// at returns the node stored at pool index i.
+func (p *NodePool[K]) at(i int32) *Node[K] {
+	return &p.mNode[i]
+}
@@ -0,0 +1,181 @@
+package graph
+
+import (
+	"math"
+	"slices"
+
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// Graph enumerates the outgoing edges of a vertex.
+type Graph[K comparable] interface {
+	// Neighbours calls f for every edge leaving k until f returns false.
+	// Edge costs must not be negative.
+	Neighbours(k K, f func(to K, cost float64) bool)
+}
+
+// GraphFunc adapts an ordinary function to the Graph interface.
+type GraphFunc[K comparable] func(k K, f func(to K, cost float64) bool)
+
+// Neighbours implements Graph.
+func (g GraphFunc[K]) Neighbours(k K, f func(to K, cost float64) bool) {
+	g(k, f)
+}
+
+// Heuristic estimates the remaining cost from a vertex to the goal. AStar
+// returns optimal paths only if the heuristic is consistent.
+type Heuristic[K comparable] func(from, goal K) float64
+
+// Search is a reusable graph search workspace. It must not be shared
+// between goroutines.
+type Search[K comparable] struct {
+	pool  *NodePool[K]
+	heap  *heap.Heap[*Node[K]]
+	rpool *NodePool[K]
+	rheap *heap.Heap[*Node[K]]
+}
+
+// NewSearch creates a workspace with capacity for size nodes per search direction.
+func NewSearch[K comparable](size int) *Search[K] {
+	return &Search[K]{
+		pool:  NewNodePool[K](int32(size)),
+		heap:  heap.NewHeap[*Node[K]](size),
+		rpool: NewNodePool[K](int32(size)),
+		rheap: heap.NewHeap[*Node[K]](size),
+	}
+}
+
+// Dijkstra searches the cheapest path from start to goal.
+func (s *Search[K]) Dijkstra(g Graph[K], start, goal K) ([]K, float64, bool) {
+	return s.AStar(g, start, goal, nil)
+}
+
+// AStar searches the cheapest path from start to goal guided by h. A nil
+// heuristic degrades to Dijkstra.
+func (s *Search[K]) AStar(g Graph[K], start, goal K, h Heuristic[K]) ([]K, float64, bool) {
+	s.pool.Clear()
+	s.heap.Clear()
+	estimate := func(k K) float64 {
+		if h == nil {
+			return 0
+		}
+		return h(k, goal)
+	}
+	if s.open(s.pool, s.heap, nil, start, 0, estimate) == nil {
+		return nil, 0, false
+	}
+	for !s.heap.Empty() {
+		cur := s.heap.Pop()
+		cur.Status = NodeClose
+		if cur.ID == goal {
+			return s.path(cur), cur.Cost, true
+		}
+		g.Neighbours(cur.ID, func(to K, cost float64) bool {
+			s.open(s.pool, s.heap, cur, to, cost, estimate)
+			return true
+		})
+	}
+	return nil, 0, false
+}
+
+// Bidirectional runs Dijkstra from start over fwd and from goal over bwd
+// at the same time and returns the cheapest path once the frontiers meet.
+// bwd must hold the reversed edges of fwd; undirected graphs pass the same
+// graph twice.
+func (s *Search[K]) Bidirectional(fwd, bwd Graph[K], start, goal K) ([]K, float64, bool) {
+	s.pool.Clear()
+	s.heap.Clear()
+	s.rpool.Clear()
+	s.rheap.Clear()
+	if start == goal {
+		return []K{start}, 0, true
+	}
+	if s.open(s.pool, s.heap, nil, start, 0, nil) == nil || s.open(s.rpool, s.rheap, nil, goal, 0, nil) == nil {
+		return nil, 0, false
+	}
+
+	var (
+		best = math.Inf(1)
+		meet K
+	)
+	for !s.heap.Empty() && !s.rheap.Empty() {
+		if s.heap.Top().Cost+s.rheap.Top().Cost >= best {
+			break
+		}
+		g, pool, h, other := fwd, s.pool, s.heap, s.rpool
+		if s.rheap.Len() < s.heap.Len() {
+			g, pool, h, other = bwd, s.rpool, s.rheap, s.pool
+		}
+		cur := h.Pop()
+		cur.Status = NodeClose
+		g.Neighbours(cur.ID, func(to K, cost float64) bool {
+			n := s.open(pool, h, cur, to, cost, nil)
+			if n == nil {
+				return true
+			}
+			if o := other.FindNode(to); o != nil && n.Cost+o.Cost < best {
+				best, meet = n.Cost+o.Cost, to
+			}
+			return true
+		})
+	}
+	if math.IsInf(best, 1) {
+		return nil, 0, false
+	}
+
+	p := s.path(s.pool.FindNode(meet))
+	for n := s.rpool.FindNode(meet); n.Parent >= 0; {
+		n = s.rpool.at(n.Parent)
+		p = append(p, n.ID)
+	}
+	return p, best, true
+}
+
+// open relaxes the edge from -> to and returns the node of to, or nil if it
+// is closed or the pool is exhausted. from == nil opens the root.
+func (s *Search[K]) open(pool *NodePool[K], h *heap.Heap[*Node[K]], from *Node[K], to K, cost float64, estimate func(K) float64) *Node[K] {
+	node := pool.GetNode(to)
+	if node == nil {
+		return nil
+	}
+	parent := int32(-1)
+	if from != nil {
+		parent = from.self
+		cost += from.Cost
+	}
+	switch node.Status {
+	case NodeNew:
+		node.Parent = parent
+		node.Cost = cost
+		node.Total = cost
+		if estimate != nil {
+			node.Total += estimate(to)
+		}
+		node.Status = NodeOpen
+		h.Push(node)
+	case NodeOpen:
+		if cost < node.Cost {
+			node.Total += cost - node.Cost
+			node.Parent = parent
+			node.Cost = cost
+			h.Fix(node)
+		}
+	case NodeClose:
+		return nil
+	}
+	return node
+}
+
+// path returns the vertices from the search root to n in the forward pool.
+func (s *Search[K]) path(n *Node[K]) []K {
+	var p []K
+	for {
+		p = append(p, n.ID)
+		if n.Parent < 0 {
+			break
+		}
+		n = s.pool.at(n.Parent)
+	}
+	slices.Reverse(p)
+	return p
+}
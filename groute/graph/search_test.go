@@ -0,0 +1,161 @@
+package graph
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type edge struct {
+	to   int
+	cost float64
+}
+
+type adjacency map[int][]edge
+
+func (a adjacency) Neighbours(k int, f func(to int, cost float64) bool) {
+	for _, e := range a[k] {
+		if !f(e.to, e.cost) {
+			return
+		}
+	}
+}
+
+func (a adjacency) reverse() adjacency {
+	r := make(adjacency, len(a))
+	for from, es := range a {
+		for _, e := range es {
+			r[e.to] = append(r[e.to], edge{to: from, cost: e.cost})
+		}
+	}
+	return r
+}
+
+func randomGraph(rng *rand.Rand, n, m int) adjacency {
+	a := make(adjacency, n)
+	for i := 0; i < m; i++ {
+		from, to := rng.Intn(n), rng.Intn(n)
+		a[from] = append(a[from], edge{to: to, cost: float64(1 + rng.Intn(20))})
+	}
+	return a
+}
+
+// floyd computes all pairs shortest distances as reference.
+func floyd(a adjacency, n int) [][]float64 {
+	d := make([][]float64, n)
+	for i := range d {
+		d[i] = make([]float64, n)
+		for j := range d[i] {
+			d[i][j] = math.Inf(1)
+		}
+		d[i][i] = 0
+	}
+	for from, es := range a {
+		for _, e := range es {
+			d[from][e.to] = min(d[from][e.to], e.cost)
+		}
+	}
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				d[i][j] = min(d[i][j], d[i][k]+d[k][j])
+			}
+		}
+	}
+	return d
+}
+
+func pathCost(t *testing.T, a adjacency, p []int) float64 {
+	var c float64
+	for i := 1; i < len(p); i++ {
+		best := math.Inf(1)
+		for _, e := range a[p[i-1]] {
+			if e.to == p[i] {
+				best = min(best, e.cost)
+			}
+		}
+		require.False(t, math.IsInf(best, 1), "missing edge %d->%d", p[i-1], p[i])
+		c += best
+	}
+	return c
+}
+
+func TestSearch_AStarGridHeuristic(t *testing.T) {
+	// 10x10 four-connected lattice with a wall at x=5 except y=9.
+	const w = 10
+	id := func(x, y int) int { return y*w + x }
+	a := make(adjacency)
+	blocked := func(x, y int) bool { return x == 5 && y != 9 }
+	for x := 0; x < w; x++ {
+		for y := 0; y < w; y++ {
+			if blocked(x, y) {
+				continue
+			}
+			for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				nx, ny := x+d[0], y+d[1]
+				if nx < 0 || ny < 0 || nx >= w || ny >= w || blocked(nx, ny) {
+					continue
+				}
+				a[id(x, y)] = append(a[id(x, y)], edge{to: id(nx, ny), cost: 1})
+			}
+		}
+	}
+	manhattan := func(from, goal int) float64 {
+		return math.Abs(float64(from%w-goal%w)) + math.Abs(float64(from/w-goal/w))
+	}
+
+	s := NewSearch[int](w * w)
+	p, c, ok := s.AStar(a, id(0, 0), id(9, 0), manhattan)
+	require.True(t, ok)
+	assert.Equal(t, float64(9+2*9), c)
+	assert.Equal(t, c, pathCost(t, a, p))
+	assert.Equal(t, id(0, 0), p[0])
+	assert.Equal(t, id(9, 0), p[len(p)-1])
+}
+
+func TestSearch_MatchesFloyd(t *testing.T) {
+	const n = 40
+	rng := rand.New(rand.NewSource(7))
+	s := NewSearch[int](n)
+	for round := 0; round < 20; round++ {
+		a := randomGraph(rng, n, 120)
+		r := a.reverse()
+		ref := floyd(a, n)
+		for q := 0; q < 20; q++ {
+			from, to := rng.Intn(n), rng.Intn(n)
+			want := ref[from][to]
+
+			p, c, ok := s.Dijkstra(a, from, to)
+			require.Equal(t, !math.IsInf(want, 1), ok)
+			if ok {
+				assert.InDelta(t, want, c, 1e-9)
+				assert.InDelta(t, want, pathCost(t, a, p), 1e-9)
+			}
+
+			p, c, ok = s.Bidirectional(a, r, from, to)
+			require.Equal(t, !math.IsInf(want, 1), ok, "bidirectional %d->%d", from, to)
+			if ok {
+				assert.InDelta(t, want, c, 1e-9)
+				assert.InDelta(t, want, pathCost(t, a, p), 1e-9)
+				assert.Equal(t, from, p[0])
+				assert.Equal(t, to, p[len(p)-1])
+			}
+		}
+	}
+}
+
+func TestSearch_PoolExhausted(t *testing.T) {
+	line := GraphFunc[int](func(k int, f func(int, float64) bool) {
+		f(k+1, 1)
+	})
+	s := NewSearch[int](8)
+	_, _, ok := s.Dijkstra(line, 0, 100)
+	assert.False(t, ok)
+	p, c, ok := s.Dijkstra(line, 0, 5)
+	require.True(t, ok)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, p)
+	assert.Equal(t, 5.0, c)
+}
@@ -0,0 +1,102 @@
+package grid
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// ErrFormat is returned when decoding data that is not a valid map.
+var ErrFormat = errors.New("grid: invalid map data")
+
+var magic = [4]byte{'G', 'R', 'D', '1'}
+
+// MaxBlocks bounds the number of blocks of a decoded map, so a corrupt or
+// hostile header cannot allocate unbounded memory.
+const MaxBlocks = 1 << 20
+
+// WriteTo encodes the map in the library's binary format: the magic "GRD1",
+// Nx and Ny as little-endian int32, then for every block in x-major order a
+// presence byte followed, for present blocks, by its 16 rows as uint16.
+func (w *Local) WriteTo(wr io.Writer) (int64, error) {
+	bw := bufio.NewWriter(wr)
+	n := int64(0)
+	put := func(v any) {
+		_ = binary.Write(bw, binary.LittleEndian, v)
+		n += int64(binary.Size(v))
+	}
+	put(magic)
+	put([2]int32{w.Nx, w.Ny})
+	for i := int32(0); i < w.Nx; i++ {
+		for j := int32(0); j < w.Ny; j++ {
+			g := w.Grids[i][j]
+			if g == nil {
+				put(uint8(0))
+				continue
+			}
+			put(uint8(1))
+			put(g.Bits)
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ReadLocal decodes a map written by Local.WriteTo.
+func ReadLocal(r io.Reader) (*Local, error) {
+	br := bufio.NewReader(r)
+	var (
+		head [4]byte
+		size [2]int32
+	)
+	if err := binary.Read(br, binary.LittleEndian, &head); err != nil {
+		return nil, err
+	}
+	if head != magic {
+		return nil, ErrFormat
+	}
+	if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size[0] <= 0 || size[1] <= 0 || int64(size[0])*int64(size[1]) > MaxBlocks {
+		return nil, ErrFormat
+	}
+	w := NewLocal(size[0], size[1])
+	for i := int32(0); i < w.Nx; i++ {
+		for j := int32(0); j < w.Ny; j++ {
+			present, err := br.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			switch present {
+			case 0:
+			case 1:
+				g := new(Grid)
+				if err = binary.Read(br, binary.LittleEndian, &g.Bits); err != nil {
+					return nil, err
+				}
+				w.Grids[i][j] = g
+			default:
+				return nil, ErrFormat
+			}
+		}
+	}
+	return w, nil
+}
+
+// ReadSlice reads n little-endian values in chunks, so that a truncated
+// input fails before a slice of the size its header claims is allocated.
+func ReadSlice[T int32 | uint16 | uint32](r io.Reader, n int) ([]T, error) {
+	const chunk = 1 << 16
+	t := make([]T, 0, min(n, chunk))
+	for len(t) < n {
+		k := min(n-len(t), max(len(t), chunk))
+		t = slices.Grow(t, k)
+		if err := binary.Read(r, binary.LittleEndian, t[len(t):len(t)+k]); err != nil {
+			return nil, err
+		}
+		t = t[:len(t)+k]
+	}
+	return t, nil
+}
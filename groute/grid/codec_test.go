@@ -0,0 +1,49 @@
+package grid
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal_WriteRead(t *testing.T) {
+	w := NewLocal(3, 2)
+	for i := int32(0); i < 3; i++ {
+		for j := int32(0); j < 2; j++ {
+			if i == 1 && j == 1 {
+				continue // keep one block nil
+			}
+			w.SetGrid(i, j, new(Grid))
+		}
+	}
+	for k := 0; k < 300; k++ {
+		x, y := rand.Int31n(48), rand.Int31n(32)
+		if w.GetGrid(x/16, y/16) != nil {
+			w.Set(x, y)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	got, err := ReadLocal(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, w.Nx, got.Nx)
+	assert.Equal(t, w.Ny, got.Ny)
+	assert.Nil(t, got.GetGrid(1, 1))
+	for x := int32(0); x < 48; x++ {
+		for y := int32(0); y < 32; y++ {
+			if w.GetGrid(x/16, y/16) != nil {
+				assert.Equal(t, w.Available(x, y), got.Available(x, y))
+			}
+		}
+	}
+
+	_, err = ReadLocal(bytes.NewReader([]byte("nope....")))
+	assert.ErrorIs(t, err, ErrFormat)
+}
@@ -0,0 +1,119 @@
+package grid
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// ImageOptions controls how FromImage turns pixels into cells.
+type ImageOptions struct {
+	// Scale is the side of the square of pixels that makes up one cell,
+	// 0 means 1.
+	Scale int
+	// Blocked reports whether a pixel is an obstacle. When nil a pixel is
+	// blocked if its gray level is below Threshold.
+	Blocked func(c color.Color) bool
+	// Threshold is the gray level used when Blocked is nil, 0 means 128.
+	// Transparent pixels read as black.
+	Threshold uint8
+	// Coverage is the fraction of blocked pixels from which a cell is
+	// blocked. 0 blocks a cell as soon as one of its pixels is blocked.
+	Coverage float64
+}
+
+// ColorBlocked returns an ImageOptions.Blocked function that blocks pixels
+// of exactly one of the given colours.
+func ColorBlocked(colors ...color.Color) func(c color.Color) bool {
+	type rgba struct{ r, g, b, a uint32 }
+	set := make(map[rgba]struct{}, len(colors))
+	for _, c := range colors {
+		r, g, b, a := c.RGBA()
+		set[rgba{r, g, b, a}] = struct{}{}
+	}
+	return func(c color.Color) bool {
+		r, g, b, a := c.RGBA()
+		_, ok := set[rgba{r, g, b, a}]
+		return ok
+	}
+}
+
+// FromImage builds a map from an image mask. Cells are Scale by Scale pixel
+// squares, a partial square at the right or bottom edge counts its pixels
+// only. The map is padded to whole blocks with blocked cells. w and h are
+// the number of cells covered by the image.
+func FromImage(img image.Image, opt ImageOptions) (m *Local, w, h int32) {
+	scale := max(opt.Scale, 1)
+	blocked := opt.Blocked
+	if blocked == nil {
+		threshold := opt.Threshold
+		if threshold == 0 {
+			threshold = 128
+		}
+		blocked = func(c color.Color) bool {
+			return color.GrayModel.Convert(c).(color.Gray).Y < threshold
+		}
+	}
+
+	b := img.Bounds()
+	w = int32((b.Dx() + scale - 1) / scale)
+	h = int32((b.Dy() + scale - 1) / scale)
+	m = NewLocal((w+g16-1)/g16, (h+g16-1)/g16)
+	for i := int32(0); i < m.Nx; i++ {
+		for j := int32(0); j < m.Ny; j++ {
+			m.SetGrid(i, j, new(Grid))
+		}
+	}
+	for y := int32(0); y < m.Ny*g16; y++ {
+		for x := int32(0); x < m.Nx*g16; x++ {
+			if x >= w || y >= h {
+				m.Set(x, y)
+				continue
+			}
+			var (
+				r         = image.Rect(int(x)*scale, int(y)*scale, int(x+1)*scale, int(y+1)*scale).Add(b.Min).Intersect(b)
+				total, nb int
+			)
+			for py := r.Min.Y; py < r.Max.Y; py++ {
+				for px := r.Min.X; px < r.Max.X; px++ {
+					total++
+					if blocked(img.At(px, py)) {
+						nb++
+					}
+				}
+			}
+			if opt.Coverage <= 0 && nb > 0 || opt.Coverage > 0 && float64(nb) >= opt.Coverage*float64(total) {
+				m.Set(x, y)
+			}
+		}
+	}
+	return m, w, h
+}
+
+// Image draws the map as a gray mask, black for blocked cells and white for
+// free ones, with scale by scale pixels per cell.
+func (w *Local) Image(scale int) *image.Gray {
+	scale = max(scale, 1)
+	img := image.NewGray(image.Rect(0, 0, int(w.Nx*g16)*scale, int(w.Ny*g16)*scale))
+	for y := int32(0); y < w.Ny*g16; y++ {
+		for x := int32(0); x < w.Nx*g16; x++ {
+			if !w.Available(x, y) {
+				continue
+			}
+			for py := int(y) * scale; py < int(y+1)*scale; py++ {
+				row := img.Pix[py*img.Stride:]
+				for px := int(x) * scale; px < int(x+1)*scale; px++ {
+					row[px] = 0xff
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG encodes Image(scale) as PNG. The result reads back with
+// FromImage using the same scale and the default threshold.
+func (w *Local) WritePNG(wr io.Writer, scale int) error {
+	return png.Encode(wr, w.Image(scale))
+}
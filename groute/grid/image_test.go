@@ -0,0 +1,92 @@
+package grid
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromImage_Threshold(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 20, 3))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.SetGray(2, 1, color.Gray{Y: 0x7f})
+	img.SetGray(3, 1, color.Gray{Y: 0x80})
+
+	m, w, h := FromImage(img, ImageOptions{})
+	assert.Equal(t, int32(20), w)
+	assert.Equal(t, int32(3), h)
+	assert.Equal(t, int32(2), m.Nx)
+	assert.Equal(t, int32(1), m.Ny)
+	assert.False(t, m.Available(2, 1))
+	assert.True(t, m.Available(3, 1))
+	assert.True(t, m.Available(19, 2))
+	assert.False(t, m.Available(20, 0)) // 补齐部分为障碍
+	assert.False(t, m.Available(0, 3))
+
+	m, _, _ = FromImage(img, ImageOptions{Threshold: 0x90})
+	assert.False(t, m.Available(3, 1))
+}
+
+func TestFromImage_ScaleColor(t *testing.T) {
+	wall := color.RGBA{R: 0xff, A: 0xff}
+	img := image.NewRGBA(image.Rect(10, 10, 19, 16)) // 非零原点，宽度不是 scale 的整数倍
+	for y := 10; y < 16; y++ {
+		for x := 10; x < 19; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	img.Set(10, 10, wall) // cell (0,0) 有 1/9 的障碍像素
+	for y := 13; y < 16; y++ {
+		for x := 13; x < 15; x++ {
+			img.Set(x, y, wall) // cell (1,1) 有 6/9 的障碍像素
+		}
+	}
+	img.Set(18, 10, wall) // cell (2,0) 只有 1x3 像素
+
+	opt := ImageOptions{Scale: 3, Blocked: ColorBlocked(wall)}
+	m, w, h := FromImage(img, opt)
+	assert.Equal(t, int32(3), w)
+	assert.Equal(t, int32(2), h)
+	assert.False(t, m.Available(0, 0))
+	assert.False(t, m.Available(1, 1))
+	assert.False(t, m.Available(2, 0))
+	assert.True(t, m.Available(1, 0))
+
+	opt.Coverage = 0.5
+	m, _, _ = FromImage(img, opt)
+	assert.True(t, m.Available(0, 0))
+	assert.False(t, m.Available(1, 1))
+	assert.True(t, m.Available(2, 0)) // 1/3 < 0.5
+}
+
+func TestLocal_WritePNG(t *testing.T) {
+	m := NewLocal(2, 1)
+	m.SetGrid(0, 0, new(Grid))
+	m.SetGrid(1, 0, new(Grid))
+	for k := 0; k < 100; k++ {
+		m.Set(rand.Int31n(32), rand.Int31n(16))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, m.WritePNG(&buf, 2))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 64, 32), img.Bounds())
+
+	got, w, h := FromImage(img, ImageOptions{Scale: 2})
+	assert.Equal(t, int32(32), w)
+	assert.Equal(t, int32(16), h)
+	for x := int32(0); x < 32; x++ {
+		for y := int32(0); y < 16; y++ {
+			assert.Equal(t, m.Available(x, y), got.Available(x, y), "(%d,%d)", x, y)
+		}
+	}
+}
@@ -45,6 +45,11 @@ func (n *Gnode) Compare(other *Gnode) int32 {
 	return int32(n.Total - other.Total)
 }
 
+// HeapKey returns the total estimated path cost as bucket queue key.
+func (n *Gnode) HeapKey() int32 {
+	return n.Total
+}
+
 type NodePool struct {
 	mNode []Gnode // size = maxNodes
 	mHash map[Gpos]int32
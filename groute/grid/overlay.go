@@ -0,0 +1,195 @@
+package grid
+
+// Owner identifies who placed a dynamic obstacle, e.g. a unit or a spell.
+type Owner uint64
+
+// NoOwner is an Owner that never matches a placed obstacle; an Overlay
+// ignoring NoOwner sees every obstacle.
+const NoOwner Owner = 0
+
+// Layer is a sparse set of dynamic obstacles kept apart from the static
+// map. A cell may be blocked by several owners at once and stays blocked
+// until every owner released it.
+//
+// A Layer must not be modified while a search reads it. Searches may share
+// it as long as nobody writes.
+type Layer struct {
+	blocks map[Gpos]*layerBlock
+	owners map[Owner]map[PathGrid]struct{}
+	n      int
+}
+
+// layerBlock mirrors the 16x16 block layout of Local so that cells without
+// obstacles are rejected with one bit test.
+type layerBlock struct {
+	bits  Grid
+	cells map[PathGrid][]Owner
+}
+
+// NewLayer creates an empty layer.
+func NewLayer() *Layer {
+	return &Layer{
+		blocks: make(map[Gpos]*layerBlock),
+		owners: make(map[Owner]map[PathGrid]struct{}),
+	}
+}
+
+// Block marks cell (x, y) as blocked by owner. Blocking a cell twice with
+// the same owner has no further effect.
+func (l *Layer) Block(x, y int32, owner Owner) {
+	p := PathGrid{X: x, Y: y}
+	key, ix, iy := layerKey(x, y)
+	b := l.blocks[key]
+	if b == nil {
+		b = &layerBlock{cells: make(map[PathGrid][]Owner)}
+		l.blocks[key] = b
+	}
+	owners := b.cells[p]
+	for _, o := range owners {
+		if o == owner {
+			return
+		}
+	}
+	if len(owners) == 0 {
+		l.n++
+	}
+	b.cells[p] = append(owners, owner)
+	b.bits.Bits[iy] |= 1 << ix
+
+	cells := l.owners[owner]
+	if cells == nil {
+		cells = make(map[PathGrid]struct{})
+		l.owners[owner] = cells
+	}
+	cells[p] = struct{}{}
+}
+
+// Unblock releases the obstacle owner placed on cell (x, y), if any.
+func (l *Layer) Unblock(x, y int32, owner Owner) {
+	p := PathGrid{X: x, Y: y}
+	key, ix, iy := layerKey(x, y)
+	b := l.blocks[key]
+	if b == nil {
+		return
+	}
+	owners := b.cells[p]
+	for i, o := range owners {
+		if o != owner {
+			continue
+		}
+		owners = append(owners[:i], owners[i+1:]...)
+		if len(owners) == 0 {
+			delete(b.cells, p)
+			b.bits.Bits[iy] &^= 1 << ix
+			l.n--
+			if len(b.cells) == 0 {
+				delete(l.blocks, key)
+			}
+		} else {
+			b.cells[p] = owners
+		}
+		cells := l.owners[owner]
+		delete(cells, p)
+		if len(cells) == 0 {
+			delete(l.owners, owner)
+		}
+		return
+	}
+}
+
+// UnblockOwner releases every obstacle placed by owner.
+func (l *Layer) UnblockOwner(owner Owner) {
+	for p := range l.owners[owner] {
+		l.Unblock(p.X, p.Y, owner)
+	}
+}
+
+// Blocked reports whether cell (x, y) is blocked by an owner other than
+// ignore.
+func (l *Layer) Blocked(x, y int32, ignore Owner) bool {
+	if l.n == 0 {
+		return false
+	}
+	key, ix, iy := layerKey(x, y)
+	b := l.blocks[key]
+	if b == nil || b.bits.Bits[iy]&(1<<ix) == 0 {
+		return false
+	}
+	if ignore == NoOwner {
+		return true
+	}
+	for _, o := range b.cells[PathGrid{X: x, Y: y}] {
+		if o != ignore {
+			return true
+		}
+	}
+	return false
+}
+
+// Owners returns the owners blocking cell (x, y). The slice must not be
+// modified.
+func (l *Layer) Owners(x, y int32) []Owner {
+	key, _, _ := layerKey(x, y)
+	if b := l.blocks[key]; b != nil {
+		return b.cells[PathGrid{X: x, Y: y}]
+	}
+	return nil
+}
+
+// Len returns the number of blocked cells.
+func (l *Layer) Len() int {
+	return l.n
+}
+
+// Clear removes every obstacle.
+func (l *Layer) Clear() {
+	clear(l.blocks)
+	clear(l.owners)
+	l.n = 0
+}
+
+func layerKey(x, y int32) (key Gpos, ix, iy int32) {
+	// floor division keeps negative coordinates in their own block
+	key = Gpos{X: x >> 4, Y: y >> 4}
+	return key, x & (g16 - 1), y & (g16 - 1)
+}
+
+// Overlay composes a static base map with dynamic obstacle layers without
+// modifying either. A cell is available if the base allows it and no layer
+// blocks it, ignoring obstacles placed by Ignore.
+type Overlay struct {
+	Base   Walkable
+	Layers []*Layer
+	Ignore Owner
+}
+
+// NewOverlay creates an overlay of layers over base.
+func NewOverlay(base Walkable, layers ...*Layer) *Overlay {
+	return &Overlay{Base: base, Layers: layers}
+}
+
+// Ignoring returns a view of the same base and layers that ignores the
+// obstacles placed by owner, so an agent does not block itself.
+func (o *Overlay) Ignoring(owner Owner) *Overlay {
+	v := *o
+	v.Ignore = owner
+	return &v
+}
+
+// Available implements Walkable.
+func (o *Overlay) Available(x, y int32) bool {
+	if !o.Base.Available(x, y) {
+		return false
+	}
+	for _, l := range o.Layers {
+		if l.Blocked(x, y, o.Ignore) {
+			return false
+		}
+	}
+	return true
+}
+
+// Bounds implements Walkable.
+func (o *Overlay) Bounds() Rect {
+	return o.Base.Bounds()
+}
@@ -0,0 +1,65 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayer(t *testing.T) {
+	l := NewLayer()
+	l.Block(3, 4, 1)
+	l.Block(3, 4, 1) // 重复放置无效果
+	l.Block(3, 4, 2)
+	l.Block(-5, -17, 1) // 负坐标
+	assert.Equal(t, 2, l.Len())
+	assert.ElementsMatch(t, []Owner{1, 2}, l.Owners(3, 4))
+
+	assert.True(t, l.Blocked(3, 4, NoOwner))
+	assert.True(t, l.Blocked(3, 4, 1)) // 仍被 2 阻挡
+	assert.True(t, l.Blocked(-5, -17, NoOwner))
+	assert.False(t, l.Blocked(-5, -17, 1))
+	assert.False(t, l.Blocked(4, 4, NoOwner))
+	assert.False(t, l.Blocked(11, -1, NoOwner)) // 与 (-5,-17) 不同块
+
+	l.Unblock(3, 4, 2)
+	assert.False(t, l.Blocked(3, 4, 1))
+	assert.True(t, l.Blocked(3, 4, 2))
+	l.Unblock(3, 4, 7) // 非占用者释放无效果
+	assert.Equal(t, 2, l.Len())
+
+	l.UnblockOwner(1)
+	assert.Equal(t, 0, l.Len())
+	assert.False(t, l.Blocked(3, 4, NoOwner))
+	assert.False(t, l.Blocked(-5, -17, NoOwner))
+	assert.Empty(t, l.blocks)
+	assert.Empty(t, l.owners)
+
+	l.Block(1, 1, 3)
+	l.Clear()
+	assert.False(t, l.Blocked(1, 1, NoOwner))
+}
+
+func TestOverlay(t *testing.T) {
+	tm := MustParseText(`
+..#.
+....
+`)
+	units, spells := NewLayer(), NewLayer()
+	units.Block(0, 0, 10)
+	spells.Block(1, 1, 20)
+	o := NewOverlay(tm.Map, units, spells)
+
+	assert.Equal(t, tm.Map.Bounds(), o.Bounds())
+	assert.False(t, o.Available(0, 0))
+	assert.False(t, o.Available(1, 1))
+	assert.False(t, o.Available(2, 0)) // 静态障碍
+	assert.True(t, o.Available(3, 1))
+	assert.True(t, tm.Map.Available(0, 0), "base map unchanged")
+
+	self := o.Ignoring(10)
+	assert.True(t, self.Available(0, 0))
+	assert.False(t, self.Available(1, 1))
+	assert.False(t, self.Available(2, 0))
+	assert.False(t, o.Available(0, 0), "view does not change the overlay")
+}
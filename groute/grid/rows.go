@@ -0,0 +1,133 @@
+package grid
+
+// RowReader is an optional Walkable extension that reads 16 cells at once.
+type RowReader interface {
+	// BlockRow returns the cells [bx*16, bx*16+16) of row y as a bit set,
+	// bit i set when cell bx*16+i is not available. Cells outside Bounds
+	// are reported as blocked.
+	BlockRow(bx, y int32) uint16
+}
+
+var (
+	_ RowReader = (*Local)(nil)
+	_ RowReader = (*Overlay)(nil)
+)
+
+// BlockRow implements RowReader.
+func (w *Local) BlockRow(bx, y int32) uint16 {
+	if uint32(bx) >= uint32(w.Nx) || uint32(y) >= uint32(w.Ny*g16) {
+		return 0xffff
+	}
+	return w.Grids[bx][y/g16].Bits[y%g16]
+}
+
+// BlockRow implements RowReader.
+func (o *Overlay) BlockRow(bx, y int32) uint16 {
+	bits := ReadBlockRow(o.Base, bx, y)
+	for _, l := range o.Layers {
+		if bits == 0xffff {
+			break
+		}
+		lb := l.blockRow(bx, y)
+		if lb == 0 {
+			continue
+		}
+		if o.Ignore == NoOwner {
+			bits |= lb
+			continue
+		}
+		for i := int32(0); i < g16; i++ {
+			if lb&(1<<i) != 0 && l.Blocked(bx*g16+i, y, o.Ignore) {
+				bits |= 1 << i
+			}
+		}
+	}
+	return bits
+}
+
+func (l *Layer) blockRow(bx, y int32) uint16 {
+	if l.n == 0 {
+		return 0
+	}
+	if b := l.blocks[Gpos{X: bx, Y: y >> 4}]; b != nil {
+		return b.bits.Bits[y&(g16-1)]
+	}
+	return 0
+}
+
+// ReadBlockRow reads a row of 16 cells from any map, using RowReader when
+// m implements it.
+func ReadBlockRow(m Walkable, bx, y int32) uint16 {
+	if r, ok := m.(RowReader); ok {
+		return r.BlockRow(bx, y)
+	}
+	var bits uint16
+	for i := int32(0); i < g16; i++ {
+		if !m.Available(bx*g16+i, y) {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+// FuncMap adapts a function to Walkable, e.g. for procedurally generated
+// worlds or test doubles. F is only called for cells inside R.
+type FuncMap struct {
+	R Rect
+	F func(x, y int32) bool
+}
+
+var _ Walkable = FuncMap{}
+
+// Available implements Walkable.
+func (f FuncMap) Available(x, y int32) bool {
+	return f.R.Contains(x, y) && f.F(x, y)
+}
+
+// Bounds implements Walkable.
+func (f FuncMap) Bounds() Rect {
+	return f.R
+}
+
+const rowCacheSize = 64
+
+// RowCache answers Available from recently read block rows of a map that
+// implements RowReader, so a search reads each row once instead of calling
+// the map for every cell. The map must not change between Invalidate
+// calls.
+type RowCache struct {
+	r       RowReader
+	gen     uint32
+	entries [rowCacheSize]rowEntry
+}
+
+type rowEntry struct {
+	bx, y int32
+	gen   uint32
+	bits  uint16
+}
+
+// Reset binds the cache to r and drops every cached row.
+func (c *RowCache) Reset(r RowReader) {
+	c.r = r
+	c.Invalidate()
+}
+
+// Invalidate drops every cached row.
+func (c *RowCache) Invalidate() {
+	c.gen++
+	if c.gen == 0 {
+		c.entries = [rowCacheSize]rowEntry{}
+		c.gen = 1
+	}
+}
+
+// Available reports whether cell (x, y) is available.
+func (c *RowCache) Available(x, y int32) bool {
+	bx := x >> 4
+	e := &c.entries[uint32(bx*7+y)%rowCacheSize]
+	if e.gen != c.gen || e.bx != bx || e.y != y {
+		*e = rowEntry{bx: bx, y: y, gen: c.gen, bits: c.r.BlockRow(bx, y)}
+	}
+	return e.bits&(1<<(x&(g16-1))) == 0
+}
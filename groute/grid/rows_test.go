@@ -0,0 +1,91 @@
+package grid
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomLocal(rng *rand.Rand, nx, ny int32) *Local {
+	m := NewLocal(nx, ny)
+	for i := int32(0); i < nx; i++ {
+		for j := int32(0); j < ny; j++ {
+			m.SetGrid(i, j, new(Grid))
+		}
+	}
+	for k := 0; k < int(nx*ny)*60; k++ {
+		m.Set(rng.Int31n(nx*16), rng.Int31n(ny*16))
+	}
+	return m
+}
+
+// 逐格比对 BlockRow 与 Available，包括越界的行和块
+func checkRows(t *testing.T, m Walkable, r RowReader) {
+	b := m.Bounds()
+	for y := b.MinY - 2; y < b.MaxY+2; y++ {
+		for bx := b.MinX>>4 - 1; bx <= (b.MaxX-1)>>4+1; bx++ {
+			bits := r.BlockRow(bx, y)
+			for i := int32(0); i < 16; i++ {
+				x := bx*16 + i
+				assert.Equal(t, !m.Available(x, y), bits&(1<<i) != 0, "(%d,%d)", x, y)
+			}
+		}
+	}
+}
+
+func TestBlockRow(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	m := randomLocal(rng, 2, 2)
+	checkRows(t, m, m)
+
+	l := NewLayer()
+	for k := 0; k < 80; k++ {
+		l.Block(rng.Int31n(32), rng.Int31n(32), Owner(1+rng.Intn(3)))
+	}
+	o := NewOverlay(m, l)
+	checkRows(t, o, o)
+	checkRows(t, o.Ignoring(2), o.Ignoring(2))
+
+	// 底图不实现 RowReader 时逐格读取
+	f := FuncMap{R: m.Bounds(), F: m.Available}
+	fo := NewOverlay(f, l).Ignoring(3)
+	checkRows(t, fo, fo)
+}
+
+func TestFuncMap(t *testing.T) {
+	calls := 0
+	f := FuncMap{
+		R: Rect{MinX: -4, MinY: -4, MaxX: 4, MaxY: 4},
+		F: func(x, y int32) bool { calls++; return (x+y)&1 == 0 },
+	}
+	assert.True(t, f.Available(-2, 0))
+	assert.False(t, f.Available(-1, 0))
+	assert.False(t, f.Available(4, 0)) // 越界不调用 F
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, uint16(0xaaaa), ReadBlockRow(f, -1, 0)&0xaaaa)
+}
+
+func TestRowCache(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	m := randomLocal(rng, 3, 3)
+	l := NewLayer()
+	o := NewOverlay(m, l)
+
+	var c RowCache
+	c.Reset(o)
+	for k := 0; k < 5000; k++ {
+		x, y := rng.Int31n(60)-6, rng.Int31n(60)-6
+		assert.Equal(t, o.Available(x, y), c.Available(x, y), "(%d,%d)", x, y)
+	}
+
+	// 地图变化后需要 Invalidate
+	l.Block(5, 5, 1)
+	c.Available(5, 5)
+	c.Invalidate()
+	assert.False(t, c.Available(5, 5))
+	l.Unblock(5, 5, 1)
+	assert.False(t, c.Available(5, 5), "stale until invalidated")
+	c.Invalidate()
+	assert.Equal(t, m.Available(5, 5), c.Available(5, 5))
+}
@@ -0,0 +1,235 @@
+package grid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// TextLayout selects how FormatText arranges cells.
+type TextLayout uint8
+
+const (
+	// TextSquare prints one character per cell.
+	TextSquare TextLayout = iota
+	// TextHex separates cells with a space and indents odd rows by one
+	// space, matching the odd-r offset layout of package hex.
+	TextHex
+)
+
+// Characters of the text map format.
+const (
+	TextFree    = '.'
+	TextBlocked = '#'
+	TextStart   = 'S'
+	TextGoal    = 'G'
+	TextPath    = '*'
+)
+
+// TextMap is a map parsed by ParseText.
+type TextMap struct {
+	Map *Local
+	// W and H are the size of the text, the map is padded to whole blocks
+	// with blocked cells.
+	W, H int32
+	// Start and Goal are the cells marked S and G.
+	Start, Goal       PathGrid
+	HasStart, HasGoal bool
+}
+
+// ParseText reads a map in the text format: '.' is a free cell, '#' a
+// blocked one and 'S' / 'G' mark free start and goal cells. '*' is read as
+// free so FormatText output parses back. Spaces and tabs are ignored, so
+// square and hex layouts parse the same way, and empty lines are skipped.
+// Short rows are padded with blocked cells.
+func ParseText(r io.Reader) (*TextMap, error) {
+	var (
+		tm   TextMap
+		rows [][]bool
+		sc   = bufio.NewScanner(r)
+	)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var row []bool
+		for col, c := range sc.Text() {
+			p := PathGrid{X: int32(len(row)), Y: int32(len(rows))}
+			switch c {
+			case ' ', '\t', '\r':
+				continue
+			case TextFree, TextPath:
+			case TextBlocked:
+			case TextStart:
+				if tm.HasStart {
+					return nil, fmt.Errorf("%w: line %d: second start", ErrFormat, line)
+				}
+				tm.Start, tm.HasStart = p, true
+			case TextGoal:
+				if tm.HasGoal {
+					return nil, fmt.Errorf("%w: line %d: second goal", ErrFormat, line)
+				}
+				tm.Goal, tm.HasGoal = p, true
+			default:
+				return nil, fmt.Errorf("%w: line %d col %d: unexpected %q", ErrFormat, line, col+1, c)
+			}
+			row = append(row, c == TextBlocked)
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+			tm.W = max(tm.W, int32(len(row)))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	tm.H = int32(len(rows))
+	if tm.W == 0 {
+		return nil, fmt.Errorf("%w: empty text map", ErrFormat)
+	}
+
+	tm.Map = NewLocal((tm.W+g16-1)/g16, (tm.H+g16-1)/g16)
+	for i := int32(0); i < tm.Map.Nx; i++ {
+		for j := int32(0); j < tm.Map.Ny; j++ {
+			tm.Map.SetGrid(i, j, new(Grid))
+		}
+	}
+	for y := int32(0); y < tm.Map.Ny*g16; y++ {
+		for x := int32(0); x < tm.Map.Nx*g16; x++ {
+			if y >= tm.H || x >= int32(len(rows[y])) || rows[y][x] {
+				tm.Map.Set(x, y)
+			}
+		}
+	}
+	return &tm, nil
+}
+
+// MustParseText parses s like ParseText and panics on error. It is meant
+// for maps written inline in tests.
+func MustParseText(s string) *TextMap {
+	tm, err := ParseText(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return tm
+}
+
+// FormatText prints the w by h cells at the top left of m. If path is not
+// empty its cells are overlaid with '*', the first one as 'S' and the last
+// one as 'G'. Consecutive path points may be apart, like the jump points
+// returned by the solvers; the cells between them are filled in.
+func FormatText(m Walkable, w, h int32, layout TextLayout, path []PathGrid) string {
+	marks := make(map[PathGrid]byte)
+	for i := 1; i < len(path); i++ {
+		line(path[i-1], path[i], layout, func(p PathGrid) {
+			marks[p] = TextPath
+		})
+	}
+	if len(path) > 0 {
+		marks[path[0]] = TextStart
+		marks[path[len(path)-1]] = TextGoal
+	}
+
+	var sb strings.Builder
+	for y := int32(0); y < h; y++ {
+		if layout == TextHex && y&1 == 1 {
+			sb.WriteByte(' ')
+		}
+		for x := int32(0); x < w; x++ {
+			if layout == TextHex && x > 0 {
+				sb.WriteByte(' ')
+			}
+			c, ok := marks[PathGrid{X: x, Y: y}]
+			switch {
+			case ok:
+			case m.Available(x, y):
+				c = TextFree
+			default:
+				c = TextBlocked
+			}
+			sb.WriteByte(c)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// String prints the parsed area of the map with its start and goal marks.
+func (tm *TextMap) String() string {
+	s := []byte(FormatText(tm.Map, tm.W, tm.H, TextSquare, nil))
+	mark := func(p PathGrid, c byte) {
+		s[p.Y*(tm.W+1)+p.X] = c
+	}
+	if tm.HasStart {
+		mark(tm.Start, TextStart)
+	}
+	if tm.HasGoal {
+		mark(tm.Goal, TextGoal)
+	}
+	return string(s)
+}
+
+// line calls f for every cell from a to b. Square lines step diagonally
+// first, hex lines are interpolated in cube coordinates.
+func line(a, b PathGrid, layout TextLayout, f func(p PathGrid)) {
+	if layout == TextSquare {
+		for {
+			f(a)
+			if a == b {
+				return
+			}
+			a.X += sign(b.X - a.X)
+			a.Y += sign(b.Y - a.Y)
+		}
+	}
+	aq, ar := oddRToAxial(a)
+	bq, br := oddRToAxial(b)
+	n := (abs32(aq-bq) + abs32(ar-br) + abs32(aq+ar-bq-br)) / 2
+	for i := int32(0); i <= n; i++ {
+		t := 0.0
+		if n > 0 {
+			t = float64(i) / float64(n)
+		}
+		// nudge off exact ties so the rounding is stable
+		q := float64(aq) + float64(bq-aq)*t + 1e-6
+		r := float64(ar) + float64(br-ar)*t + 1e-6
+		f(axialToOddR(cubeRound(q, r)))
+	}
+}
+
+func oddRToAxial(p PathGrid) (q, r int32) {
+	return p.X - (p.Y-p.Y&1)/2, p.Y
+}
+
+func axialToOddR(q, r int32) PathGrid {
+	return PathGrid{X: q + (r-r&1)/2, Y: r}
+}
+
+func cubeRound(q, r float64) (int32, int32) {
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+	return int32(rq), int32(rr)
+}
+
+func sign(v int32) int32 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
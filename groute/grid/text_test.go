@@ -0,0 +1,77 @@
+package grid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseText(t *testing.T) {
+	tm := MustParseText(`
+S..#
+.#.
+...#G
+`)
+	assert.Equal(t, int32(5), tm.W)
+	assert.Equal(t, int32(3), tm.H)
+	assert.Equal(t, int32(1), tm.Map.Nx)
+	assert.Equal(t, int32(1), tm.Map.Ny)
+	assert.True(t, tm.HasStart)
+	assert.True(t, tm.HasGoal)
+	assert.Equal(t, PathGrid{X: 0, Y: 0}, tm.Start)
+	assert.Equal(t, PathGrid{X: 4, Y: 2}, tm.Goal)
+
+	assert.True(t, tm.Map.Available(1, 0))
+	assert.False(t, tm.Map.Available(3, 0))
+	assert.False(t, tm.Map.Available(1, 1))
+	assert.False(t, tm.Map.Available(3, 1)) // 短行补齐为障碍
+	assert.True(t, tm.Map.Available(4, 2))
+	assert.False(t, tm.Map.Available(0, 3)) // 分块补齐为障碍
+	assert.Equal(t, "S..##\n.#.##\n...#G\n", tm.String())
+}
+
+func TestParseText_Errors(t *testing.T) {
+	for _, s := range []string{"", "\n\n", "..x", "S.S", "G\nG"} {
+		_, err := ParseText(strings.NewReader(s))
+		assert.ErrorIs(t, err, ErrFormat, "%q", s)
+	}
+}
+
+func TestFormatText_Square(t *testing.T) {
+	tm := MustParseText(`
+......
+..##..
+......
+`)
+	path := []PathGrid{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 4, Y: 0}, {X: 5, Y: 1}, {X: 5, Y: 2}}
+	want := `S****.
+..##.*
+.....G
+`
+	got := FormatText(tm.Map, tm.W, tm.H, TextSquare, path)
+	assert.Equal(t, want, got)
+
+	// 输出可以重新解析
+	back, err := ParseText(strings.NewReader(got))
+	require.NoError(t, err)
+	assert.Equal(t, tm.Start, back.Start)
+	assert.Equal(t, PathGrid{X: 5, Y: 2}, back.Goal)
+	assert.Equal(t, FormatText(tm.Map, tm.W, tm.H, TextSquare, nil), FormatText(back.Map, tm.W, tm.H, TextSquare, nil))
+}
+
+func TestFormatText_Hex(t *testing.T) {
+	tm := MustParseText(`
+. . . .
+ . # . .
+. . . .
+`)
+	// (0,0) -> (1,2) 是沿右下方向的直线，经过 (0,1)
+	path := []PathGrid{{X: 0, Y: 0}, {X: 1, Y: 2}, {X: 3, Y: 2}}
+	want := `S . . .
+ * # . .
+. * * G
+`
+	assert.Equal(t, want, FormatText(tm.Map, tm.W, tm.H, TextHex, path))
+}
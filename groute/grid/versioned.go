@@ -0,0 +1,157 @@
+package grid
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Snapshot is an immutable version of a Versioned map. Readers pin it with
+// Versioned.Acquire and must call Release when their search is done.
+type Snapshot struct {
+	// Map must not be modified.
+	Map     *Local
+	Version uint64
+	// Changed lists the blocks that differ from the previous version.
+	Changed []Gpos
+
+	refs  atomic.Int32
+	owner *Versioned
+}
+
+// Release unpins the snapshot. It must be called exactly once per Acquire.
+func (s *Snapshot) Release() {
+	if s.refs.Add(-1) == 0 {
+		s.owner.live.Add(-1)
+	}
+}
+
+// Versioned is a map edited by copy-on-write so that searches running on
+// other goroutines never see a partial edit.
+//
+// Every Update publishes a new Snapshot that shares unchanged 16x16 blocks
+// with the previous one and holds copies of the touched blocks only. A
+// snapshot is retired once it is no longer current and its last reader
+// released it.
+type Versioned struct {
+	mu   sync.Mutex // serialises writers
+	cur  atomic.Pointer[Snapshot]
+	live atomic.Int32
+}
+
+// NewVersioned takes ownership of m as version 1. m must not be modified
+// afterwards.
+func NewVersioned(m *Local) *Versioned {
+	v := &Versioned{}
+	v.publish(&Snapshot{Map: m, Version: 1, owner: v})
+	return v
+}
+
+// Acquire pins the current snapshot.
+func (v *Versioned) Acquire() *Snapshot {
+	for {
+		s := v.cur.Load()
+		r := s.refs.Load()
+		// a snapshot with no references is retired, reload the current one
+		if r > 0 && s.refs.CompareAndSwap(r, r+1) {
+			return s
+		}
+	}
+}
+
+// Version returns the current version number.
+func (v *Versioned) Version() uint64 {
+	return v.cur.Load().Version
+}
+
+// Live returns the number of snapshots not retired yet, including the
+// current one.
+func (v *Versioned) Live() int {
+	return int(v.live.Load())
+}
+
+// Update runs f on a transaction over the current version and publishes the
+// result as a new version, which it returns. If f changes nothing, no
+// version is published and the current snapshot is returned.
+func (v *Versioned) Update(f func(tx *Tx)) *Snapshot {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	old := v.cur.Load()
+	tx := &Tx{base: old.Map, touched: make(map[Gpos]struct{})}
+	f(tx)
+	if tx.m == nil {
+		return old
+	}
+	s := &Snapshot{Map: tx.m, Version: old.Version + 1, owner: v}
+	for p := range tx.touched {
+		s.Changed = append(s.Changed, p)
+	}
+	v.publish(s)
+	return s
+}
+
+func (v *Versioned) publish(s *Snapshot) {
+	s.refs.Store(1) // held by Versioned while current
+	v.live.Add(1)
+	old := v.cur.Swap(s)
+	if old != nil {
+		old.Release()
+	}
+}
+
+// Tx collects the edits of one Update.
+type Tx struct {
+	base    *Local
+	m       *Local // copy, created on the first change
+	cols    []bool // columns of m.Grids already copied
+	touched map[Gpos]struct{}
+}
+
+// Available reports whether cell (x, y) is available in the edited map.
+func (tx *Tx) Available(x, y int32) bool {
+	if tx.m != nil {
+		return tx.m.Available(x, y)
+	}
+	return tx.base.Available(x, y)
+}
+
+// Bounds returns the map bounds.
+func (tx *Tx) Bounds() Rect {
+	return tx.base.Bounds()
+}
+
+// Set marks cell (x, y) as blocked or free. Cells outside the map are
+// ignored.
+func (tx *Tx) Set(x, y int32, blocked bool) {
+	if !tx.base.Bounds().Contains(x, y) || tx.Available(x, y) != blocked {
+		return
+	}
+	g := tx.block(x/g16, y/g16)
+	if blocked {
+		g.Bits[y%g16] |= 1 << (x % g16)
+	} else {
+		g.Bits[y%g16] &^= 1 << (x % g16)
+	}
+}
+
+// block returns a private copy of block (bx, by).
+func (tx *Tx) block(bx, by int32) *Grid {
+	if tx.m == nil {
+		tx.m = &Local{Grids: make([][]*Grid, tx.base.Nx), Nx: tx.base.Nx, Ny: tx.base.Ny}
+		copy(tx.m.Grids, tx.base.Grids)
+		tx.cols = make([]bool, tx.base.Nx)
+	}
+	if !tx.cols[bx] {
+		tx.m.Grids[bx] = append([]*Grid(nil), tx.base.Grids[bx]...)
+		tx.cols[bx] = true
+	}
+	p := Gpos{X: bx, Y: by}
+	if _, ok := tx.touched[p]; !ok {
+		g := new(Grid)
+		if b := tx.base.Grids[bx][by]; b != nil {
+			*g = *b
+		}
+		tx.m.Grids[bx][by] = g
+		tx.touched[p] = struct{}{}
+	}
+	return tx.m.Grids[bx][by]
+}
@@ -0,0 +1,53 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersioned_CopyOnWrite(t *testing.T) {
+	tm := MustParseText(`
+................................
+................................
+`)
+	v := NewVersioned(tm.Map)
+	assert.Equal(t, uint64(1), v.Version())
+	assert.Equal(t, 1, v.Live())
+
+	old := v.Acquire()
+	s := v.Update(func(tx *Tx) {
+		tx.Set(3, 1, true)
+		tx.Set(4, 1, true)
+		tx.Set(-1, 0, true) // 越界忽略
+		assert.False(t, tx.Available(3, 1))
+	})
+	assert.Equal(t, uint64(2), s.Version)
+	assert.Equal(t, uint64(2), v.Version())
+	assert.Equal(t, []Gpos{{X: 0, Y: 0}}, s.Changed)
+
+	// 旧版本不受影响，未改动的块共享
+	assert.True(t, old.Map.Available(3, 1))
+	assert.False(t, s.Map.Available(3, 1))
+	assert.NotSame(t, old.Map.Grids[0][0], s.Map.Grids[0][0])
+	assert.Same(t, old.Map.Grids[1][0], s.Map.Grids[1][0])
+
+	// 没有改动时不产生新版本
+	same := v.Update(func(tx *Tx) { tx.Set(3, 1, true) })
+	assert.Same(t, s, same)
+
+	// 旧版本在读者释放后退役
+	assert.Equal(t, 2, v.Live())
+	old.Release()
+	assert.Equal(t, 1, v.Live())
+
+	cur := v.Acquire()
+	assert.Same(t, s, cur)
+	v.Update(func(tx *Tx) { tx.Set(3, 1, false) })
+	assert.Equal(t, 2, v.Live())
+	assert.False(t, cur.Map.Available(3, 1))
+	cur.Release()
+	assert.Equal(t, 1, v.Live())
+	require.True(t, v.Acquire().Map.Available(3, 1))
+}
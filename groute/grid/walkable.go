@@ -0,0 +1,29 @@
+package grid
+
+// Rect is the cell rectangle [MinX, MaxX) x [MinY, MaxY).
+type Rect struct {
+	MinX, MinY, MaxX, MaxY int32
+}
+
+// Contains reports whether cell (x, y) lies inside r.
+func (r Rect) Contains(x, y int32) bool {
+	return x >= r.MinX && x < r.MaxX && y >= r.MinY && y < r.MaxY
+}
+
+// Walkable is the map view searched by the solvers.
+type Walkable interface {
+	// Available reports whether cell (x, y) is inside Bounds and not blocked.
+	Available(x, y int32) bool
+	// Bounds returns the rectangle outside of which no cell is available.
+	Bounds() Rect
+}
+
+var (
+	_ Walkable = (*Local)(nil)
+	_ Walkable = (*Overlay)(nil)
+)
+
+// Bounds returns the cells covered by the map blocks.
+func (w *Local) Bounds() Rect {
+	return Rect{MaxX: w.Nx * g16, MaxY: w.Ny * g16}
+}
@@ -0,0 +1,269 @@
+package grid
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ChunkPolicy decides how cells of unavailable chunks are seen.
+type ChunkPolicy uint8
+
+const (
+	// ChunkBlocked treats cells of unavailable chunks as blocked.
+	ChunkBlocked ChunkPolicy = iota
+	// ChunkUnknown treats cells of unavailable chunks as free, e.g. to plan
+	// optimistically through terrain that is not streamed in yet.
+	ChunkUnknown
+)
+
+// ChunkLoader returns block (bx, by) of a World. ok == false means the
+// block is unavailable right now; the World remembers that like a loaded
+// block and applies its policy until the entry is evicted or World.Retry
+// drops it. A nil block with ok == true is an
+// empty, fully free block. The World owns returned blocks and modifies them
+// in Set. The loader runs under the World's lock and must not call back into
+// the World.
+type ChunkLoader func(bx, by int32) (g *Grid, ok bool)
+
+// WorldOptions configures a World.
+type WorldOptions struct {
+	// Bounds limits the world in cells. The zero value means MaxWorldBounds
+	// and is not allowed with ChunkUnknown: solvers jump through free cells
+	// up to the border, so keep it tight.
+	Bounds Rect
+	// Capacity is the number of cached blocks, loaded or unavailable, 0
+	// means 1024. The least recently used block is unloaded first.
+	Capacity int
+	// Policy applies to blocks the loader cannot provide.
+	Policy ChunkPolicy
+	// Loader loads blocks on first access.
+	Loader ChunkLoader
+	// Unload, if set, is called with every block leaving the cache, under
+	// the same rules as Loader. dirty tells that the block was modified by
+	// World.Set.
+	Unload func(bx, by int32, g *Grid, dirty bool)
+}
+
+// MaxWorldBounds is the extent of a World without explicit bounds.
+var MaxWorldBounds = Rect{MinX: -1 << 24, MinY: -1 << 24, MaxX: 1 << 24, MaxY: 1 << 24}
+
+// WorldStats counts chunk cache activity.
+type WorldStats struct {
+	Resident  int // loaded blocks, without the cached unavailable ones
+	Loads     int64
+	Misses    int64 // loads that returned unavailable
+	Evictions int64
+}
+
+// World is a chunk-streamed map of unbounded size. Blocks are loaded on
+// demand through the loader and kept in an LRU cache, coordinates may be
+// negative. A World is safe for concurrent use.
+type World struct {
+	mu     sync.Mutex
+	opt    WorldOptions
+	chunks map[Gpos]*list.Element
+	lru    list.List // front is most recently used
+	stats  WorldStats
+	absent int // cached unavailable blocks
+}
+
+type chunk struct {
+	pos    Gpos
+	g      *Grid
+	dirty  bool
+	absent bool // the loader reported the block unavailable
+}
+
+var (
+	_ Walkable  = (*World)(nil)
+	_ RowReader = (*World)(nil)
+)
+
+// NewWorld creates an empty world. It panics if opt has ChunkUnknown without
+// Bounds.
+func NewWorld(opt WorldOptions) *World {
+	if opt.Bounds == (Rect{}) {
+		if opt.Policy == ChunkUnknown {
+			panic("grid: ChunkUnknown World without Bounds")
+		}
+		opt.Bounds = MaxWorldBounds
+	}
+	if opt.Capacity <= 0 {
+		opt.Capacity = 1024
+	}
+	return &World{opt: opt, chunks: make(map[Gpos]*list.Element)}
+}
+
+// Bounds implements Walkable.
+func (w *World) Bounds() Rect {
+	return w.opt.Bounds
+}
+
+// Available implements Walkable.
+func (w *World) Available(x, y int32) bool {
+	if !w.opt.Bounds.Contains(x, y) {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	g, ok := w.block(x>>4, y>>4)
+	if !ok {
+		return w.opt.Policy == ChunkUnknown
+	}
+	return g == nil || g.Bits[y&(g16-1)]&(1<<(x&(g16-1))) == 0
+}
+
+// BlockRow implements RowReader.
+func (w *World) BlockRow(bx, y int32) uint16 {
+	b := w.opt.Bounds
+	if y < b.MinY || y >= b.MaxY {
+		return 0xffff
+	}
+	var outside uint16
+	for i := int32(0); i < g16; i++ {
+		if x := bx*g16 + i; x < b.MinX || x >= b.MaxX {
+			outside |= 1 << i
+		}
+	}
+	if outside == 0xffff {
+		return outside
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	g, ok := w.block(bx, y>>4)
+	switch {
+	case !ok && w.opt.Policy == ChunkUnknown, ok && g == nil:
+		return outside
+	case !ok:
+		return 0xffff
+	}
+	return g.Bits[y&(g16-1)] | outside
+}
+
+// Set marks cell (x, y) as blocked or free. The block is loaded first, an
+// unavailable block is created empty. Set reports false outside Bounds.
+func (w *World) Set(x, y int32, blocked bool) bool {
+	if !w.opt.Bounds.Contains(x, y) {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pos := Gpos{X: x >> 4, Y: y >> 4}
+	w.block(pos.X, pos.Y)
+	e := w.chunks[pos]
+	if e == nil {
+		e = w.insert(pos, nil)
+	}
+	c := e.Value.(*chunk)
+	if c.absent {
+		c.absent = false
+		w.absent--
+	}
+	if c.g == nil {
+		c.g = new(Grid)
+	}
+	if blocked {
+		c.g.Bits[y&(g16-1)] |= 1 << (x & (g16 - 1))
+	} else {
+		c.g.Bits[y&(g16-1)] &^= 1 << (x & (g16 - 1))
+	}
+	c.dirty = true
+	return true
+}
+
+// Preload loads every block overlapping the cell rectangle r.
+func (w *World) Preload(r Rect) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for bx := r.MinX >> 4; bx <= (r.MaxX-1)>>4; bx++ {
+		for by := r.MinY >> 4; by <= (r.MaxY-1)>>4; by++ {
+			w.block(bx, by)
+		}
+	}
+}
+
+// Retry forgets that the blocks overlapping the cell rectangle r were
+// unavailable, so the next access asks the loader again.
+func (w *World) Retry(r Rect) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for bx := r.MinX >> 4; bx <= (r.MaxX-1)>>4; bx++ {
+		for by := r.MinY >> 4; by <= (r.MaxY-1)>>4; by++ {
+			if e := w.chunks[Gpos{X: bx, Y: by}]; e != nil && e.Value.(*chunk).absent {
+				w.remove(e)
+			}
+		}
+	}
+}
+
+// Unload drops every resident block, calling WorldOptions.Unload.
+func (w *World) Unload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.lru.Len() > 0 {
+		w.evict()
+	}
+}
+
+// Stats returns cache counters.
+func (w *World) Stats() WorldStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.stats
+	s.Resident = w.lru.Len() - w.absent
+	return s
+}
+
+// block returns resident block (bx, by), loading it if needed.
+func (w *World) block(bx, by int32) (*Grid, bool) {
+	pos := Gpos{X: bx, Y: by}
+	if e := w.chunks[pos]; e != nil {
+		w.lru.MoveToFront(e)
+		c := e.Value.(*chunk)
+		return c.g, !c.absent
+	}
+	if w.opt.Loader == nil {
+		return nil, false
+	}
+	g, ok := w.opt.Loader(bx, by)
+	if !ok {
+		w.stats.Misses++
+		w.insert(pos, nil).Value.(*chunk).absent = true
+		w.absent++
+		return nil, false
+	}
+	w.stats.Loads++
+	w.insert(pos, g)
+	return g, true
+}
+
+func (w *World) insert(pos Gpos, g *Grid) *list.Element {
+	for w.lru.Len() >= w.opt.Capacity {
+		w.evict()
+	}
+	e := w.lru.PushFront(&chunk{pos: pos, g: g})
+	w.chunks[pos] = e
+	return e
+}
+
+func (w *World) evict() {
+	e := w.lru.Back()
+	c := e.Value.(*chunk)
+	w.remove(e)
+	if c.absent {
+		return
+	}
+	w.stats.Evictions++
+	if w.opt.Unload != nil {
+		w.opt.Unload(c.pos.X, c.pos.Y, c.g, c.dirty)
+	}
+}
+
+func (w *World) remove(e *list.Element) {
+	c := e.Value.(*chunk)
+	w.lru.Remove(e)
+	delete(w.chunks, c.pos)
+	if c.absent {
+		w.absent--
+	}
+}
@@ -0,0 +1,114 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 程序化生成的块：x+y 能被 5 整除的格子为障碍，块 (1,1) 不可用
+func genLoader(loads map[Gpos]int) ChunkLoader {
+	return func(bx, by int32) (*Grid, bool) {
+		loads[Gpos{X: bx, Y: by}]++
+		if bx == 1 && by == 1 {
+			return nil, false
+		}
+		g := new(Grid)
+		for iy := int32(0); iy < 16; iy++ {
+			for ix := int32(0); ix < 16; ix++ {
+				if x, y := bx*16+ix, by*16+iy; (x+y)%5 == 0 {
+					g.Bits[iy] |= 1 << ix
+				}
+			}
+		}
+		return g, true
+	}
+}
+
+func TestWorld_Load(t *testing.T) {
+	loads := make(map[Gpos]int)
+	w := NewWorld(WorldOptions{Loader: genLoader(loads)})
+	assert.Equal(t, MaxWorldBounds, w.Bounds())
+
+	assert.False(t, w.Available(-5, 0))
+	assert.True(t, w.Available(-6, 0))
+	assert.False(t, w.Available(-16, -4))
+	assert.True(t, w.Available(-17, -4))
+	assert.Equal(t, 1, loads[Gpos{X: -1, Y: 0}])
+	assert.Equal(t, 1, loads[Gpos{X: -1, Y: -1}])
+	assert.Equal(t, 1, loads[Gpos{X: -2, Y: -1}])
+
+	w.Available(-7, 1) // 已加载的块不再加载
+	assert.Equal(t, 1, loads[Gpos{X: -1, Y: 0}])
+
+	// 不可用的块按策略视为障碍，结果同样缓存，Retry 后重新加载
+	assert.False(t, w.Available(20, 20))
+	assert.False(t, w.Available(21, 20))
+	assert.Equal(t, 0xffff, int(w.BlockRow(1, 20)))
+	assert.Equal(t, 1, loads[Gpos{X: 1, Y: 1}])
+
+	s := w.Stats()
+	assert.Equal(t, 3, s.Resident)
+	assert.Equal(t, int64(3), s.Loads)
+	assert.Equal(t, int64(1), s.Misses)
+
+	w.Retry(Rect{MinX: -32, MinY: -32, MaxX: 32, MaxY: 32})
+	assert.Equal(t, 3, w.Stats().Resident, "loaded blocks are kept")
+	assert.False(t, w.Available(20, 20))
+	assert.Equal(t, 2, loads[Gpos{X: 1, Y: 1}])
+
+	// 写入不可用的块时创建空块
+	assert.True(t, w.Set(20, 21, true))
+	assert.True(t, w.Available(20, 20))
+	assert.False(t, w.Available(20, 21))
+	assert.Equal(t, 4, w.Stats().Resident)
+
+	assert.Panics(t, func() { NewWorld(WorldOptions{Loader: genLoader(loads), Policy: ChunkUnknown}) })
+	u := NewWorld(WorldOptions{Bounds: Rect{MinX: -64, MinY: -64, MaxX: 64, MaxY: 64},
+		Loader: genLoader(loads), Policy: ChunkUnknown})
+	assert.True(t, u.Available(20, 20))
+	assert.False(t, u.Available(64, 0))
+}
+
+func TestWorld_LRU(t *testing.T) {
+	type unload struct {
+		pos   Gpos
+		dirty bool
+	}
+	var unloaded []unload
+	w := NewWorld(WorldOptions{
+		Bounds:   Rect{MinX: -64, MinY: -64, MaxX: 64, MaxY: 64},
+		Capacity: 2,
+		Loader:   func(bx, by int32) (*Grid, bool) { return nil, true },
+		Unload: func(bx, by int32, g *Grid, dirty bool) {
+			unloaded = append(unloaded, unload{Gpos{X: bx, Y: by}, dirty})
+		},
+	})
+	assert.True(t, w.Available(0, 0))
+	assert.True(t, w.Set(-1, 0, true))
+	assert.False(t, w.Available(-1, 0))
+	assert.True(t, w.Available(1, 1)) // (0,0) 成为最近使用
+	assert.True(t, w.Available(0, 16))
+	assert.Equal(t, []unload{{Gpos{X: -1, Y: 0}, true}}, unloaded)
+
+	assert.False(t, w.Set(64, 0, true))
+	assert.False(t, w.Available(-65, 0))
+
+	w.Unload()
+	assert.Len(t, unloaded, 3)
+	assert.Equal(t, 0, w.Stats().Resident)
+	assert.Equal(t, int64(3), w.Stats().Evictions)
+	assert.True(t, w.Available(-1, 0), "edit lost after unload, the loader owns persistence")
+}
+
+func TestWorld_BlockRow(t *testing.T) {
+	for _, policy := range []ChunkPolicy{ChunkBlocked, ChunkUnknown} {
+		w := NewWorld(WorldOptions{
+			Bounds: Rect{MinX: -21, MinY: -19, MaxX: 37, MaxY: 40},
+			Policy: policy,
+			Loader: genLoader(make(map[Gpos]int)),
+		})
+		w.Set(3, 3, true)
+		checkRows(t, w, w)
+	}
+}
@@ -2,9 +2,7 @@ package hex
 
 import (
 	"github.com/legamerdc/pathfinding/groute/grid"
-	"github.com/legamerdc/pathfinding/utils/heap"
-
-	"slices"
+	"github.com/legamerdc/pathfinding/groute/jps"
 )
 
 /*
@@ -14,181 +12,183 @@ import (
 	  2s  1
 */
 
-type dirSet uint32
+const _fullDirSet jps.DirSet = (1 << 6) - 1
 
-const (
-	_fullDirSetdirSet dirSet = (1 << 6) - 1
-	_emptyDirSet      dirSet = 0
+type WorkSpace struct {
+	Map grid.Walkable
 
-	_noDir = 0xff
-)
+	topo   topology
+	search *jps.Search
+}
 
-func (s *dirSet) dirAdd(d int32) {
-	*s |= 1 << d
+// NewWorkSpace creates a reusable hex-grid search workspace.
+func NewWorkSpace(size int) *WorkSpace {
+	ws := &WorkSpace{}
+	ws.search = jps.NewSearch(&ws.topo, size)
+	return ws
 }
 
-func (s *dirSet) dirIter(f func(d int32) bool) {
-	for d := int32(0); d < 6; d++ {
-		if (*s)&(1<<d) != 0 {
-			if !f(d) {
-				return
-			}
-		}
-	}
+// Reset binds the workspace to a map before running Solve.
+func (ws *WorkSpace) Reset(m grid.Walkable) {
+	ws.Map = m
+	ws.topo.reset(m)
 }
 
-type WorkSpace struct {
-	Map *grid.Local
+// SetQueue selects the open list implementation used by Solve.
+func (ws *WorkSpace) SetQueue(k jps.QueueKind) {
+	ws.search.SetQueue(k)
+}
 
-	pool       *grid.NodePool
-	heap       *heap.Heap[*grid.Gnode]
-	endX, endY int32
+// SetBidirectional switches Solve to bidirectional A*, see
+// jps.Search.SetBidirectional. It expands fewer nodes than a one-sided search
+// on large open maps and fails fast when the start or goal is enclosed.
+func (ws *WorkSpace) SetBidirectional(on bool) {
+	ws.search.SetBidirectional(on)
 }
 
-// NewWorkSpace creates a reusable hex-grid search workspace.
-func NewWorkSpace(size int) *WorkSpace {
-	return &WorkSpace{
-		pool: grid.NewNodePool(int32(size)),
-		heap: heap.NewHeap[*grid.Gnode](size),
+// SetWeight sets the heuristic weight of Solve for bounded suboptimal
+// search, see jps.Search.SetWeight.
+func (ws *WorkSpace) SetWeight(w float64) {
+	ws.search.SetWeight(w)
+}
+
+// Anytime starts an ARA* search that improves its path on every Step, see
+// jps.Search.Anytime. The map must not change until the search is dropped.
+func (ws *WorkSpace) Anytime(sx, sy, ex, ey int32, w, delta float64) *jps.Anytime {
+	ws.topo.rows.Invalidate()
+	return ws.search.Anytime(sx, sy, ex, ey, w, delta)
+}
+
+// BuildLandmarks preprocesses the current map for the ALT heuristic with k
+// landmarks, see jps.BuildLandmarks.
+func (ws *WorkSpace) BuildLandmarks(k int) *jps.Landmarks {
+	ws.topo.rows.Invalidate()
+	return jps.BuildLandmarks(&ws.topo, ws.Map, k)
+}
+
+// SetLandmarks makes Solve use the ALT heuristic of l on top of the hex
+// distance, nil removes it. It reports false and changes nothing if l was
+// built for another topology.
+func (ws *WorkSpace) SetLandmarks(l *jps.Landmarks) bool {
+	if l == nil {
+		ws.search.SetHeuristic(nil)
+		return true
+	}
+	if l.Unit != ws.topo.Dist(0, 0, 1, 0) {
+		return false
 	}
+	ws.search.SetHeuristic(l.Heuristic(ws.topo.Dist))
+	return true
 }
 
-// Reset binds the workspace to a map before running Solve.
-func (ws *WorkSpace) Reset(m *grid.Local) {
-	ws.Map = m
+// SetTracer attaches a tracer that observes every following Solve. nil
+// disables tracing.
+func (ws *WorkSpace) SetTracer(t jps.Tracer) {
+	ws.search.SetTracer(t)
 }
 
 // Solve searches a path on the hex grid from start to end cell coordinates.
 func (ws *WorkSpace) Solve(sx, sy, ex, ey int32) (p []grid.PathGrid, ok bool) {
-	ws.pool.Clear()
-	ws.heap.Clear()
-	ws.endX, ws.endY = ex, ey
-	ws.putInOpenSet(sx, sy, _noDir, sx, sy, 0)
-	for {
-		x, y, d, c, ok1 := ws.getOutOpenSet()
-		if !ok1 {
-			break
-		}
-		if x == ex && y == ey {
-			return ws.path(sx, sy)
-		}
-		set := ws.naturalDir(d) | ws.forceDir(x, y, d)
-		set.dirIter(func(nd int32) bool {
-			return !ws.jump(x, y, x, y, nd, c)
-		})
-	}
-	return nil, false
+	ws.topo.rows.Invalidate()
+	return ws.search.Solve(sx, sy, ex, ey)
 }
 
-func (ws *WorkSpace) jump(x, y, fx, fy, d, c int32) bool {
-	for {
-		x, y = Move(x, y, d)
-		if !ws.Map.Available(x, y) {
-			return false
-		}
-		if x == ws.endX && y == ws.endY {
-			ws.putInOpenSet(x, y, d, fx, fy, c)
-			return true
-		}
-		if ws.forceDir(x, y, d) > 0 {
-			ws.putInOpenSet(x, y, d, fx, fy, c)
-			return false
-		}
-		if spread(d) {
-			if ws.jump(x, y, fx, fy, (d+1)%6, c) || ws.jump(x, y, fx, fy, (d+5)%6, c) {
-				return true
-			}
-		}
-	}
+// Exhausted reports whether the last Solve ran out of nodes, see
+// jps.Search.Exhausted.
+func (ws *WorkSpace) Exhausted() bool {
+	return ws.search.Exhausted()
 }
 
-func (ws *WorkSpace) getOutOpenSet() (x, y, d, c int32, ok bool) {
-	if ws.heap.Empty() {
-		return
-	}
-	node := ws.heap.Pop()
-	node.Status = grid.NodeClose
-	return node.Pos.X, node.Pos.Y, node.Dir, node.Cost, true
+// PathCost returns the number of steps of the path found by the last
+// successful Solve.
+func (ws *WorkSpace) PathCost() float64 {
+	return float64(ws.search.Cost())
 }
 
-func (ws *WorkSpace) putInOpenSet(x, y, d, fx, fy, c int32) {
-	node := ws.pool.GetNode(x, y)
-	if node == nil {
-		return
-	}
-	switch node.Status {
-	case grid.NodeNew:
-		node.FPos = grid.Gpos{X: fx, Y: fy}
-		node.Dir = d
-		node.Cost = c + dist(x, y, fx, fy)
-		node.Total = node.Cost + dist(x, y, ws.endX, ws.endY)
-		node.Status = grid.NodeOpen
-		ws.heap.Push(node)
-	case grid.NodeOpen:
-		cost := c + dist(x, y, fx, fy)
-		if cost < node.Cost {
-			node.FPos = grid.Gpos{X: fx, Y: fy}
-			node.Dir = d
-			node.Cost = cost
-			node.Total = cost + dist(x, y, ws.endX, ws.endY)
-			ws.heap.Fix(node)
-		}
-	case grid.NodeClose:
-		return
-	}
+// topology implements the hex-grid jump rules for jps.Search.
+type topology struct {
+	m     grid.Walkable
+	local *grid.Local // m when it is a plain map, checked without dynamic dispatch
+	rows  grid.RowCache
+	// useRows tells that m implements grid.RowReader and is read through rows
+	useRows bool
 }
 
-func (ws *WorkSpace) naturalDir(curDir int32) (s dirSet) {
-	if curDir == _noDir {
-		return _fullDirSetdirSet
+// Step implements jps.Topology.
+func (t *topology) Step(x, y, d int32) (int32, int32, bool) {
+	x, y = Move(x, y, d)
+	return x, y, t.available(x, y)
+}
+
+// Natural implements jps.Topology.
+func (t *topology) Natural(_, _, curDir int32) (s jps.DirSet) {
+	if curDir == jps.NoDir {
+		return _fullDirSet
 	}
-	s.dirAdd(curDir)
+	s.Add(curDir)
 	if spread(curDir) {
-		s.dirAdd((curDir + 1) % 6)
-		s.dirAdd((curDir + 5) % 6)
+		s.Add((curDir + 1) % 6)
+		s.Add((curDir + 5) % 6)
 	}
 
 	return s
 }
 
-func (ws *WorkSpace) forceDir(x, y, curDir int32) (s dirSet) {
-	if curDir == _noDir || spread(curDir) {
-		return _emptyDirSet
+// Forced implements jps.Topology.
+func (t *topology) Forced(x, y, curDir int32) (s jps.DirSet) {
+	if curDir == jps.NoDir || spread(curDir) {
+		return 0
 	}
-	if !ws.walkable(x, y, curDir, 2) {
-		s.dirAdd((curDir + 1) % 6)
+	if !t.walkable(x, y, curDir, 2) {
+		s.Add((curDir + 1) % 6)
 	}
-	if !ws.walkable(x, y, curDir, 4) {
-		s.dirAdd((curDir + 5) % 6)
+	if !t.walkable(x, y, curDir, 4) {
+		s.Add((curDir + 5) % 6)
 	}
 	return s
 }
 
-func (ws *WorkSpace) walkable(x, y, curDir, nextDir int32) bool {
-	x, y = Move(x, y, (curDir+nextDir)%6)
-	return ws.Map.Available(x, y)
+// Spread implements jps.Topology.
+func (t *topology) Spread(d int32) (int32, int32, bool) {
+	if !spread(d) {
+		return 0, 0, false
+	}
+	return (d + 1) % 6, (d + 5) % 6, true
 }
 
-func (ws *WorkSpace) path(sx, sy int32) (p []grid.PathGrid, ok bool) {
-	var (
-		fx, fy int32
-		x, y   = ws.endX, ws.endY
-		node   *grid.Gnode
-	)
-	for !(x == sx && y == sy) {
-		p = append(p, grid.PathGrid{X: x, Y: y})
-		if node = ws.pool.FindNode(x, y); node == nil {
-			return
-		}
-		fx, fy = node.FPos.X, node.FPos.Y
-		if mx, my, ok1 := midPoint(x, y, fx, fy); ok1 {
-			p = append(p, grid.PathGrid{X: mx, Y: my})
-		}
-		x, y = fx, fy
+// Dist implements jps.Topology.
+func (t *topology) Dist(x1, y1, x2, y2 int32) int32 {
+	return dist(x1, y1, x2, y2)
+}
+
+// MidPoint implements jps.Topology.
+func (t *topology) MidPoint(x, y, fx, fy int32) (int32, int32, bool) {
+	return midPoint(x, y, fx, fy)
+}
+
+func (t *topology) reset(m grid.Walkable) {
+	t.m = m
+	t.local, _ = m.(*grid.Local)
+	r, ok := m.(grid.RowReader)
+	t.useRows = ok && t.local == nil
+	if t.useRows {
+		t.rows.Reset(r)
 	}
-	p = append(p, grid.PathGrid{X: sx, Y: sy})
-	slices.Reverse(p)
-	return p, true
+}
+
+func (t *topology) available(x, y int32) bool {
+	switch {
+	case t.local != nil:
+		return t.local.Available(x, y)
+	case t.useRows:
+		return t.rows.Available(x, y)
+	}
+	return t.m.Available(x, y)
+}
+
+func (t *topology) walkable(x, y, curDir, nextDir int32) bool {
+	x, y = Move(x, y, (curDir+nextDir)%6)
+	return t.available(x, y)
 }
 
 // Move advances one step in direction d using this package's offset hex coordinates.
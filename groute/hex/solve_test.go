@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/jps"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,3 +24,188 @@ func TestTransform(t *testing.T) {
 func TestMidPoint(t *testing.T) {
 	fmt.Println(midPoint(3, 4, 0, 1))
 }
+
+func createHexMap(seed int64) *grid.Local {
+	rng := rand.New(rand.NewSource(seed))
+	m := grid.NewLocal(3, 3)
+	for i := int32(0); i < 3; i++ {
+		for j := int32(0); j < 3; j++ {
+			m.SetGrid(i, j, new(grid.Grid))
+		}
+	}
+	for i := int32(0); i < 48; i++ {
+		for j := int32(0); j < 48; j++ {
+			if (i == 0 && j == 0) || (i == 47 && j == 47) {
+				continue
+			}
+			if rng.Float32() < 0.25 {
+				m.Set(i, j)
+			}
+		}
+	}
+	return m
+}
+
+func pathCost(path []grid.PathGrid) int32 {
+	var c int32
+	for i := 1; i < len(path); i++ {
+		c += dist(path[i-1].X, path[i-1].Y, path[i].X, path[i].Y)
+	}
+	return c
+}
+
+func TestWorkSpace_QueueKinds(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		m := createHexMap(seed)
+		ws := NewWorkSpace(2304)
+		ws.Reset(m)
+		want, ok := ws.Solve(0, 0, 47, 47)
+		for _, kind := range []jps.QueueKind{jps.QueueQuadHeap, jps.QueueBucket} {
+			ws.SetQueue(kind)
+			got, ok1 := ws.Solve(0, 0, 47, 47)
+			assert.Equal(t, ok, ok1, "seed %d", seed)
+			assert.Equal(t, pathCost(want), pathCost(got), "seed %d", seed)
+		}
+	}
+}
+
+func TestWorkSpace_TextMap(t *testing.T) {
+	tm := grid.MustParseText(`
+S . . # . . . .
+ . . . # . . . .
+. . . # . # . .
+ . . . . . # . .
+. # # # # # . G
+ . . . . . . . .
+`)
+	ws := NewWorkSpace(400)
+	ws.Reset(tm.Map)
+	path, ok := ws.Solve(tm.Start.X, tm.Start.Y, tm.Goal.X, tm.Goal.Y)
+	assert.True(t, ok)
+	assert.Equal(t, 11.0, ws.PathCost())
+	assert.Equal(t, `S * . # . . . .
+ . * . # * * . .
+. . * # * # * .
+ . . * * . # * .
+. # # # # # . G
+ . . . . . . . .
+`, grid.FormatText(tm.Map, tm.W, tm.H, grid.TextHex, path))
+}
+
+func TestWorkSpace_Walkable(t *testing.T) {
+	const off = 40 // 偶数偏移保持 odd-r 的行奇偶性
+	for seed := int64(0); seed < 10; seed++ {
+		m := createHexMap(seed)
+		shifted := grid.FuncMap{
+			R: grid.Rect{MinX: -off, MinY: -off, MaxX: 48 - off, MaxY: 48 - off},
+			F: func(x, y int32) bool { return m.Available(x+off, y+off) },
+		}
+		ws := NewWorkSpace(2304)
+		ws.Reset(m)
+		want, ok := ws.Solve(0, 0, 47, 47)
+
+		ws.Reset(grid.NewOverlay(m, grid.NewLayer()))
+		got, ok1 := ws.Solve(0, 0, 47, 47)
+		assert.Equal(t, ok, ok1, "seed %d", seed)
+		assert.Equal(t, pathCost(want), pathCost(got), "seed %d", seed)
+
+		ws.Reset(shifted)
+		got, ok1 = ws.Solve(-off, -off, 47-off, 47-off)
+		assert.Equal(t, ok, ok1, "seed %d", seed)
+		assert.Equal(t, pathCost(want), pathCost(got), "seed %d", seed)
+	}
+}
+
+func TestWorkSpace_World(t *testing.T) {
+	// 块 (-1,0) 尚未加载：按策略视为障碍或可通行
+	bounds := grid.Rect{MinX: -32, MinY: -16, MaxX: 32, MaxY: 32}
+	loader := func(bx, by int32) (*grid.Grid, bool) {
+		return nil, bx != -1 || by != 0
+	}
+	for _, policy := range []grid.ChunkPolicy{grid.ChunkBlocked, grid.ChunkUnknown} {
+		world := grid.NewWorld(grid.WorldOptions{Bounds: bounds, Policy: policy, Loader: loader})
+		ref := grid.FuncMap{R: bounds, F: func(x, y int32) bool {
+			return policy == grid.ChunkUnknown || x>>4 != -1 || y>>4 != 0
+		}}
+		ws := NewWorkSpace(4096)
+		ws.Reset(ref)
+		want, ok := ws.Solve(-30, 8, 2, 8)
+		assert.True(t, ok)
+		ws.Reset(world)
+		got, ok := ws.Solve(-30, 8, 2, 8)
+		assert.True(t, ok)
+		assert.Equal(t, pathCost(want), pathCost(got), "policy %d", policy)
+		if policy == grid.ChunkUnknown {
+			assert.Equal(t, int32(32), pathCost(got))
+		} else {
+			assert.Greater(t, pathCost(got), int32(32))
+		}
+	}
+}
+
+func TestWorkSpace_Bidirectional(t *testing.T) {
+	ws := NewWorkSpace(2304)
+	bi := NewWorkSpace(2304)
+	bi.SetBidirectional(true)
+	for seed := int64(0); seed < 20; seed++ {
+		m := createHexMap(seed)
+		ws.Reset(m)
+		bi.Reset(m)
+		want, ok := ws.Solve(0, 0, 47, 47)
+		got, ok1 := bi.Solve(0, 0, 47, 47)
+		assert.Equal(t, ok, ok1, "seed %d", seed)
+		if !ok {
+			continue
+		}
+		assert.Equal(t, pathCost(want), pathCost(got), "seed %d", seed)
+		assert.Equal(t, ws.PathCost(), bi.PathCost(), "seed %d", seed)
+		assert.Equal(t, grid.PathGrid{}, got[0])
+		assert.Equal(t, grid.PathGrid{X: 47, Y: 47}, got[len(got)-1])
+	}
+}
+
+func TestWorkSpace_Anytime(t *testing.T) {
+	ws := NewWorkSpace(2304)
+	for seed := int64(0); seed < 20; seed++ {
+		m := createHexMap(seed)
+		ws.Reset(m)
+		want, ok := ws.Solve(0, 0, 47, 47)
+		ws.SetWeight(1.5)
+		weighted, _ := ws.Solve(0, 0, 47, 47)
+		ws.SetWeight(1)
+		if ok {
+			assert.LessOrEqual(t, float64(pathCost(weighted)), 1.5*float64(pathCost(want)), "seed %d", seed)
+		}
+
+		a := ws.Anytime(0, 0, 47, 47, 2, 0.25)
+		for i := 0; i < 10; i++ {
+			path, bound, ok1 := a.Step()
+			assert.Equal(t, ok, ok1, "seed %d", seed)
+			if !ok {
+				break
+			}
+			assert.LessOrEqual(t, float64(pathCost(path)), bound*float64(pathCost(want)), "seed %d", seed)
+			if bound == 1 {
+				assert.Equal(t, pathCost(want), a.Cost(), "seed %d", seed)
+				break
+			}
+		}
+	}
+}
+
+func TestWorkSpace_Landmarks(t *testing.T) {
+	ws := NewWorkSpace(2304)
+	for seed := int64(0); seed < 20; seed++ {
+		m := createHexMap(seed)
+		ws.Reset(m)
+		assert.True(t, ws.SetLandmarks(nil))
+		want, ok := ws.Solve(0, 0, 47, 47)
+		l := ws.BuildLandmarks(4)
+		assert.True(t, ws.SetLandmarks(l))
+		got, ok1 := ws.Solve(0, 0, 47, 47)
+		assert.Equal(t, ok, ok1, "seed %d", seed)
+		if ok {
+			assert.Equal(t, pathCost(want), pathCost(got), "seed %d", seed)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package jps
+
+import (
+	"math"
+	"slices"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// Anytime is an ARA* search: the first Step returns a weighted A* path
+// quickly, every further Step lowers the weight and improves the path,
+// reusing the work of the previous steps. It expands single steps of the
+// topology, so moves must be consistent with Dist.
+//
+// The map must not change while an Anytime search is in use.
+type Anytime struct {
+	s              *Search
+	sx, sy, ex, ey int32
+	w, delta       float64
+	bound          float64
+	started        bool
+
+	nodes  map[grid.Gpos]*araNode
+	open   *heap.Heap[*araNode]
+	closed []*araNode // closed in the current step
+	incons []*araNode // improved after being closed in the current step
+}
+
+type araNode struct {
+	pos, parent grid.Gpos
+	g, h        int32
+	key         float64
+	index       int32
+	closed      bool
+	incons      bool
+}
+
+// Compare implements heap.Node.
+func (n *araNode) Compare(o *araNode) int32 {
+	switch {
+	case n.key < o.key:
+		return -1
+	case n.key > o.key:
+		return 1
+	}
+	return 0
+}
+
+// GetHeapIndex implements heap.Node.
+func (n *araNode) GetHeapIndex() int32 { return n.index }
+
+// SetHeapIndex implements heap.Node.
+func (n *araNode) SetHeapIndex(i int32) { n.index = i }
+
+const araInf = math.MaxInt32
+
+// Anytime starts an ARA* search from start to end with initial heuristic
+// weight w (values below 1 mean 1). Every Step after the first lowers the
+// weight by delta, delta <= 0 means 0.5. The search shares the topology and
+// heuristic of s but not its node pool, and runs only when Step is called.
+func (s *Search) Anytime(sx, sy, ex, ey int32, w, delta float64) *Anytime {
+	if delta <= 0 {
+		delta = 0.5
+	}
+	a := &Anytime{
+		s:  s,
+		sx: sx, sy: sy, ex: ex, ey: ey,
+		w:     max(w, 1),
+		delta: delta,
+		bound: math.Inf(1),
+		nodes: make(map[grid.Gpos]*araNode),
+		open:  heap.NewHeap[*araNode](64),
+	}
+	start := a.node(grid.Gpos{X: sx, Y: sy})
+	start.g = 0
+	a.push(start)
+	return a
+}
+
+// Step runs one ARA* iteration and returns the best path so far with the
+// bound on its suboptimality: the path costs at most bound times the
+// optimum. Once bound is 1 the path is optimal and further calls return it
+// without searching. ok is false if there is no path.
+func (a *Anytime) Step() (p []grid.PathGrid, bound float64, ok bool) {
+	if a.started {
+		if a.bound <= 1 {
+			return a.path()
+		}
+		a.w = max(a.w-a.delta, 1)
+		// inconsistent nodes are searched again under the new weight
+		for _, n := range a.incons {
+			n.incons = false
+			a.push(n)
+		}
+		a.incons = a.incons[:0]
+		for _, n := range a.closed {
+			n.closed = false
+		}
+		a.closed = a.closed[:0]
+		rebuilt := make([]*araNode, 0, a.open.Len())
+		a.open.Iter(func(n *araNode) bool {
+			n.key = a.key(n)
+			rebuilt = append(rebuilt, n)
+			return true
+		})
+		a.open.Init(rebuilt)
+	}
+	a.started = true
+	a.improvePath()
+
+	goal := a.nodes[grid.Gpos{X: a.ex, Y: a.ey}]
+	if goal == nil || goal.g == araInf {
+		a.bound = 1 // nothing left to improve
+		return nil, math.Inf(1), false
+	}
+	// the optimum is at least the smallest g+h among nodes not settled
+	lower := float64(goal.g)
+	a.open.Iter(func(n *araNode) bool {
+		lower = min(lower, float64(n.g+n.h))
+		return true
+	})
+	for _, n := range a.incons {
+		lower = min(lower, float64(n.g+n.h))
+	}
+	a.bound = a.w
+	if lower > 0 {
+		a.bound = min(a.w, float64(goal.g)/lower)
+	} else if goal.g == 0 {
+		a.bound = 1
+	}
+	return a.path()
+}
+
+// Weight returns the heuristic weight of the last Step.
+func (a *Anytime) Weight() float64 {
+	return a.w
+}
+
+// Cost returns the cost of the current path in the units of Topology.Dist.
+func (a *Anytime) Cost() int32 {
+	if n := a.nodes[grid.Gpos{X: a.ex, Y: a.ey}]; n != nil {
+		return n.g
+	}
+	return araInf
+}
+
+func (a *Anytime) improvePath() {
+	goal := a.node(grid.Gpos{X: a.ex, Y: a.ey})
+	for !a.open.Empty() && a.key(goal) > a.open.Top().key {
+		n := a.open.Pop()
+		n.closed = true
+		a.closed = append(a.closed, n)
+		if a.s.tracer != nil {
+			a.s.tracer.Closed(n.pos.X, n.pos.Y, n.g)
+		}
+		x, y := n.pos.X, n.pos.Y
+		a.s.topo.Natural(x, y, NoDir).Iter(func(d int32) bool {
+			nx, ny, ok := a.s.topo.Step(x, y, d)
+			if !ok {
+				return true
+			}
+			m := a.node(grid.Gpos{X: nx, Y: ny})
+			g := addCost(n.g, a.s.topo.Dist(x, y, nx, ny))
+			if g >= m.g {
+				return true
+			}
+			m.g, m.parent = g, n.pos
+			switch {
+			case !m.closed:
+				a.push(m)
+			case !m.incons:
+				m.incons = true
+				a.incons = append(a.incons, m)
+			}
+			if a.s.tracer != nil {
+				a.s.tracer.Opened(nx, ny, x, y, m.g, int32(m.key))
+			}
+			return true
+		})
+	}
+}
+
+func (a *Anytime) path() ([]grid.PathGrid, float64, bool) {
+	goal := a.nodes[grid.Gpos{X: a.ex, Y: a.ey}]
+	if goal == nil || goal.g == araInf {
+		return nil, math.Inf(1), false
+	}
+	cells := []grid.PathGrid{{X: a.ex, Y: a.ey}}
+	for p := goal.pos; p != (grid.Gpos{X: a.sx, Y: a.sy}); {
+		p = a.nodes[p].parent
+		cells = append(cells, grid.PathGrid{X: p.X, Y: p.Y})
+	}
+	slices.Reverse(cells)
+	return a.s.turningPoints(cells), a.bound, true
+}
+
+func (a *Anytime) push(n *araNode) {
+	n.key = a.key(n)
+	if a.open.Contains(n) {
+		a.open.Fix(n)
+		return
+	}
+	a.open.Push(n)
+}
+
+func (a *Anytime) key(n *araNode) float64 {
+	if n.g == araInf {
+		return math.Inf(1)
+	}
+	return float64(n.g) + a.w*float64(n.h)
+}
+
+func (a *Anytime) node(p grid.Gpos) *araNode {
+	n, ok := a.nodes[p]
+	if !ok {
+		n = &araNode{pos: p, g: araInf, h: a.s.estimateTo(p.X, p.Y, a.ex, a.ey), index: -1}
+		a.nodes[p] = n
+	}
+	return n
+}
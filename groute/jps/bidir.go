@@ -0,0 +1,174 @@
+package jps
+
+import (
+	"math"
+	"slices"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// side is one direction of a bidirectional search.
+type side struct {
+	pool       *grid.NodePool
+	heap       heap.Queue[*grid.Gnode]
+	endX, endY int32 // the cell this side searches towards
+}
+
+// SetBidirectional switches Solve between jump point search and
+// bidirectional A*. The bidirectional mode runs A* over single steps from
+// both ends at once, each side with its own node pool, and always expands
+// the side with the smaller open list. It stops as soon as either open list
+// cannot improve on the best meeting path, which keeps the result optimal,
+// and fails as soon as either open list empties, so an enclosed start or
+// goal fails fast. If either side runs out of nodes, Solve falls back to
+// jump point search. Moves must be symmetric.
+func (s *Search) SetBidirectional(on bool) {
+	s.bidir = on
+}
+
+// Bidirectional reports whether the bidirectional mode is on.
+func (s *Search) Bidirectional() bool {
+	return s.bidir
+}
+
+func (s *Search) solveBidirectional(sx, sy, ex, ey int32) ([]grid.PathGrid, bool) {
+	if s.back == nil {
+		s.back = &side{pool: grid.NewNodePool(int32(s.size)), heap: s.newQueue()}
+	}
+	fwd := &side{pool: s.pool, heap: s.heap, endX: ex, endY: ey}
+	bwd := s.back
+	bwd.endX, bwd.endY = sx, sy
+	for _, d := range []*side{fwd, bwd} {
+		d.pool.Clear()
+		d.heap.Clear()
+	}
+	s.endX, s.endY = ex, ey
+	s.open(fwd, sx, sy, NoDir, sx, sy, 0)
+	s.open(bwd, ex, ey, NoDir, ex, ey, 0)
+
+	best, meet := int32(math.MaxInt32), grid.Gpos{}
+	if sx == ex && sy == ey {
+		best, meet = 0, grid.Gpos{X: sx, Y: sy}
+	}
+	for !s.full && !fwd.heap.Empty() && !bwd.heap.Empty() {
+		if fwd.heap.Top().Total >= best || bwd.heap.Top().Total >= best {
+			break
+		}
+		cur, other := fwd, bwd
+		if bwd.heap.Len() < fwd.heap.Len() {
+			cur, other = bwd, fwd
+		}
+		n := cur.heap.Pop()
+		n.Status = grid.NodeClose
+		if s.tracer != nil {
+			s.tracer.Closed(n.Pos.X, n.Pos.Y, n.Cost)
+		}
+		x, y, c := n.Pos.X, n.Pos.Y, n.Cost
+		s.topo.Natural(x, y, NoDir).Iter(func(d int32) bool {
+			nx, ny, ok := s.topo.Step(x, y, d)
+			if !ok {
+				return true
+			}
+			nc := addCost(c, s.topo.Dist(x, y, nx, ny))
+			if !s.open(cur, nx, ny, d, x, y, nc) {
+				s.full = true
+				return false
+			}
+			if o := other.pool.FindNode(nx, ny); o != nil && o.Status != grid.NodeNew && addCost(nc, o.Cost) < best {
+				best, meet = addCost(nc, o.Cost), grid.Gpos{X: nx, Y: ny}
+			}
+			return true
+		})
+	}
+	if s.full || best == math.MaxInt32 {
+		return nil, false
+	}
+	s.cost = best
+
+	// cells from the start to the meeting cell, then on to the goal
+	var cells []grid.PathGrid
+	for p := meet; ; {
+		cells = append(cells, grid.PathGrid{X: p.X, Y: p.Y})
+		n := fwd.pool.FindNode(p.X, p.Y)
+		if n == nil || n.Dir == NoDir {
+			break
+		}
+		p = n.FPos
+	}
+	slices.Reverse(cells)
+	for p := meet; ; {
+		n := bwd.pool.FindNode(p.X, p.Y)
+		if n == nil || n.Dir == NoDir {
+			break
+		}
+		p = n.FPos
+		cells = append(cells, grid.PathGrid{X: p.X, Y: p.Y})
+	}
+	return s.turningPoints(cells), true
+}
+
+// open pushes or improves the node of (x, y) reached from (fx, fy) with cost
+// c on side d. It reports false if the pool is full.
+func (s *Search) open(d *side, x, y, dir, fx, fy, c int32) bool {
+	node := d.pool.GetNode(x, y)
+	if node == nil {
+		return false
+	}
+	if node.Status == grid.NodeClose || node.Status == grid.NodeOpen && c >= node.Cost {
+		return true
+	}
+	node.FPos = grid.Gpos{X: fx, Y: fy}
+	node.Dir = dir
+	node.Cost = c
+	node.Total = addCost(c, s.estimateTo(x, y, d.endX, d.endY))
+	if node.Status == grid.NodeOpen {
+		d.heap.Fix(node)
+	} else {
+		node.Status = grid.NodeOpen
+		d.heap.Push(node)
+	}
+	if s.tracer != nil {
+		s.tracer.Opened(x, y, fx, fy, node.Cost, node.Total)
+	}
+	return true
+}
+
+func (s *Search) estimateTo(x, y, ex, ey int32) int32 {
+	if s.heuristic != nil {
+		return s.heuristic(x, y, ex, ey)
+	}
+	return s.topo.Dist(x, y, ex, ey)
+}
+
+// turningPoints keeps the first and last cell and the cells where the move
+// direction changes.
+func (s *Search) turningPoints(cells []grid.PathGrid) []grid.PathGrid {
+	if len(cells) <= 2 {
+		return cells
+	}
+	out := []grid.PathGrid{cells[0]}
+	last := s.dirOf(cells[0], cells[1])
+	for i := 1; i < len(cells)-1; i++ {
+		d := s.dirOf(cells[i], cells[i+1])
+		if d != last {
+			out = append(out, cells[i])
+			last = d
+		}
+	}
+	return append(out, cells[len(cells)-1])
+}
+
+// dirOf returns the direction of the single step from a to b.
+func (s *Search) dirOf(a, b grid.PathGrid) int32 {
+	dir := int32(NoDir)
+	s.topo.Natural(a.X, a.Y, NoDir).Iter(func(d int32) bool {
+		// the step is known to be allowed, only its target matters here
+		if x, y, _ := s.topo.Step(a.X, a.Y, d); x == b.X && y == b.Y {
+			dir = d
+			return false
+		}
+		return true
+	})
+	return dir
+}
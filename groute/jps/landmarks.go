@@ -0,0 +1,391 @@
+package jps
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync/atomic"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// ErrStale is returned when landmark tables do not match the map.
+var ErrStale = errors.New("jps: landmarks do not match the map")
+
+var landmarkMagic = [4]byte{'L', 'M', 'K', '1'}
+
+// maxLandmarkCells bounds the table entries ReadLandmarks accepts, landmarks
+// times cells.
+const maxLandmarkCells = 1 << 28
+
+// Landmarks holds the distance tables of the ALT heuristic: the exact
+// distance from every cell of a map to a few landmark cells. By the triangle
+// inequality |d(L, x) - d(L, g)| never overestimates d(x, g), which is far
+// tighter than the plain distance in mazes with long detours.
+//
+// Tables use 16 bits per cell and landmark when the distances fit, 32 bits
+// otherwise. They describe the map at build time; after any edit call
+// Invalidate, or the heuristic may overestimate. Tables built on a snapshot
+// of a grid.Versioned map can Track it instead.
+type Landmarks struct {
+	Rect   grid.Rect
+	Points []grid.Gpos
+	// Unit is the cost of one straight step of the topology the tables were
+	// built with, to detect tables of another cost model.
+	Unit int32
+
+	t16   [][]uint16
+	t32   [][]uint32
+	sum   uint64 // fingerprint of the map
+	stale atomic.Bool
+
+	versions *grid.Versioned // set by Track
+	version  uint64
+}
+
+// BuildLandmarks picks k landmarks of m by farthest point selection and
+// computes their distance tables with the moves and costs of t. Every
+// connected part of the map receives a landmark before any part receives a
+// second one. The tables cover m.Bounds(), so the bounds must be finite and
+// reasonably small.
+func BuildLandmarks(t Topology, m grid.Walkable, k int) *Landmarks {
+	r := m.Bounds()
+	l := &Landmarks{Rect: r, Unit: t.Dist(0, 0, 1, 0), sum: fingerprint(m)}
+	n := int(r.MaxX-r.MinX) * int(r.MaxY-r.MinY)
+	if n <= 0 || k <= 0 {
+		return l
+	}
+
+	seed := -1
+	for i := 0; i < n; i++ {
+		if x, y := l.cell(i); m.Available(x, y) {
+			seed = i
+			break
+		}
+	}
+	if seed < 0 {
+		return l
+	}
+	far := farthest(dijkstra(t, m, r, seed), nil)
+	if far < 0 {
+		far = seed // an isolated cell
+	}
+	mins := make([]int64, n)
+	for i := range mins {
+		mins[i] = math.MaxInt64
+	}
+	var tables [][]int64
+	for len(tables) < k {
+		ds := dijkstra(t, m, r, far)
+		tables = append(tables, ds)
+		x, y := l.cell(far)
+		l.Points = append(l.Points, grid.Gpos{X: x, Y: y})
+		for i, d := range ds {
+			mins[i] = min(mins[i], d)
+		}
+		if far = farthest(mins, func(i int) bool { x, y := l.cell(i); return m.Available(x, y) }); far < 0 {
+			break
+		}
+	}
+
+	var dmax int64
+	for _, ds := range tables {
+		for _, d := range ds {
+			if d != math.MaxInt64 {
+				dmax = max(dmax, d)
+			}
+		}
+	}
+	for _, ds := range tables {
+		if dmax < math.MaxUint16 {
+			t16 := make([]uint16, n)
+			for i, d := range ds {
+				t16[i] = uint16(min(d, math.MaxUint16))
+			}
+			l.t16 = append(l.t16, t16)
+		} else {
+			t32 := make([]uint32, n)
+			for i, d := range ds {
+				t32[i] = uint32(min(d, math.MaxUint32))
+			}
+			l.t32 = append(l.t32, t32)
+		}
+	}
+	return l
+}
+
+// Heuristic returns the ALT estimate, never lower than base (nil means
+// no base). Once the tables are invalidated it returns base alone. The
+// returned function caches the goal's distances, so every Search needs its
+// own; the tables themselves may be shared.
+func (l *Landmarks) Heuristic(base Heuristic) Heuristic {
+	goal, gs := int32(-1), make([]uint32, len(l.Points))
+	return func(x, y, ex, ey int32) int32 {
+		h := int32(0)
+		if base != nil {
+			h = base(x, y, ex, ey)
+		}
+		if !l.Valid() || !l.Rect.Contains(x, y) || !l.Rect.Contains(ex, ey) {
+			return h
+		}
+		if g := l.index(ex, ey); g != goal {
+			goal = g
+			for j := range gs {
+				if d, ok := l.dist(j, g); ok {
+					gs[j] = d
+				} else {
+					gs[j] = math.MaxUint32
+				}
+			}
+		}
+		i := l.index(x, y)
+		for j, dg := range gs {
+			dx, ok := l.dist(j, i)
+			if !ok || dg == math.MaxUint32 {
+				continue
+			}
+			if diff := int64(dx) - int64(dg); diff > int64(h) {
+				h = int32(diff)
+			} else if -diff > int64(h) {
+				h = int32(-diff)
+			}
+		}
+		return h
+	}
+}
+
+// Invalidate marks the tables as stale after the map was edited. The
+// heuristic falls back to its base.
+func (l *Landmarks) Invalidate() {
+	l.stale.Store(true)
+}
+
+// Track ties the tables to version of v, the snapshot they were built on:
+// they count as invalidated as soon as v publishes a newer version. It must
+// be called before the tables are used.
+func (l *Landmarks) Track(v *grid.Versioned, version uint64) {
+	l.versions, l.version = v, version
+}
+
+// Valid reports whether the tables were not invalidated.
+func (l *Landmarks) Valid() bool {
+	if l.versions != nil && l.versions.Version() != l.version {
+		return false
+	}
+	return !l.stale.Load()
+}
+
+// Matches reports whether m has the same bounds and cells as the map the
+// tables were built on.
+func (l *Landmarks) Matches(m grid.Walkable) bool {
+	return m.Bounds() == l.Rect && fingerprint(m) == l.sum
+}
+
+// WriteTo encodes the tables: the magic "LMK1", the rect, unit, map
+// fingerprint, landmark count and table width as little-endian integers,
+// then the landmarks and their tables.
+func (l *Landmarks) WriteTo(wr io.Writer) (int64, error) {
+	bw := bufio.NewWriter(wr)
+	n := int64(0)
+	put := func(v any) {
+		_ = binary.Write(bw, binary.LittleEndian, v)
+		n += int64(binary.Size(v))
+	}
+	width := uint8(16)
+	if len(l.t32) > 0 {
+		width = 32
+	}
+	put(landmarkMagic)
+	put([4]int32{l.Rect.MinX, l.Rect.MinY, l.Rect.MaxX, l.Rect.MaxY})
+	put(l.Unit)
+	put(l.sum)
+	put(uint32(len(l.Points)))
+	put(width)
+	for _, p := range l.Points {
+		put([2]int32{p.X, p.Y})
+	}
+	for _, t := range l.t16 {
+		put(t)
+	}
+	for _, t := range l.t32 {
+		put(t)
+	}
+	return n, bw.Flush()
+}
+
+// ReadLandmarks decodes tables written by Landmarks.WriteTo and checks them
+// against m, returning ErrStale if m changed since they were built.
+func ReadLandmarks(r io.Reader, m grid.Walkable) (*Landmarks, error) {
+	br := bufio.NewReader(r)
+	var (
+		head  [4]byte
+		rect  [4]int32
+		count uint32
+		width uint8
+		l     = &Landmarks{}
+	)
+	for _, v := range []any{&head, &rect, &l.Unit, &l.sum, &count, &width} {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	l.Rect = grid.Rect{MinX: rect[0], MinY: rect[1], MaxX: rect[2], MaxY: rect[3]}
+	n := int64(l.Rect.MaxX-l.Rect.MinX) * int64(l.Rect.MaxY-l.Rect.MinY)
+	if head != landmarkMagic || n <= 0 || count > 1<<10 || n*int64(count) > maxLandmarkCells ||
+		width != 16 && width != 32 {
+		return nil, grid.ErrFormat
+	}
+	l.Points = make([]grid.Gpos, count)
+	for i := range l.Points {
+		var p [2]int32
+		if err := binary.Read(br, binary.LittleEndian, &p); err != nil {
+			return nil, err
+		}
+		l.Points[i] = grid.Gpos{X: p[0], Y: p[1]}
+	}
+	for range count {
+		var err error
+		if width == 16 {
+			var t []uint16
+			t, err = grid.ReadSlice[uint16](br, int(n))
+			l.t16 = append(l.t16, t)
+		} else {
+			var t []uint32
+			t, err = grid.ReadSlice[uint32](br, int(n))
+			l.t32 = append(l.t32, t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+		}
+	}
+	if !l.Matches(m) {
+		return nil, ErrStale
+	}
+	return l, nil
+}
+
+// dist returns the distance from landmark j to cell i, ok == false if the
+// cell is unreachable from it.
+func (l *Landmarks) dist(j int, i int32) (uint32, bool) {
+	if l.t16 != nil {
+		d := l.t16[j][i]
+		return uint32(d), d != math.MaxUint16
+	}
+	d := l.t32[j][i]
+	return d, d != math.MaxUint32
+}
+
+func (l *Landmarks) index(x, y int32) int32 {
+	return (y-l.Rect.MinY)*(l.Rect.MaxX-l.Rect.MinX) + x - l.Rect.MinX
+}
+
+func (l *Landmarks) cell(i int) (int32, int32) {
+	w := int(l.Rect.MaxX - l.Rect.MinX)
+	return l.Rect.MinX + int32(i%w), l.Rect.MinY + int32(i/w)
+}
+
+// farthest returns the index of the largest distance, unreachable ones
+// first, among cells accepted by ok (nil accepts reachable cells), or -1
+// if every candidate is at distance 0.
+func farthest(ds []int64, ok func(i int) bool) int {
+	best, at := int64(0), -1
+	for i, d := range ds {
+		if ok == nil && d == math.MaxInt64 || ok != nil && !ok(i) {
+			continue
+		}
+		if d > best {
+			best, at = d, i
+		}
+	}
+	return at
+}
+
+// dijkstra returns the distance from cell index src to every cell of r,
+// math.MaxInt64 for unreachable ones. Moves are symmetric, so these are
+// also the distances to src.
+func dijkstra(t Topology, m grid.Walkable, r grid.Rect, src int) []int64 {
+	w := int(r.MaxX - r.MinX)
+	nodes := make([]dijkstraNode, w*int(r.MaxY-r.MinY))
+	for i := range nodes {
+		nodes[i] = dijkstraNode{i: i, d: math.MaxInt64, index: -1}
+	}
+	nodes[src].d = 0
+	q := heap.NewHeap[*dijkstraNode](0)
+	q.Push(&nodes[src])
+	for !q.Empty() {
+		cur := q.Pop()
+		x, y := r.MinX+int32(cur.i%w), r.MinY+int32(cur.i/w)
+		t.Natural(x, y, NoDir).Iter(func(d int32) bool {
+			nx, ny, ok := t.Step(x, y, d)
+			if !ok || !r.Contains(nx, ny) || !m.Available(nx, ny) {
+				return true
+			}
+			next := &nodes[int(ny-r.MinY)*w+int(nx-r.MinX)]
+			if nd := cur.d + int64(t.Dist(x, y, nx, ny)); nd < next.d {
+				next.d = nd
+				if q.Contains(next) {
+					q.Fix(next)
+				} else {
+					q.Push(next)
+				}
+			}
+			return true
+		})
+	}
+	ds := make([]int64, len(nodes))
+	for i := range nodes {
+		ds[i] = nodes[i].d
+	}
+	return ds
+}
+
+func fingerprint(m grid.Walkable) uint64 {
+	r := m.Bounds()
+	h := fnv.New64a()
+	var b [8]byte
+	bits := uint64(0)
+	n := 0
+	for y := r.MinY; y < r.MaxY; y++ {
+		for x := r.MinX; x < r.MaxX; x++ {
+			if !m.Available(x, y) {
+				bits |= 1 << n
+			}
+			if n++; n == 64 {
+				binary.LittleEndian.PutUint64(b[:], bits)
+				h.Write(b[:])
+				bits, n = 0, 0
+			}
+		}
+	}
+	binary.LittleEndian.PutUint64(b[:], bits)
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+// dijkstraNode is the distance of one cell in dijkstra.
+type dijkstraNode struct {
+	i     int
+	d     int64
+	index int32
+}
+
+// Compare implements heap.Node.
+func (n *dijkstraNode) Compare(o *dijkstraNode) int32 {
+	return int32(cmp.Compare(n.d, o.d))
+}
+
+// GetHeapIndex implements heap.Node.
+func (n *dijkstraNode) GetHeapIndex() int32 {
+	return n.index
+}
+
+// SetHeapIndex implements heap.Node.
+func (n *dijkstraNode) SetHeapIndex(i int32) {
+	n.index = i
+}
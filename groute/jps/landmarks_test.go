@@ -0,0 +1,109 @@
+package jps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLandmarks(t *testing.T) {
+	m := grid.NewLocal(1, 1)
+	m.SetGrid(0, 0, new(grid.Grid))
+	for y := int32(0); y < 15; y++ {
+		m.Set(5, y)
+	}
+	topo := &cross{m: m}
+	l := BuildLandmarks(topo, m, 3)
+	require.Len(t, l.Points, 3)
+	assert.Equal(t, int32(1), l.Unit)
+
+	// 估价不超过真实距离，且绕墙时比曼哈顿距离更紧
+	h := l.Heuristic(topo.Dist)
+	s := NewSearch(topo, 256)
+	for _, goal := range []grid.Gpos{{X: 10, Y: 0}, {X: 0, Y: 15}, {X: 15, Y: 15}} {
+		for y := int32(0); y < 16; y++ {
+			for x := int32(0); x < 16; x++ {
+				if !m.Available(x, y) {
+					continue
+				}
+				if _, ok := s.Solve(x, y, goal.X, goal.Y); ok {
+					assert.LessOrEqual(t, h(x, y, goal.X, goal.Y), s.Cost(), "(%d,%d)->%v", x, y, goal)
+				}
+			}
+		}
+	}
+	assert.Equal(t, int32(10), topo.Dist(0, 0, 10, 0))
+	assert.Equal(t, int32(40), h(0, 0, 10, 0))
+
+	// 序列化往返
+	var buf bytes.Buffer
+	n, err := l.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	data := buf.Bytes()
+	l1, err := ReadLandmarks(bytes.NewReader(data), m)
+	require.NoError(t, err)
+	assert.Equal(t, l.Points, l1.Points)
+	assert.Equal(t, int32(40), l1.Heuristic(topo.Dist)(0, 0, 10, 0))
+
+	_, err = ReadLandmarks(bytes.NewReader(data[:len(data)-1]), m)
+	assert.ErrorIs(t, err, grid.ErrFormat)
+	_, err = ReadLandmarks(bytes.NewReader(append([]byte("XXXX"), data[4:]...)), m)
+	assert.ErrorIs(t, err, grid.ErrFormat)
+
+	// 头部声明的表格过大，或数据远少于声明的长度
+	huge := bytes.Clone(data)
+	binary.LittleEndian.PutUint32(huge[4+16+4+8:], 1024)
+	binary.LittleEndian.PutUint32(huge[4+8:], 1<<15)
+	binary.LittleEndian.PutUint32(huge[4+12:], 1<<15)
+	_, err = ReadLandmarks(bytes.NewReader(huge), m)
+	assert.ErrorIs(t, err, grid.ErrFormat)
+	binary.LittleEndian.PutUint32(huge[4+16+4+8:], 1)
+	binary.LittleEndian.PutUint32(huge[4+8:], 1<<14)
+	binary.LittleEndian.PutUint32(huge[4+12:], 1<<14)
+	_, err = ReadLandmarks(bytes.NewReader(huge), m)
+	assert.ErrorIs(t, err, grid.ErrFormat)
+
+	// 地图改动后表格失效
+	m.Set(5, 15)
+	assert.False(t, l.Matches(m))
+	_, err = ReadLandmarks(bytes.NewReader(data), m)
+	assert.ErrorIs(t, err, ErrStale)
+	l.Invalidate()
+	assert.False(t, l.Valid())
+	assert.Equal(t, int32(10), h(0, 0, 10, 0))
+
+	// 跟踪版本化地图：发布新版本后表格失效
+	m2 := grid.NewLocal(1, 1)
+	m2.SetGrid(0, 0, new(grid.Grid))
+	for y := int32(0); y < 15; y++ {
+		m2.Set(5, y)
+	}
+	v := grid.NewVersioned(m2)
+	snap := v.Acquire()
+	lv := BuildLandmarks(&cross{m: m2}, snap.Map, 3)
+	lv.Track(v, snap.Version)
+	snap.Release()
+	hv := lv.Heuristic(topo.Dist)
+	assert.True(t, lv.Valid())
+	assert.Equal(t, int32(40), hv(0, 0, 10, 0))
+	v.Update(func(tx *grid.Tx) { tx.Set(4, 4, true) })
+	assert.False(t, lv.Valid())
+	assert.Equal(t, int32(10), hv(0, 0, 10, 0))
+}
+
+func TestLandmarks_Components(t *testing.T) {
+	// 两个不连通的区域各得到一个地标
+	m := grid.NewLocal(1, 1)
+	m.SetGrid(0, 0, new(grid.Grid))
+	for y := int32(0); y < 16; y++ {
+		m.Set(5, y)
+	}
+	l := BuildLandmarks(&cross{m: m}, m, 2)
+	require.Len(t, l.Points, 2)
+	assert.NotEqual(t, l.Points[0].X < 5, l.Points[1].X < 5)
+}
@@ -0,0 +1,321 @@
+package jps
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// NoDir is the direction of a node that was not reached by a jump, i.e. the start.
+const NoDir = 0xff
+
+// DirSet is a bit set of movement directions.
+type DirSet uint32
+
+// Add inserts direction d into the set.
+func (s *DirSet) Add(d int32) {
+	*s |= 1 << d
+}
+
+// Iter calls f for every direction in ascending order until f returns false.
+func (s DirSet) Iter(f func(d int32) bool) {
+	for s != 0 {
+		d := int32(bits.TrailingZeros32(uint32(s)))
+		if !f(d) {
+			return
+		}
+		s &^= 1 << d
+	}
+}
+
+// Topology describes the movement and pruning rules of a grid.
+//
+// Directions are small integers chosen by the topology; they must fit in a
+// DirSet. Costs returned by Dist must be consistent with the moves produced by
+// Step, because the same function is used for g and h.
+type Topology interface {
+	// Step moves one cell from (x, y) in direction d and reports whether the
+	// resulting cell can be entered from (x, y).
+	Step(x, y, d int32) (nx, ny int32, ok bool)
+	// Natural returns the natural successors of a node reached travelling in
+	// d. For d == NoDir it returns every direction.
+	Natural(x, y, d int32) DirSet
+	// Forced returns the forced successors of a node reached travelling in d.
+	Forced(x, y, d int32) DirSet
+	// Spread returns the two directions scanned from every cell of a jump in
+	// d, or ok == false if d only scans straight ahead.
+	Spread(d int32) (a, b int32, ok bool)
+	// Dist returns the cost of the shortest obstacle free move between two cells.
+	Dist(x1, y1, x2, y2 int32) int32
+	// MidPoint returns the turning cell of a jump from (fx, fy) to (x, y), if any.
+	MidPoint(x, y, fx, fy int32) (mx, my int32, ok bool)
+}
+
+// Heuristic estimates the remaining cost from (x, y) to the goal (ex, ey) in
+// the units of Topology.Dist. It must never overestimate.
+type Heuristic func(x, y, ex, ey int32) int32
+
+// QueueKind selects the open list implementation of a Search.
+type QueueKind uint8
+
+const (
+	// QueueHeap is a binary heap, the default.
+	QueueHeap QueueKind = iota
+	// QueueQuadHeap is a 4-ary heap.
+	QueueQuadHeap
+	// QueueBucket is a monotone bucket queue keyed by the integer total cost.
+	QueueBucket
+)
+
+// Search is the jump point search loop shared by every topology. It owns the
+// node pool and open list, so it must not be used by two goroutines at once.
+type Search struct {
+	topo Topology
+
+	pool       *grid.NodePool
+	heap       heap.Queue[*grid.Gnode]
+	heuristic  Heuristic
+	weight     float64 // heuristic weight, <= 1 means plain A*
+	tracer     Tracer
+	size       int
+	queue      QueueKind
+	endX, endY int32
+	cost       int32
+	full       bool // the last Solve ran out of nodes
+
+	bidir bool
+	back  *side // backward search of bidirectional mode, created on first use
+}
+
+// NewSearch creates a reusable search over topology t with capacity for size nodes.
+func NewSearch(t Topology, size int) *Search {
+	return &Search{
+		topo: t,
+		pool: grid.NewNodePool(int32(size)),
+		heap: heap.NewHeap[*grid.Gnode](size),
+		size: size,
+	}
+}
+
+// SetQueue replaces the open list implementation.
+func (s *Search) SetQueue(k QueueKind) {
+	s.queue = k
+	s.heap = s.newQueue()
+	if s.back != nil {
+		s.back.heap = s.newQueue()
+	}
+}
+
+// Queue returns the open list implementation in use.
+func (s *Search) Queue() QueueKind {
+	return s.queue
+}
+
+func (s *Search) newQueue() heap.Queue[*grid.Gnode] {
+	switch s.queue {
+	case QueueQuadHeap:
+		return heap.NewDHeap[*grid.Gnode](4, s.size)
+	case QueueBucket:
+		return heap.NewBucketQueue[*grid.Gnode](s.size)
+	}
+	return heap.NewHeap[*grid.Gnode](s.size)
+}
+
+// SetHeuristic replaces the goal estimate. nil restores Topology.Dist.
+func (s *Search) SetHeuristic(h Heuristic) {
+	s.heuristic = h
+}
+
+// SetWeight multiplies the goal estimate by w, for weighted A*: the path
+// found costs at most w times the optimum, and usually far fewer nodes are
+// expanded. w <= 1 restores optimal search. The weight does not apply to the
+// bidirectional mode.
+func (s *Search) SetWeight(w float64) {
+	s.weight = w
+}
+
+// Weight returns the heuristic weight, 1 for optimal search.
+func (s *Search) Weight() float64 {
+	return max(s.weight, 1)
+}
+
+// Cost returns the cost of the path found by the last successful Solve, in
+// the units of Topology.Dist.
+func (s *Search) Cost() int32 {
+	return s.cost
+}
+
+// Exhausted reports whether the last Solve ran out of nodes. A failed Solve
+// that is not exhausted proves the goal unreachable; an exhausted one only
+// means the node pool was too small.
+func (s *Search) Exhausted() bool {
+	return s.full
+}
+
+// Solve searches a path from start to end cell coordinates.
+func (s *Search) Solve(sx, sy, ex, ey int32) (p []grid.PathGrid, ok bool) {
+	s.full = false
+	if s.bidir {
+		if p, ok = s.solveBidirectional(sx, sy, ex, ey); ok || !s.full {
+			return p, ok
+		}
+		// a side ran out of nodes, which proves nothing about the goal, and
+		// jump point search stores far fewer nodes
+		s.full = false
+	}
+	return s.solveJump(sx, sy, ex, ey)
+}
+
+func (s *Search) solveJump(sx, sy, ex, ey int32) ([]grid.PathGrid, bool) {
+	s.pool.Clear()
+	s.heap.Clear()
+	s.endX, s.endY = ex, ey
+	s.putInOpenSet(sx, sy, NoDir, sx, sy, 0)
+	for {
+		x, y, d, c, ok1 := s.getOutOpenSet()
+		if !ok1 {
+			break
+		}
+		if x == ex && y == ey {
+			s.cost = c
+			return s.path(sx, sy)
+		}
+		set := s.topo.Natural(x, y, d) | s.topo.Forced(x, y, d)
+		set.Iter(func(nd int32) bool {
+			return !s.jump(x, y, x, y, nd, c)
+		})
+	}
+	return nil, false
+}
+
+func (s *Search) jump(x, y, fx, fy, d, c int32) bool {
+	var (
+		a, b, spread = s.topo.Spread(d)
+		ox, oy, n    = x, y, int32(0)
+	)
+	for {
+		var ok bool
+		n++
+		if x, y, ok = s.topo.Step(x, y, d); !ok {
+			if s.tracer != nil {
+				s.traceJump(ox, oy, d, n, x, y, JumpBlocked)
+			}
+			return false
+		}
+		if x == s.endX && y == s.endY {
+			if s.tracer != nil {
+				s.traceJump(ox, oy, d, n, x, y, JumpGoal)
+			}
+			s.putInOpenSet(x, y, d, fx, fy, c)
+			return true
+		}
+		if forced := s.topo.Forced(x, y, d); forced != 0 {
+			if s.tracer != nil {
+				s.tracer.Forced(x, y, d, forced)
+				s.traceJump(ox, oy, d, n, x, y, JumpForced)
+			}
+			s.putInOpenSet(x, y, d, fx, fy, c)
+			return false
+		}
+		if spread {
+			if s.jump(x, y, fx, fy, a, c) || s.jump(x, y, fx, fy, b, c) {
+				if s.tracer != nil {
+					s.traceJump(ox, oy, d, n, x, y, JumpGoal)
+				}
+				return true
+			}
+		}
+	}
+}
+
+func (s *Search) traceJump(x, y, d, n, ex, ey int32, r JumpResult) {
+	s.tracer.Jump(JumpScan{X: x, Y: y, Dir: d, Len: n, EndX: ex, EndY: ey, Result: r})
+}
+
+func (s *Search) getOutOpenSet() (x, y, d, c int32, ok bool) {
+	if s.heap.Empty() {
+		return
+	}
+	node := s.heap.Pop()
+	node.Status = grid.NodeClose
+	if s.tracer != nil {
+		s.tracer.Closed(node.Pos.X, node.Pos.Y, node.Cost)
+	}
+	return node.Pos.X, node.Pos.Y, node.Dir, node.Cost, true
+}
+
+func (s *Search) putInOpenSet(x, y, d, fx, fy, c int32) {
+	node := s.pool.GetNode(x, y)
+	if node == nil {
+		s.full = true
+		return
+	}
+	switch node.Status {
+	case grid.NodeNew:
+		node.FPos = grid.Gpos{X: fx, Y: fy}
+		node.Dir = d
+		node.Cost = addCost(c, s.topo.Dist(x, y, fx, fy))
+		node.Total = addCost(node.Cost, s.estimate(x, y))
+		node.Status = grid.NodeOpen
+		s.heap.Push(node)
+		if s.tracer != nil {
+			s.tracer.Opened(x, y, fx, fy, node.Cost, node.Total)
+		}
+	case grid.NodeOpen:
+		cost := addCost(c, s.topo.Dist(x, y, fx, fy))
+		if cost < node.Cost {
+			node.FPos = grid.Gpos{X: fx, Y: fy}
+			node.Dir = d
+			node.Cost = cost
+			node.Total = addCost(cost, s.estimate(x, y))
+			s.heap.Fix(node)
+			if s.tracer != nil {
+				s.tracer.Opened(x, y, fx, fy, node.Cost, node.Total)
+			}
+		}
+	case grid.NodeClose:
+		return
+	}
+}
+
+func (s *Search) estimate(x, y int32) int32 {
+	h := s.estimateTo(x, y, s.endX, s.endY)
+	if s.weight > 1 {
+		h = int32(min(float64(h)*s.weight, math.MaxInt32))
+	}
+	return h
+}
+
+// addCost adds the non-negative cost b to a, saturating at math.MaxInt32 so
+// that cells too far away for int32 costs sort last instead of wrapping.
+func addCost(a, b int32) int32 {
+	if c := a + b; c >= a {
+		return c
+	}
+	return math.MaxInt32
+}
+
+func (s *Search) path(sx, sy int32) (p []grid.PathGrid, ok bool) {
+	var (
+		fx, fy int32
+		x, y   = s.endX, s.endY
+		node   *grid.Gnode
+	)
+	for !(x == sx && y == sy) {
+		p = append(p, grid.PathGrid{X: x, Y: y})
+		if node = s.pool.FindNode(x, y); node == nil {
+			return
+		}
+		fx, fy = node.FPos.X, node.FPos.Y
+		if mx, my, ok1 := s.topo.MidPoint(x, y, fx, fy); ok1 {
+			p = append(p, grid.PathGrid{X: mx, Y: my})
+		}
+		x, y = fx, fy
+	}
+	p = append(p, grid.PathGrid{X: sx, Y: sy})
+	slices.Reverse(p)
+	return p, true
+}
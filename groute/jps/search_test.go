@@ -0,0 +1,115 @@
+package jps
+
+import (
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirSet(t *testing.T) {
+	var s DirSet
+
+	// 测试添加方向
+	s.Add(0)
+	s.Add(2)
+	s.Add(4)
+
+	// 测试迭代
+	var directions []int32
+	s.Iter(func(d int32) bool {
+		directions = append(directions, d)
+		return true
+	})
+
+	expected := []int32{0, 2, 4}
+	if len(directions) != len(expected) {
+		t.Errorf("方向数量错误，期望 %d，得到 %d", len(expected), len(directions))
+	}
+
+	for i, d := range expected {
+		if i >= len(directions) || directions[i] != d {
+			t.Errorf("方向 %d：期望 %d，得到 %d", i, d, directions[i])
+		}
+	}
+}
+
+// 测试提前终止迭代
+func TestDirSet_EarlyTermination(t *testing.T) {
+	var s DirSet
+	s.Add(0)
+	s.Add(1)
+	s.Add(2)
+
+	var count int
+	s.Iter(func(d int32) bool {
+		count++
+		return count < 2 // 只处理前2个方向
+	})
+
+	if count != 2 {
+		t.Errorf("应该只处理2个方向，实际处理了 %d 个", count)
+	}
+}
+
+// cross is a 4-connected topology without pruning: every cell is a jump point.
+type cross struct {
+	m *grid.Local
+}
+
+func (c *cross) Step(x, y, d int32) (int32, int32, bool) {
+	switch d {
+	case 0:
+		y++
+	case 1:
+		x++
+	case 2:
+		y--
+	case 3:
+		x--
+	}
+	return x, y, c.m.Available(x, y)
+}
+
+func (c *cross) Natural(_, _, d int32) DirSet {
+	if d == NoDir {
+		return 0xf
+	}
+	return 1 << d
+}
+
+func (c *cross) Forced(_, _, d int32) DirSet {
+	if d == NoDir {
+		return 0
+	}
+	return 1<<((d+1)%4) | 1<<((d+3)%4)
+}
+
+func (c *cross) Spread(int32) (int32, int32, bool) { return 0, 0, false }
+
+func (c *cross) Dist(x1, y1, x2, y2 int32) int32 {
+	dx, dy := x1-x2, y1-y2
+	return max(dx, -dx) + max(dy, -dy)
+}
+
+func (c *cross) MidPoint(int32, int32, int32, int32) (int32, int32, bool) { return 0, 0, false }
+
+func TestSearch_CustomTopology(t *testing.T) {
+	m := grid.NewLocal(1, 1)
+	m.SetGrid(0, 0, new(grid.Grid))
+	for y := int32(0); y < 15; y++ {
+		m.Set(5, y)
+	}
+
+	s := NewSearch(&cross{m: m}, 256)
+	path, ok := s.Solve(0, 0, 10, 0)
+	require.True(t, ok)
+	assert.Equal(t, grid.PathGrid{X: 0, Y: 0}, path[0])
+	assert.Equal(t, grid.PathGrid{X: 10, Y: 0}, path[len(path)-1])
+	assert.Len(t, path, 10+2*15+1)
+
+	m.Set(5, 15)
+	_, ok = s.Solve(0, 0, 10, 0)
+	assert.False(t, ok)
+}
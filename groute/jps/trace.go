@@ -0,0 +1,46 @@
+package jps
+
+// JumpResult tells why a jump scan stopped.
+type JumpResult uint8
+
+const (
+	// JumpBlocked means the next cell could not be entered.
+	JumpBlocked JumpResult = iota
+	// JumpGoal means the scan reached the goal. A diagonal scan whose
+	// straight branch reached the goal also ends with JumpGoal, at the cell
+	// the branch started from; it is reported after the branch.
+	JumpGoal
+	// JumpForced means the scan stopped at a cell with forced neighbours.
+	JumpForced
+)
+
+// JumpScan describes one straight scan of a jump.
+type JumpScan struct {
+	X, Y   int32 // cell the scan started from
+	Dir    int32
+	Len    int32 // cells entered, including the cell the scan stopped on
+	EndX   int32 // last cell examined: the jump point, or the blocked cell
+	EndY   int32
+	Result JumpResult
+}
+
+// Tracer observes a search for debugging and visualisation. Callbacks run
+// on the searching goroutine in search order; the search does no tracing
+// work while no tracer is set.
+type Tracer interface {
+	// Opened reports a node pushed into or improved in the open list, with
+	// its parent, cost and estimated total cost.
+	Opened(x, y, fx, fy, cost, total int32)
+	// Closed reports a node popped from the open list for expansion.
+	Closed(x, y, cost int32)
+	// Jump reports a finished straight scan.
+	Jump(scan JumpScan)
+	// Forced reports the forced successors found at (x, y) when reached
+	// travelling in d.
+	Forced(x, y, d int32, forced DirSet)
+}
+
+// SetTracer attaches t to the search. nil disables tracing.
+func (s *Search) SetTracer(t Tracer) {
+	s.tracer = t
+}
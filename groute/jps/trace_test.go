@@ -0,0 +1,57 @@
+package jps
+
+import (
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	opened, closed map[grid.Gpos]int
+	jumps          []JumpScan
+	forced         int
+}
+
+func (r *recorder) Opened(x, y, _, _, _, _ int32)  { r.opened[grid.Gpos{X: x, Y: y}]++ }
+func (r *recorder) Closed(x, y, _ int32)           { r.closed[grid.Gpos{X: x, Y: y}]++ }
+func (r *recorder) Jump(scan JumpScan)             { r.jumps = append(r.jumps, scan) }
+func (r *recorder) Forced(_, _, _ int32, _ DirSet) { r.forced++ }
+
+func TestSearch_Tracer(t *testing.T) {
+	m := grid.NewLocal(1, 1)
+	m.SetGrid(0, 0, new(grid.Grid))
+	for y := int32(0); y < 15; y++ {
+		m.Set(5, y)
+	}
+
+	rec := &recorder{opened: map[grid.Gpos]int{}, closed: map[grid.Gpos]int{}}
+	s := NewSearch(&cross{m: m}, 256)
+	s.SetTracer(rec)
+	_, ok := s.Solve(0, 0, 10, 0)
+	require.True(t, ok)
+
+	assert.Equal(t, 1, rec.opened[grid.Gpos{}])
+	assert.Equal(t, 1, rec.closed[grid.Gpos{X: 10, Y: 0}])
+	for p, n := range rec.closed {
+		assert.Equal(t, 1, n, "closed twice: %v", p)
+		assert.NotZero(t, rec.opened[p], "closed but never opened: %v", p)
+	}
+	last := rec.jumps[len(rec.jumps)-1]
+	assert.Equal(t, JumpGoal, last.Result)
+	assert.Equal(t, [2]int32{10, 0}, [2]int32{last.EndX, last.EndY})
+	for _, j := range rec.jumps {
+		assert.Equal(t, int32(1), j.Len) // cross has a forced neighbour on every cell
+		if j.Result == JumpBlocked {
+			assert.False(t, m.Available(j.EndX, j.EndY))
+		}
+	}
+	assert.NotZero(t, rec.forced)
+
+	s.SetTracer(nil)
+	n := len(rec.jumps)
+	_, ok = s.Solve(0, 0, 10, 0)
+	require.True(t, ok)
+	assert.Len(t, rec.jumps, n)
+}
@@ -0,0 +1,224 @@
+package groute
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+)
+
+// Query is a path request in cell coordinates.
+type Query struct {
+	SX, SY, EX, EY int32
+}
+
+// Result is the answer to a Query.
+type Result struct {
+	Path []grid.PathGrid
+	OK   bool
+	// Version is the map version the path was computed on, for planners
+	// created by NewVersionedPlanner. Compare it with grid.Versioned.Version
+	// to detect stale paths.
+	Version uint64
+}
+
+// PlannerOptions configures a Planner.
+type PlannerOptions struct {
+	// Nodes is the search node capacity of every workspace. 0 means one
+	// node per cell of the map bounds; it must be set for maps of more than
+	// maxDefaultNodes cells, such as a grid.World.
+	Nodes int
+	// Workers bounds both the number of workspaces and the goroutines used
+	// by SolveBatch. 0 means GOMAXPROCS.
+	Workers int
+	// Setup, if set, configures every new workspace, e.g. to select a cost
+	// model or queue.
+	Setup func(s Solver)
+}
+
+// Planner answers path queries from many goroutines over a shared map.
+//
+// It owns a bounded set of workspaces, so a caller never needs its own.
+// The map is read-only while searches run: every change must go through
+// Edit or SetMap, which wait for in-flight searches and hold new ones back
+// until the change is done. A planner over a grid.Versioned map needs no
+// fence, see NewVersionedPlanner.
+type Planner struct {
+	kind  Kind
+	nodes int
+	setup func(s Solver)
+
+	fence sync.RWMutex
+	m     grid.Walkable
+	gen   uint64 // map generation, bumped by SetMap
+
+	versions *grid.Versioned
+
+	free    chan *plannerSlot
+	created atomic.Int32
+	workers int
+}
+
+type plannerSlot struct {
+	s   Solver
+	gen uint64 // map generation or snapshot version the solver is reset to
+}
+
+// maxDefaultNodes bounds the node capacity PlannerOptions.Nodes defaults to;
+// every workspace allocates it up front.
+const maxDefaultNodes = 1 << 20
+
+// NewPlanner creates a planner of kind k over m. It reports false for an
+// unknown kind, or if opt.Nodes is 0 and m has more than maxDefaultNodes
+// cells.
+func NewPlanner(k Kind, m grid.Walkable, opt PlannerOptions) (*Planner, bool) {
+	if k != Square && k != Hex {
+		return nil, false
+	}
+	if opt.Workers <= 0 {
+		opt.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opt.Nodes <= 0 {
+		b := m.Bounds()
+		cells := int64(b.MaxX-b.MinX) * int64(b.MaxY-b.MinY)
+		if cells > maxDefaultNodes {
+			return nil, false
+		}
+		opt.Nodes = int(cells)
+	}
+	return &Planner{
+		kind:    k,
+		nodes:   opt.Nodes,
+		setup:   opt.Setup,
+		m:       m,
+		gen:     1,
+		free:    make(chan *plannerSlot, opt.Workers),
+		workers: opt.Workers,
+	}, true
+}
+
+// NewVersionedPlanner creates a planner of kind k over v. Every search pins
+// the current snapshot of v, so v.Update never waits for searches and
+// searches never wait for v.Update; results carry the version they were
+// computed on. Edit and SetMap must not be used on such a planner.
+func NewVersionedPlanner(k Kind, v *grid.Versioned, opt PlannerOptions) (*Planner, bool) {
+	s := v.Acquire()
+	defer s.Release()
+	p, ok := NewPlanner(k, s.Map, opt)
+	if !ok {
+		return nil, false
+	}
+	p.m, p.versions = nil, v
+	return p, true
+}
+
+// Solve searches a path from start to end cell coordinates. It blocks while
+// all workspaces are busy or an edit is in progress.
+func (p *Planner) Solve(sx, sy, ex, ey int32) ([]grid.PathGrid, bool) {
+	r := p.SolveQuery(Query{SX: sx, SY: sy, EX: ex, EY: ey})
+	return r.Path, r.OK
+}
+
+// SolveQuery is like Solve but also returns the map version.
+func (p *Planner) SolveQuery(q Query) Result {
+	slot := p.acquire()
+	defer p.release(slot)
+	return p.solve(slot, q)
+}
+
+// SolveBatch answers every query using up to Workers goroutines and
+// returns the results in query order. Edits may run between two queries of
+// a batch.
+func (p *Planner) SolveBatch(qs []Query) []Result {
+	res := make([]Result, len(qs))
+	var (
+		next atomic.Int64
+		wg   sync.WaitGroup
+	)
+	for w := min(p.workers, len(qs)); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slot := p.acquire()
+			defer p.release(slot)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(qs) {
+					return
+				}
+				res[i] = p.solve(slot, qs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// Edit runs f with exclusive access to the map. f may modify the map in
+// place, e.g. call Set on a grid.Local or Block on an overlay layer.
+func (p *Planner) Edit(f func(m grid.Walkable)) {
+	if p.versions != nil {
+		panic("groute: Edit on a versioned planner")
+	}
+	p.fence.Lock()
+	defer p.fence.Unlock()
+	f(p.m)
+}
+
+// SetMap replaces the map once in-flight searches are done.
+func (p *Planner) SetMap(m grid.Walkable) {
+	if p.versions != nil {
+		panic("groute: SetMap on a versioned planner")
+	}
+	p.fence.Lock()
+	defer p.fence.Unlock()
+	p.m = m
+	p.gen++
+}
+
+func (p *Planner) solve(slot *plannerSlot, q Query) Result {
+	var r Result
+	if p.versions != nil {
+		s := p.versions.Acquire()
+		defer s.Release()
+		if slot.gen != s.Version {
+			slot.s.Reset(s.Map)
+			slot.gen = s.Version
+		}
+		r.Path, r.OK = slot.s.Solve(q.SX, q.SY, q.EX, q.EY)
+		r.Version = s.Version
+		return r
+	}
+	p.fence.RLock()
+	defer p.fence.RUnlock()
+	if slot.gen != p.gen {
+		slot.s.Reset(p.m)
+		slot.gen = p.gen
+	}
+	r.Path, r.OK = slot.s.Solve(q.SX, q.SY, q.EX, q.EY)
+	return r
+}
+
+// acquire takes a free workspace, creating one while fewer than Workers
+// exist.
+func (p *Planner) acquire() *plannerSlot {
+	select {
+	case slot := <-p.free:
+		return slot
+	default:
+	}
+	if n := p.created.Add(1); int(n) <= p.workers {
+		s, _ := NewSolver(p.kind, p.nodes)
+		if p.setup != nil {
+			p.setup(s)
+		}
+		return &plannerSlot{s: s}
+	}
+	p.created.Add(-1)
+	return <-p.free
+}
+
+func (p *Planner) release(slot *plannerSlot) {
+	p.free <- slot
+}
@@ -0,0 +1,190 @@
+package groute
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/sq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomMap(seed int64) *grid.Local {
+	rng := rand.New(rand.NewSource(seed))
+	m := grid.NewLocal(4, 4)
+	for i := int32(0); i < 4; i++ {
+		for j := int32(0); j < 4; j++ {
+			m.SetGrid(i, j, new(grid.Grid))
+		}
+	}
+	for k := 0; k < 900; k++ {
+		m.Set(rng.Int31n(64), rng.Int31n(64))
+	}
+	return m
+}
+
+func randomQueries(seed int64, m grid.Walkable, n int) []Query {
+	rng := rand.New(rand.NewSource(seed))
+	qs := make([]Query, 0, n)
+	for len(qs) < n {
+		q := Query{SX: rng.Int31n(64), SY: rng.Int31n(64), EX: rng.Int31n(64), EY: rng.Int31n(64)}
+		if m.Available(q.SX, q.SY) && m.Available(q.EX, q.EY) {
+			qs = append(qs, q)
+		}
+	}
+	return qs
+}
+
+func TestPlanner_SolveBatch(t *testing.T) {
+	m := randomMap(1)
+	qs := randomQueries(2, m, 200)
+	for _, k := range []Kind{Square, Hex} {
+		var setups atomic.Int32
+		p, ok := NewPlanner(k, m, PlannerOptions{
+			Workers: 4,
+			Setup:   func(Solver) { setups.Add(1) },
+		})
+		require.True(t, ok)
+
+		got := p.SolveBatch(qs)
+		ref, _ := NewSolver(k, 64*64)
+		ref.Reset(m)
+		for i, q := range qs {
+			want, ok := ref.Solve(q.SX, q.SY, q.EX, q.EY)
+			assert.Equal(t, ok, got[i].OK, "%s query %d", k, i)
+			assert.Equal(t, len(want) > 0, len(got[i].Path) > 0, "%s query %d", k, i)
+		}
+		assert.LessOrEqual(t, setups.Load(), int32(4), "workspaces are bounded")
+	}
+	_, ok := NewPlanner(Kind(9), m, PlannerOptions{})
+	assert.False(t, ok)
+}
+
+// 超大地图（如 World）必须显式指定 Nodes
+func TestPlanner_Nodes(t *testing.T) {
+	world := grid.NewWorld(grid.WorldOptions{
+		Bounds: grid.Rect{MinX: -1024, MinY: -1024, MaxX: 1024, MaxY: 1024},
+		Loader: func(bx, by int32) (*grid.Grid, bool) { return nil, true },
+	})
+	_, ok := NewPlanner(Square, world, PlannerOptions{})
+	assert.False(t, ok)
+
+	p, ok := NewPlanner(Square, world, PlannerOptions{Nodes: 4096, Workers: 1})
+	require.True(t, ok)
+	path, ok := p.Solve(-100, -100, 100, 50)
+	assert.True(t, ok)
+	assert.NotEmpty(t, path)
+}
+
+func TestPlanner_Setup(t *testing.T) {
+	m := randomMap(3)
+	p, _ := NewPlanner(Square, m, PlannerOptions{
+		Workers: 2,
+		Setup:   func(s Solver) { s.(*sq.WorkSpace).SetCostModel(sq.CostOctile) },
+	})
+	q := randomQueries(4, m, 1)[0]
+	_, ok := p.Solve(q.SX, q.SY, q.EX, q.EY)
+	assert.True(t, ok)
+}
+
+// 并发编辑与搜索：用 -race 运行时可以发现未加隔离的读写
+func TestPlanner_EditFence(t *testing.T) {
+	m := randomMap(5)
+	units := grid.NewLayer()
+	p, _ := NewPlanner(Square, grid.NewOverlay(m, units), PlannerOptions{Workers: 4})
+	qs := randomQueries(6, m, 50)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rng := rand.New(rand.NewSource(7))
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			x, y := rng.Int31n(64), rng.Int31n(64)
+			p.Edit(func(grid.Walkable) { units.Block(x, y, 1) })
+			p.Edit(func(grid.Walkable) { units.Unblock(x, y, 1) })
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		p.SolveBatch(qs)
+	}
+	close(stop)
+	wg.Wait()
+
+	// 编辑完成后的查询能看到新的障碍
+	q := qs[0]
+	p.Edit(func(grid.Walkable) { units.Block(q.EX, q.EY, 2) })
+	_, ok := p.Solve(q.SX, q.SY, q.EX, q.EY)
+	assert.False(t, ok)
+}
+
+func TestPlanner_SetMap(t *testing.T) {
+	m := randomMap(8)
+	p, _ := NewPlanner(Hex, m, PlannerOptions{Workers: 2})
+	q := randomQueries(9, m, 1)[0]
+
+	closed := grid.FuncMap{R: m.Bounds(), F: func(x, y int32) bool { return false }}
+	p.SetMap(closed)
+	res := p.SolveBatch([]Query{q, q, q})
+	for _, r := range res {
+		assert.False(t, r.OK)
+	}
+	p.SetMap(m)
+	_, ok := p.Solve(q.SX, q.SY, q.EX, q.EY)
+	assert.Equal(t, p.SolveBatch([]Query{q})[0].OK, ok)
+}
+
+// 版本化地图：写入不等待搜索，结果带有计算时的版本
+func TestPlanner_Versioned(t *testing.T) {
+	v := grid.NewVersioned(randomMap(10))
+	p, ok := NewVersionedPlanner(Square, v, PlannerOptions{Workers: 4})
+	require.True(t, ok)
+	s := v.Acquire()
+	qs := randomQueries(11, s.Map, 50)
+	s.Release()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rng := rand.New(rand.NewSource(12))
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			x, y := rng.Int31n(64), rng.Int31n(64)
+			v.Update(func(tx *grid.Tx) { tx.Set(x, y, !tx.Available(x, y)) })
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		for _, r := range p.SolveBatch(qs) {
+			assert.NotZero(t, r.Version)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	assert.Equal(t, 1, v.Live(), "old versions are released")
+
+	q := qs[0]
+	before := p.SolveQuery(q)
+	assert.Equal(t, v.Version(), before.Version)
+	v.Update(func(tx *grid.Tx) { tx.Set(q.EX, q.EY, true) })
+	assert.Less(t, before.Version, v.Version(), "path is stale")
+	after := p.SolveQuery(q)
+	assert.False(t, after.OK)
+	assert.Equal(t, v.Version(), after.Version)
+
+	assert.Panics(t, func() { p.SetMap(randomMap(1)) })
+}
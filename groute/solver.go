@@ -0,0 +1,67 @@
+package groute
+
+import (
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/hex"
+	"github.com/legamerdc/pathfinding/groute/sq"
+)
+
+// Solver is the common interface of the grid path finders.
+//
+// A Solver keeps reusable search state and is not safe for concurrent use.
+type Solver interface {
+	// Reset binds the solver to a map, e.g. a *grid.Local or *grid.Overlay,
+	// before running Solve.
+	Reset(m grid.Walkable)
+	// Solve searches a path from start to end cell coordinates.
+	Solve(sx, sy, ex, ey int32) ([]grid.PathGrid, bool)
+}
+
+var (
+	_ Solver = (*sq.WorkSpace)(nil)
+	_ Solver = (*hex.WorkSpace)(nil)
+)
+
+// Kind selects a grid topology.
+type Kind uint8
+
+const (
+	Square Kind = iota
+	Hex
+)
+
+// String returns the configuration name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case Square:
+		return "sq"
+	case Hex:
+		return "hex"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseKind parses a configuration name produced by Kind.String.
+func ParseKind(s string) (Kind, bool) {
+	switch s {
+	case "sq", "square":
+		return Square, true
+	case "hex":
+		return Hex, true
+	default:
+		return 0, false
+	}
+}
+
+// NewSolver creates a solver of kind k with capacity for size search nodes.
+func NewSolver(k Kind, size int) (Solver, bool) {
+	switch k {
+	case Square:
+		return sq.NewWorkSpace(size), true
+	case Hex:
+		return hex.NewWorkSpace(size), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,44 @@
+package groute
+
+import (
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKind(t *testing.T) {
+	for _, k := range []Kind{Square, Hex} {
+		got, ok := ParseKind(k.String())
+		require.True(t, ok)
+		assert.Equal(t, k, got)
+	}
+	_, ok := ParseKind("tri")
+	assert.False(t, ok)
+}
+
+func TestNewSolver(t *testing.T) {
+	m := grid.NewLocal(2, 2)
+	for i := int32(0); i < 2; i++ {
+		for j := int32(0); j < 2; j++ {
+			m.SetGrid(i, j, new(grid.Grid))
+		}
+	}
+	for y := int32(0); y < 30; y++ {
+		m.Set(10, y)
+	}
+
+	for _, k := range []Kind{Square, Hex} {
+		s, ok := NewSolver(k, 1024)
+		require.True(t, ok)
+		s.Reset(m)
+		path, ok := s.Solve(0, 0, 20, 0)
+		require.True(t, ok, k.String())
+		assert.Equal(t, grid.PathGrid{X: 0, Y: 0}, path[0])
+		assert.Equal(t, grid.PathGrid{X: 20, Y: 0}, path[len(path)-1])
+	}
+
+	_, ok := NewSolver(Kind(9), 16)
+	assert.False(t, ok)
+}
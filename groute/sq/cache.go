@@ -0,0 +1,208 @@
+package sq
+
+import (
+	"container/list"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/jps"
+)
+
+// CacheStats counts PathCache activity.
+type CacheStats struct {
+	Entries     int
+	Hits        int64
+	Misses      int64
+	Evictions   int64 // entries dropped for capacity
+	Invalidated int64 // entries dropped by map edits
+}
+
+// PathCache is an LRU cache of paths in front of a WorkSpace, keyed by the
+// endpoints and the solver options.
+//
+// Every entry records the 16x16 blocks touched by its path and the corridor
+// around it; Invalidate evicts the entries of edited blocks. Edits that open
+// a shortcut elsewhere do not evict anything, so a cached path stays valid
+// but may no longer be the shortest. Failed searches are not cached.
+type PathCache struct {
+	ws      *WorkSpace
+	cap     int
+	entries map[cacheKey]*list.Element
+	lru     list.List // front is most recently used
+	blocks  map[grid.Gpos]map[*cacheEntry]struct{}
+	stats   CacheStats
+	resets  uint64 // WorkSpace.Reset calls the entries were found under
+}
+
+type cacheKey struct {
+	natural        bool
+	sx, sy, ex, ey float64
+	cost           CostModel
+	queue          jps.QueueKind
+	bidir          bool
+	weight         float64
+	landmarks      *jps.Landmarks
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	path    []grid.PathGrid
+	natural []grid.PathPoint
+	blocks  []grid.Gpos
+}
+
+// NewPathCache creates a cache of up to capacity paths over ws.
+func NewPathCache(ws *WorkSpace, capacity int) *PathCache {
+	c := &PathCache{ws: ws, cap: max(capacity, 1), resets: ws.resets}
+	c.Clear()
+	return c
+}
+
+// WorkSpace returns the cached workspace. Its options may be changed
+// directly, they are part of the key; binding it to another map with
+// WorkSpace.Reset drops every entry on the next lookup.
+func (c *PathCache) WorkSpace() *WorkSpace {
+	return c.ws
+}
+
+// Reset binds the workspace to another map and drops every entry.
+func (c *PathCache) Reset(m grid.Walkable) {
+	c.ws.Reset(m)
+	c.Clear()
+	c.resets = c.ws.resets
+}
+
+// Solve is WorkSpace.Solve through the cache. The returned path is a copy.
+func (c *PathCache) Solve(sx, sy, ex, ey int32) ([]grid.PathGrid, bool) {
+	key := c.key(false, float64(sx), float64(sy), float64(ex), float64(ey))
+	if e := c.get(key); e != nil {
+		return append([]grid.PathGrid(nil), e.path...), true
+	}
+	p, ok := c.ws.Solve(sx, sy, ex, ey)
+	if !ok {
+		return nil, false
+	}
+	c.put(&cacheEntry{key: key, path: p}, p)
+	return append([]grid.PathGrid(nil), p...), true
+}
+
+// SolveNatural is WorkSpace.SolveNatural through the cache. The returned
+// path is a copy.
+func (c *PathCache) SolveNatural(sx, sy, ex, ey float64) ([]grid.PathPoint, bool) {
+	key := c.key(true, sx, sy, ex, ey)
+	if e := c.get(key); e != nil {
+		return append([]grid.PathPoint(nil), e.natural...), true
+	}
+	p, gp, ok := c.ws.solveNatural(sx, sy, ex, ey)
+	if !ok {
+		return nil, false
+	}
+	c.put(&cacheEntry{key: key, natural: p}, gp)
+	return append([]grid.PathPoint(nil), p...), true
+}
+
+// Invalidate evicts the entries touching any of the given blocks and
+// invalidates the workspace's landmarks. Call it with the blocks of every
+// edit, e.g. grid.Snapshot.Changed.
+func (c *PathCache) Invalidate(blocks ...grid.Gpos) {
+	if l := c.ws.landmarks; l != nil && len(blocks) > 0 {
+		l.Invalidate()
+	}
+	for _, b := range blocks {
+		for e := range c.blocks[b] {
+			c.remove(e)
+			c.stats.Invalidated++
+		}
+	}
+}
+
+// InvalidateCell evicts the entries touching the block of cell (x, y).
+func (c *PathCache) InvalidateCell(x, y int32) {
+	c.Invalidate(grid.Gpos{X: x >> 4, Y: y >> 4})
+}
+
+// Clear drops every entry. Counters are kept.
+func (c *PathCache) Clear() {
+	c.entries = make(map[cacheKey]*list.Element)
+	c.blocks = make(map[grid.Gpos]map[*cacheEntry]struct{})
+	c.lru.Init()
+}
+
+// Stats returns the cache counters.
+func (c *PathCache) Stats() CacheStats {
+	s := c.stats
+	s.Entries = c.lru.Len()
+	return s
+}
+
+func (c *PathCache) key(natural bool, sx, sy, ex, ey float64) cacheKey {
+	if c.resets != c.ws.resets {
+		// the workspace was bound to another map behind the cache's back
+		c.Clear()
+		c.resets = c.ws.resets
+	}
+	k := cacheKey{
+		natural: natural,
+		sx:      sx, sy: sy, ex: ex, ey: ey,
+		cost:   c.ws.topo.cost,
+		queue:  c.ws.queue,
+		bidir:  c.ws.search.Bidirectional(),
+		weight: c.ws.search.Weight(),
+	}
+	if l := c.ws.landmarks; l != nil && l.Valid() {
+		k.landmarks = l
+	}
+	return k
+}
+
+func (c *PathCache) get(key cacheKey) *cacheEntry {
+	el := c.entries[key]
+	if el == nil {
+		c.stats.Misses++
+		return nil
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+// put adds e, recording the blocks of the corridor around the jump points p.
+func (c *PathCache) put(e *cacheEntry, p []grid.PathGrid) {
+	for c.lru.Len() >= c.cap {
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+		c.stats.Evictions++
+	}
+	seen := make(map[grid.Gpos]struct{})
+	for _, cell := range expandGridPath(p) {
+		// the corridor is the path dilated by one cell
+		for dx := int32(-1); dx <= 1; dx++ {
+			for dy := int32(-1); dy <= 1; dy++ {
+				b := grid.Gpos{X: (cell.X + dx) >> 4, Y: (cell.Y + dy) >> 4}
+				if _, ok := seen[b]; ok {
+					continue
+				}
+				seen[b] = struct{}{}
+				e.blocks = append(e.blocks, b)
+				if c.blocks[b] == nil {
+					c.blocks[b] = make(map[*cacheEntry]struct{})
+				}
+				c.blocks[b][e] = struct{}{}
+			}
+		}
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+}
+
+func (c *PathCache) remove(e *cacheEntry) {
+	el := c.entries[e.key]
+	if el == nil {
+		return
+	}
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	for _, b := range e.blocks {
+		delete(c.blocks[b], e)
+		if len(c.blocks[b]) == 0 {
+			delete(c.blocks, b)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package sq
+
+import (
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/jps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathCache_HitMiss(t *testing.T) {
+	m := createTestGrid(64, 64)
+	ws := NewWorkSpace(64 * 64)
+	ws.Reset(m)
+	c := NewPathCache(ws, 2)
+
+	p1, ok := c.Solve(1, 1, 10, 1)
+	require.True(t, ok)
+	p2, ok := c.Solve(1, 1, 10, 1)
+	require.True(t, ok)
+	assert.Equal(t, p1, p2)
+	p2[0].X = 99 // 返回的是副本
+	p3, _ := c.Solve(1, 1, 10, 1)
+	assert.Equal(t, p1, p3)
+	assert.Equal(t, CacheStats{Entries: 1, Hits: 2, Misses: 1}, c.Stats())
+
+	// 求解选项不同则键不同
+	ws.SetCostModel(CostOctile)
+	c.Solve(1, 1, 10, 1)
+	ws.SetQueue(jps.QueueQuadHeap)
+	c.Solve(1, 1, 10, 1)
+	s := c.Stats()
+	assert.Equal(t, int64(3), s.Misses)
+	assert.Equal(t, int64(1), s.Evictions, "capacity 2")
+	assert.Equal(t, 2, s.Entries)
+
+	// 自然路径与网格路径分开缓存，失败不缓存
+	n1, ok := c.SolveNatural(1.5, 1.5, 40.5, 40.5)
+	require.True(t, ok)
+	n2, _ := c.SolveNatural(1.5, 1.5, 40.5, 40.5)
+	assert.Equal(t, n1, n2)
+	_, ok = c.Solve(1, 1, 64, 64)
+	assert.False(t, ok)
+	_, ok = c.Solve(1, 1, 64, 64)
+	assert.False(t, ok)
+	s = c.Stats()
+	assert.Equal(t, int64(3), s.Hits)
+	assert.Equal(t, int64(6), s.Misses)
+
+	// 双向搜索同样区分：第一次命中，开启双向后未命中
+	c.Solve(1, 1, 10, 1)
+	ws.SetBidirectional(true)
+	c.Solve(1, 1, 10, 1)
+	s = c.Stats()
+	assert.Equal(t, int64(4), s.Hits)
+	assert.Equal(t, int64(7), s.Misses)
+
+	// 权重不同则键不同，w <= 1 都是最优搜索
+	ws.SetBidirectional(false)
+	ws.SetWeight(0)
+	c.Solve(1, 1, 10, 1)
+	ws.SetWeight(2)
+	c.Solve(1, 1, 10, 1)
+	s = c.Stats()
+	assert.Equal(t, int64(5), s.Hits)
+	assert.Equal(t, int64(8), s.Misses)
+}
+
+// 通过 WorkSpace() 直接换图后，旧地图上的路径不能再命中
+func TestPathCache_Rebind(t *testing.T) {
+	m := createTestGrid(64, 64)
+	ws := NewWorkSpace(64 * 64)
+	ws.Reset(m)
+	c := NewPathCache(ws, 8)
+	_, ok := c.Solve(1, 1, 10, 1)
+	require.True(t, ok)
+
+	walled := createTestGrid(64, 64)
+	for y := int32(0); y < 64; y++ {
+		walled.Set(5, y)
+	}
+	c.WorkSpace().Reset(walled)
+	_, ok = c.Solve(1, 1, 10, 1)
+	assert.False(t, ok)
+	assert.Equal(t, CacheStats{Misses: 2}, c.Stats())
+
+	c.Reset(m)
+	_, ok = c.Solve(1, 1, 10, 1)
+	assert.True(t, ok)
+}
+
+func TestPathCache_Invalidate(t *testing.T) {
+	m := createTestGrid(64, 64)
+	ws := NewWorkSpace(64 * 64)
+	ws.Reset(m)
+	c := NewPathCache(ws, 16)
+
+	c.Solve(1, 1, 10, 1)    // 块 (0,0)
+	c.Solve(40, 40, 60, 40) // 块 (2,2) (3,2)
+	c.Solve(1, 15, 10, 15)  // 通道延伸到块 (0,0) 下方的 (0,1)
+	c.SolveNatural(40.5, 40.5, 60.5, 40.5)
+	require.Equal(t, 4, c.Stats().Entries)
+
+	// 编辑远处的块不影响任何条目
+	c.InvalidateCell(20, 60)
+	assert.Equal(t, 4, c.Stats().Entries)
+
+	// 编辑 (0,1) 块：只有通道触及它的条目被驱逐
+	m.Set(5, 16)
+	c.InvalidateCell(5, 16)
+	s := c.Stats()
+	assert.Equal(t, 3, s.Entries)
+	assert.Equal(t, int64(1), s.Invalidated)
+
+	c.Invalidate(grid.Gpos{X: 3, Y: 2})
+	assert.Equal(t, 1, c.Stats().Entries)
+
+	// 与版本化地图配合
+	v := grid.NewVersioned(createTestGrid(64, 64))
+	snap := v.Acquire()
+	c.Reset(snap.Map)
+	snap.Release()
+	c.Solve(1, 1, 10, 1)
+	up := v.Update(func(tx *grid.Tx) { tx.Set(5, 1, true) })
+	c.Reset(up.Map)
+	assert.Equal(t, 0, c.Stats().Entries)
+	c.Solve(1, 1, 10, 1)
+	c.Invalidate(up.Changed...)
+	assert.Equal(t, 0, c.Stats().Entries)
+
+	// 地标表格参与缓存键，编辑时随缓存一起失效
+	l := ws.BuildLandmarks(4)
+	c.Solve(1, 1, 10, 1)
+	ws.SetLandmarks(l)
+	c.Solve(1, 1, 10, 1)
+	assert.Equal(t, 2, c.Stats().Entries)
+	c.Solve(1, 1, 10, 1)
+	assert.Equal(t, 2, c.Stats().Entries)
+	c.InvalidateCell(60, 60)
+	assert.False(t, l.Valid())
+	assert.Equal(t, 2, c.Stats().Entries)
+	c.Solve(1, 1, 10, 1) // 失效的表格不再参与估价，与无地标时相同
+	assert.Equal(t, 2, c.Stats().Entries)
+}
@@ -0,0 +1,436 @@
+package sq
+
+import (
+	"slices"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// TimedCell is a step of a timed path: the agent enters cell (X, Y) at tick
+// T and stays there until the tick of the next step. It stays on the last
+// cell of a path.
+type TimedCell struct {
+	X, Y, T int32
+}
+
+// Agent is a planning request of Coop. Agents must start on free cells.
+type Agent struct {
+	ID         int32
+	Start, End grid.PathGrid
+}
+
+// Reservations is a space-time reservation table: which agent occupies a
+// cell at a tick. An agent occupies the cells of its timed path between
+// their ticks and its last cell from then on. Agents swapping their cells
+// or crossing diagonally through the same 2x2 square are detected from the
+// cells as well.
+type Reservations struct {
+	cells   map[TimedCell]int32
+	parked  map[grid.PathGrid]parking
+	paths   map[int32][]TimedCell
+	horizon int32 // the last tick of any reserved cell
+}
+
+type parking struct {
+	agent int32
+	from  int32
+}
+
+// NewReservations creates an empty table.
+func NewReservations() *Reservations {
+	return &Reservations{
+		cells:  make(map[TimedCell]int32),
+		parked: make(map[grid.PathGrid]parking),
+		paths:  make(map[int32][]TimedCell),
+	}
+}
+
+// Reserve reserves the timed path of agent, replacing its previous
+// reservations.
+func (r *Reservations) Reserve(agent int32, path []TimedCell) {
+	r.Release(agent)
+	if len(path) == 0 {
+		return
+	}
+	for i := 0; i < len(path)-1; i++ {
+		p := path[i]
+		for t := p.T; t < path[i+1].T; t++ {
+			r.cells[TimedCell{X: p.X, Y: p.Y, T: t}] = agent
+		}
+	}
+	last := path[len(path)-1]
+	r.parked[grid.PathGrid{X: last.X, Y: last.Y}] = parking{agent: agent, from: last.T}
+	r.paths[agent] = path
+	r.horizon = max(r.horizon, last.T)
+}
+
+// Release drops the reservations of agent.
+func (r *Reservations) Release(agent int32) {
+	path, ok := r.paths[agent]
+	if !ok {
+		return
+	}
+	for i := 0; i < len(path)-1; i++ {
+		p := path[i]
+		for t := p.T; t < path[i+1].T; t++ {
+			delete(r.cells, TimedCell{X: p.X, Y: p.Y, T: t})
+		}
+	}
+	last := grid.PathGrid{X: path[len(path)-1].X, Y: path[len(path)-1].Y}
+	if r.parked[last].agent == agent {
+		delete(r.parked, last)
+	}
+	delete(r.paths, agent)
+}
+
+// Owner returns the agent occupying (x, y) at tick t.
+func (r *Reservations) Owner(x, y, t int32) (int32, bool) {
+	if a, ok := r.cells[TimedCell{X: x, Y: y, T: t}]; ok {
+		return a, true
+	}
+	if p, ok := r.parked[grid.PathGrid{X: x, Y: y}]; ok && p.from <= t {
+		return p.agent, true
+	}
+	return 0, false
+}
+
+// Path returns the reserved path of agent.
+func (r *Reservations) Path(agent int32) []TimedCell {
+	return r.paths[agent]
+}
+
+// Clear drops all reservations.
+func (r *Reservations) Clear() {
+	clear(r.cells)
+	clear(r.parked)
+	clear(r.paths)
+	r.horizon = 0
+}
+
+// Coop plans collision free paths for several agents on the square grid
+// with Windowed Hierarchical Cooperative A*: agents are planned one after
+// another in priority order by a space-time A* that avoids the cells
+// reserved by the agents before them, and reserve their own paths. Every
+// move and every wait takes one tick. The heuristic is the true distance to
+// the goal ignoring other agents, from a reverse search per agent that is
+// resumed on demand and kept between plans.
+//
+// With a Window of w ticks only the next w ticks are planned, and agents
+// should plan again, e.g. after w/2 ticks. With no window each agent is
+// planned to its goal where it stays, which is Cooperative A*.
+//
+// Moves and corner rules are those of Solve. A move costs its distance under
+// the cost model, a wait one straight step, and waiting at the goal nothing.
+// The map must not change while a Coop is in use, see Reset.
+type Coop struct {
+	Map   grid.Walkable
+	Table *Reservations
+	// Window is the number of ticks planned ahead, 0 for no window.
+	Window int32
+	// MaxTicks bounds the ticks searched without a window. 0 means 1024.
+	MaxTicks int32
+	// MaxNodes bounds the space-time nodes expanded per agent and plan.
+	// 0 means 1 << 16.
+	MaxNodes int
+
+	topo  topology
+	rra   map[int32]*rra
+	nodes map[TimedCell]*stNode
+	open  *heap.Heap[*stNode]
+}
+
+type stNode struct {
+	TimedCell
+	g, f   int32
+	parent *stNode
+	closed bool
+	index  int32
+}
+
+// Compare implements heap.Node, preferring later ticks on ties.
+func (n *stNode) Compare(o *stNode) int32 {
+	if n.f != o.f {
+		return n.f - o.f
+	}
+	return o.T - n.T
+}
+
+// GetHeapIndex implements heap.Node.
+func (n *stNode) GetHeapIndex() int32 { return n.index }
+
+// SetHeapIndex implements heap.Node.
+func (n *stNode) SetHeapIndex(i int32) { n.index = i }
+
+// NewCoop creates a planner on m with an empty reservation table.
+func NewCoop(m grid.Walkable, window int32) *Coop {
+	c := &Coop{
+		Table:  NewReservations(),
+		Window: window,
+		rra:    make(map[int32]*rra),
+		nodes:  make(map[TimedCell]*stNode),
+		open:   heap.NewHeap[*stNode](64),
+	}
+	c.Reset(m)
+	return c
+}
+
+// Reset binds the planner to a map and drops the cached distances, but not
+// the reservations.
+func (c *Coop) Reset(m grid.Walkable) {
+	c.Map = m
+	c.topo.reset(m)
+	clear(c.rra)
+}
+
+// SetCostModel selects the cost model of moves.
+func (c *Coop) SetCostModel(m CostModel) {
+	c.topo.cost = m
+	clear(c.rra)
+}
+
+// Plan plans the agents in the given priority order from tick t. It first
+// releases their reservations, then plans and reserves each one. An agent
+// without a path reserves its start from t on and its ok is false; agents
+// planned before it did not know and may run into it. Without a window an
+// agent whose goal is the last cell of another agent's path fails at once.
+func (c *Coop) Plan(t int32, agents []Agent) (paths [][]TimedCell, ok []bool) {
+	for _, a := range agents {
+		c.Table.Release(a.ID)
+	}
+	paths, ok = make([][]TimedCell, len(agents)), make([]bool, len(agents))
+	for i, a := range agents {
+		paths[i], ok[i] = c.plan(t, a)
+		c.Table.Reserve(a.ID, paths[i])
+	}
+	return paths, ok
+}
+
+// Forget releases the reservations of an agent and drops its distances.
+func (c *Coop) Forget(agent int32) {
+	c.Table.Release(agent)
+	delete(c.rra, agent)
+}
+
+func (c *Coop) plan(t0 int32, a Agent) ([]TimedCell, bool) {
+	c.topo.rows.Invalidate()
+	stay := []TimedCell{{X: a.Start.X, Y: a.Start.Y, T: t0}}
+	r := c.rra[a.ID]
+	if r == nil || r.goal != a.End {
+		r = newRRA(&c.topo, a.End, a.Start)
+		c.rra[a.ID] = r
+	}
+	h, ok := r.dist(a.Start)
+	if !ok {
+		return stay, false
+	}
+	limit := c.Window
+	if limit <= 0 {
+		limit = c.MaxTicks
+		if limit <= 0 {
+			limit = 1024
+		}
+	}
+	budget := c.MaxNodes
+	if budget <= 0 {
+		budget = 1 << 16
+	}
+	unit := c.topo.Dist(0, 0, 1, 0)
+
+	clear(c.nodes)
+	c.open.Clear()
+	// the goal stays occupied forever, waiting for it is hopeless
+	if k, ok := c.Table.parked[a.End]; ok && k.agent != a.ID && c.Window <= 0 {
+		return stay, false
+	}
+	start := c.node(TimedCell{X: a.Start.X, Y: a.Start.Y, T: t0})
+	start.g, start.f = 0, h
+	c.open.Push(start)
+	for expanded := 0; !c.open.Empty() && expanded < budget; expanded++ {
+		n := c.open.Pop()
+		n.closed = true
+		at := grid.PathGrid{X: n.X, Y: n.Y}
+		if c.Window > 0 && n.T-t0 == c.Window || c.Window <= 0 && at == a.End && c.free(a.ID, at, n.T) {
+			return c.timed(n), true
+		}
+		if n.T-t0 >= limit {
+			continue
+		}
+		wait := unit
+		if at == a.End {
+			wait = 0
+		}
+		c.expand(a.ID, r, n, n.X, n.Y, wait)
+		for d := int32(0); d < 8; d++ {
+			if x, y, ok := c.topo.Step(n.X, n.Y, d); ok {
+				c.expand(a.ID, r, n, x, y, c.topo.Dist(n.X, n.Y, x, y))
+			}
+		}
+	}
+	return stay, false
+}
+
+// expand offers the move of agent from n to (x, y) at the next tick.
+func (c *Coop) expand(agent int32, r *rra, n *stNode, x, y, cost int32) {
+	t := n.T + 1
+	if other, ok := c.Table.Owner(x, y, t); ok && other != agent {
+		return
+	}
+	// agents swapping their cells would pass through each other
+	if other, ok := c.Table.Owner(x, y, n.T); ok && other != agent {
+		if o, ok := c.Table.Owner(n.X, n.Y, t); ok && o == other {
+			return
+		}
+	}
+	// as would agents moving along both diagonals of a 2x2 square
+	if x != n.X && y != n.Y {
+		if other, ok := c.Table.Owner(x, n.Y, n.T); ok && other != agent {
+			if o, ok := c.Table.Owner(n.X, y, t); ok && o == other {
+				return
+			}
+		}
+		if other, ok := c.Table.Owner(n.X, y, n.T); ok && other != agent {
+			if o, ok := c.Table.Owner(x, n.Y, t); ok && o == other {
+				return
+			}
+		}
+	}
+	h, ok := r.dist(grid.PathGrid{X: x, Y: y})
+	if !ok {
+		return
+	}
+	m := c.node(TimedCell{X: x, Y: y, T: t})
+	g := n.g + cost
+	if m.closed || g >= m.g {
+		return
+	}
+	m.g, m.f, m.parent = g, g+h, n
+	if c.open.Contains(m) {
+		c.open.Fix(m)
+	} else {
+		c.open.Push(m)
+	}
+}
+
+// free reports whether no other agent occupies p from tick t on.
+func (c *Coop) free(agent int32, p grid.PathGrid, t int32) bool {
+	for ; t <= c.Table.horizon; t++ {
+		if other, ok := c.Table.Owner(p.X, p.Y, t); ok && other != agent {
+			return false
+		}
+	}
+	if k, ok := c.Table.parked[p]; ok && k.agent != agent {
+		return false
+	}
+	return true
+}
+
+func (c *Coop) node(p TimedCell) *stNode {
+	n, ok := c.nodes[p]
+	if !ok {
+		n = &stNode{TimedCell: p, g: 1<<31 - 1, index: -1}
+		c.nodes[p] = n
+	}
+	return n
+}
+
+// timed returns the path ending at n with one step per entered cell.
+func (c *Coop) timed(n *stNode) []TimedCell {
+	var p []TimedCell
+	for ; n != nil; n = n.parent {
+		if n.parent == nil || n.parent.X != n.X || n.parent.Y != n.Y {
+			p = append(p, n.TimedCell)
+		}
+	}
+	slices.Reverse(p)
+	return p
+}
+
+// rra is a reverse resumable A*: it searches from the goal towards the start
+// of an agent and resumes when asked for the distance of a cell it has not
+// closed yet. Closed cells have their exact distance to the goal, because the
+// heuristic is consistent.
+type rra struct {
+	topo   *topology
+	goal   grid.PathGrid
+	target grid.PathGrid // the cell the heuristic leads to
+	nodes  map[grid.PathGrid]*rraNode
+	open   *heap.Heap[*rraNode]
+}
+
+type rraNode struct {
+	p      grid.PathGrid
+	g, f   int32
+	closed bool
+	index  int32
+}
+
+// Compare implements heap.Node.
+func (n *rraNode) Compare(o *rraNode) int32 {
+	if n.f != o.f {
+		return n.f - o.f
+	}
+	return o.g - n.g
+}
+
+// GetHeapIndex implements heap.Node.
+func (n *rraNode) GetHeapIndex() int32 { return n.index }
+
+// SetHeapIndex implements heap.Node.
+func (n *rraNode) SetHeapIndex(i int32) { n.index = i }
+
+func newRRA(t *topology, goal, target grid.PathGrid) *rra {
+	r := &rra{
+		topo:   t,
+		goal:   goal,
+		target: target,
+		nodes:  make(map[grid.PathGrid]*rraNode),
+		open:   heap.NewHeap[*rraNode](64),
+	}
+	if t.available(goal.X, goal.Y) {
+		n := &rraNode{p: goal, f: t.Dist(goal.X, goal.Y, target.X, target.Y), index: -1}
+		r.nodes[goal] = n
+		r.open.Push(n)
+	}
+	return r
+}
+
+// dist returns the distance from p to the goal, ok == false if there is no
+// path.
+func (r *rra) dist(p grid.PathGrid) (int32, bool) {
+	if n, ok := r.nodes[p]; ok && n.closed {
+		return n.g, true
+	}
+	for !r.open.Empty() {
+		n := r.open.Pop()
+		n.closed = true
+		// moves are symmetric between free cells, so stepping away from n
+		// finds the cells stepping onto it
+		for d := int32(0); d < 8; d++ {
+			x, y, ok := r.topo.Step(n.p.X, n.p.Y, d)
+			if !ok {
+				continue
+			}
+			q := grid.PathGrid{X: x, Y: y}
+			m, seen := r.nodes[q]
+			if !seen {
+				m = &rraNode{p: q, g: 1<<31 - 1, index: -1}
+				r.nodes[q] = m
+			}
+			g := n.g + r.topo.Dist(n.p.X, n.p.Y, x, y)
+			if m.closed || g >= m.g {
+				continue
+			}
+			m.g, m.f = g, g+r.topo.Dist(x, y, r.target.X, r.target.Y)
+			if r.open.Contains(m) {
+				r.open.Fix(m)
+			} else {
+				r.open.Push(m)
+			}
+		}
+		if n.p == p {
+			return n.g, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,245 @@
+package sq
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 返回路径在 t 时刻所在的格子
+func cellAt(path []TimedCell, t int32) grid.PathGrid {
+	p := path[0]
+	for _, s := range path {
+		if s.T > t {
+			break
+		}
+		p = s
+	}
+	return grid.PathGrid{X: p.X, Y: p.Y}
+}
+
+// 检查任意两个智能体既不同时占据同一格，也不对穿或在 2x2 方格内交叉斜穿
+func checkConflicts(t *testing.T, paths [][]TimedCell, from, to int32) {
+	t.Helper()
+	for tick := from; tick <= to; tick++ {
+		for i := range paths {
+			for j := i + 1; j < len(paths); j++ {
+				a, b := cellAt(paths[i], tick), cellAt(paths[j], tick)
+				require.NotEqual(t, a, b, "agents %d and %d meet at %v, tick %d", i, j, a, tick)
+				if tick > from {
+					a0, b0 := cellAt(paths[i], tick-1), cellAt(paths[j], tick-1)
+					require.False(t, a == b0 && b == a0, "agents %d and %d swap at tick %d", i, j, tick)
+					require.False(t, crosses(a0, a, b0, b), "agents %d and %d cross at tick %d", i, j, tick)
+				}
+			}
+		}
+	}
+}
+
+// crosses 判断 a0->a1 与 b0->b1 是否是同一个 2x2 方格的两条对角线
+func crosses(a0, a1, b0, b1 grid.PathGrid) bool {
+	if a0.X == a1.X || a0.Y == a1.Y {
+		return false
+	}
+	p, q := grid.PathGrid{X: a1.X, Y: a0.Y}, grid.PathGrid{X: a0.X, Y: a1.Y}
+	return b0 == p && b1 == q || b0 == q && b1 == p
+}
+
+// 检查相邻步骤是一次合法移动，等待体现为时间间隔
+func checkMoves(t *testing.T, ws *WorkSpace, path []TimedCell) int32 {
+	t.Helper()
+	var cost int32
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		require.Greater(t, b.T, a.T)
+		require.Equal(t, -1, ws.Validate([]grid.PathGrid{{X: a.X, Y: a.Y}, {X: b.X, Y: b.Y}}))
+		require.LessOrEqual(t, max(b.X-a.X, a.X-b.X, b.Y-a.Y, a.Y-b.Y), int32(1))
+		cost += ws.topo.Dist(a.X, a.Y, b.X, b.Y)
+	}
+	return cost
+}
+
+func TestCoop_SingleAgent(t *testing.T) {
+	ws := NewWorkSpace(48 * 48)
+	ws.SetCostModel(CostOctile)
+	for seed := int64(0); seed < 10; seed++ {
+		m := createDemoSquareMap(seed)
+		ws.Reset(m)
+		c := NewCoop(m, 0)
+		c.SetCostModel(CostOctile)
+		_, ok := ws.Solve(0, 0, 47, 47)
+		paths, oks := c.Plan(0, []Agent{{ID: 1, Start: grid.PathGrid{}, End: grid.PathGrid{X: 47, Y: 47}}})
+		require.Equal(t, ok, oks[0], "seed %d", seed)
+		if !ok {
+			continue
+		}
+		// 没有其他智能体时不需要等待，代价与 Solve 相同
+		p := paths[0]
+		assert.Equal(t, int32(len(p)-1), p[len(p)-1].T)
+		assert.InDelta(t, ws.PathCost(), float64(checkMoves(t, ws, p))/octileUnit, 1e-9, "seed %d", seed)
+	}
+}
+
+func TestCoop_Corridor(t *testing.T) {
+	// 单行走廊中迎面相遇，其中一个智能体必须退进凹槽让路
+	tm := grid.MustParseText(`
+# # # # . # #
+. . . . . . .
+`)
+	ws := NewWorkSpace(256)
+	ws.Reset(tm.Map)
+	c := NewCoop(tm.Map, 0)
+	agents := []Agent{
+		{ID: 1, Start: grid.PathGrid{X: 0, Y: 1}, End: grid.PathGrid{X: 6, Y: 1}},
+		{ID: 2, Start: grid.PathGrid{X: 6, Y: 1}, End: grid.PathGrid{X: 0, Y: 1}},
+	}
+	paths, ok := c.Plan(0, agents)
+	require.Equal(t, []bool{true, true}, ok)
+	// 优先级高的智能体走最短路径
+	assert.Equal(t, []TimedCell{{0, 1, 0}, {1, 1, 1}, {2, 1, 2}, {3, 1, 3}, {4, 1, 4}, {5, 1, 5}, {6, 1, 6}}, paths[0])
+	pocket := false
+	for _, s := range paths[1] {
+		pocket = pocket || s.X == 4 && s.Y == 0
+	}
+	assert.True(t, pocket, "%v", paths[1])
+	checkConflicts(t, paths, 0, 20)
+	for i, p := range paths {
+		checkMoves(t, ws, p)
+		last := p[len(p)-1]
+		assert.Equal(t, agents[i].End, grid.PathGrid{X: last.X, Y: last.Y})
+	}
+	assert.Equal(t, paths[1], c.Table.Path(2))
+
+	// 搜索节点数受 MaxNodes 限制，让路需要更多节点
+	small := NewCoop(tm.Map, 0)
+	small.MaxNodes = 10
+	_, ok = small.Plan(0, agents)
+	assert.Equal(t, []bool{true, false}, ok)
+	assert.LessOrEqual(t, len(small.nodes), 10*9+1)
+
+	// 没有凹槽则无解，智能体原地等待
+	tm = grid.MustParseText(`
+. . . . . . .
+`)
+	c = NewCoop(tm.Map, 0)
+	c.MaxTicks = 32
+	agents[0].Start.Y, agents[0].End.Y, agents[1].Start.Y, agents[1].End.Y = 0, 0, 0, 0
+	paths, ok = c.Plan(0, agents)
+	assert.Equal(t, []bool{true, false}, ok)
+	assert.Equal(t, []TimedCell{{6, 0, 0}}, paths[1])
+}
+
+func TestCoop_DiagonalCrossing(t *testing.T) {
+	// 两个智能体的最短路径是同一方格的两条对角线，后规划的必须绕开
+	tm := grid.MustParseText(`
+. .
+. .
+`)
+	ws := NewWorkSpace(256)
+	ws.Reset(tm.Map)
+	c := NewCoop(tm.Map, 0)
+	agents := []Agent{
+		{ID: 1, Start: grid.PathGrid{X: 0, Y: 0}, End: grid.PathGrid{X: 1, Y: 1}},
+		{ID: 2, Start: grid.PathGrid{X: 1, Y: 0}, End: grid.PathGrid{X: 0, Y: 1}},
+	}
+	paths, ok := c.Plan(0, agents)
+	require.Equal(t, []bool{true, true}, ok)
+	assert.Equal(t, []TimedCell{{0, 0, 0}, {1, 1, 1}}, paths[0])
+	assert.Greater(t, paths[1][len(paths[1])-1].T, int32(1))
+	for _, p := range paths {
+		checkMoves(t, ws, p)
+	}
+	checkConflicts(t, paths, 0, 4)
+	assert.True(t, crosses(grid.PathGrid{X: 0, Y: 0}, grid.PathGrid{X: 1, Y: 1},
+		grid.PathGrid{X: 1, Y: 0}, grid.PathGrid{X: 0, Y: 1}))
+}
+
+func TestCoop_SharedGoal(t *testing.T) {
+	// 终点已被先规划的智能体永久占据时立即失败，默认 MaxTicks 下也不会长时间搜索
+	m := createTestGrid(48, 48)
+	c := NewCoop(m, 0)
+	goal := grid.PathGrid{X: 40, Y: 40}
+	agents := []Agent{
+		{ID: 1, Start: grid.PathGrid{X: 0, Y: 0}, End: goal},
+		{ID: 2, Start: grid.PathGrid{X: 47, Y: 0}, End: goal},
+	}
+	paths, ok := c.Plan(0, agents)
+	assert.Equal(t, []bool{true, false}, ok)
+	assert.Equal(t, []TimedCell{{47, 0, 0}}, paths[1])
+	assert.Empty(t, c.nodes)
+
+	// 有窗口时只规划窗口内的时刻，不受影响
+	c = NewCoop(m, 8)
+	_, ok = c.Plan(0, agents)
+	assert.Equal(t, []bool{true, true}, ok)
+}
+
+func TestCoop_Squad(t *testing.T) {
+	m := createTestGrid(32, 32)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 60; i++ {
+		m.Set(rng.Int31n(32), rng.Int31n(32))
+	}
+	ws := NewWorkSpace(32 * 32)
+	ws.Reset(m)
+
+	// 随机生成互不重叠的起点和终点
+	used := map[grid.PathGrid]bool{}
+	pick := func() grid.PathGrid {
+		for {
+			p := grid.PathGrid{X: rng.Int31n(32), Y: rng.Int31n(32)}
+			if m.Available(p.X, p.Y) && !used[p] {
+				used[p] = true
+				return p
+			}
+		}
+	}
+	var agents []Agent
+	for i := int32(0); i < 12; i++ {
+		agents = append(agents, Agent{ID: i, Start: pick(), End: pick()})
+	}
+
+	// 不分窗口：每个智能体规划到终点
+	c := NewCoop(m, 0)
+	paths, ok := c.Plan(0, agents)
+	var horizon int32
+	for i, p := range paths {
+		require.True(t, ok[i], "agent %d", i)
+		checkMoves(t, ws, p)
+		horizon = max(horizon, p[len(p)-1].T)
+	}
+	checkConflicts(t, paths, 0, horizon+1)
+
+	// 分窗口：每 4 个时刻按 8 个时刻的窗口重新规划，直到全部到达
+	c = NewCoop(m, 8)
+	executed := make([][]TimedCell, len(agents))
+	for i, a := range agents {
+		executed[i] = []TimedCell{{X: a.Start.X, Y: a.Start.Y}}
+	}
+	var tick int32
+	for ; tick < 200; tick += 4 {
+		paths, ok := c.Plan(tick, agents)
+		arrived := true
+		for i, p := range paths {
+			require.True(t, ok[i], "agent %d tick %d", i, tick)
+			checkMoves(t, ws, p)
+			// 执行窗口的前 4 个时刻
+			for _, s := range p[1:] {
+				if s.T <= tick+4 {
+					executed[i] = append(executed[i], s)
+				}
+			}
+			at := cellAt(p, tick+4)
+			agents[i].Start = at
+			arrived = arrived && at == agents[i].End
+		}
+		if arrived {
+			break
+		}
+	}
+	require.Less(t, tick, int32(200))
+	checkConflicts(t, executed, 0, tick+4)
+}
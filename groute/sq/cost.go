@@ -0,0 +1,120 @@
+package sq
+
+import (
+	"math"
+
+	"github.com/legamerdc/pathfinding/groute/jps"
+)
+
+// CostModel selects how Solve measures move costs on the square grid.
+type CostModel uint8
+
+const (
+	// CostApprox is the integer metric used by dist: a straight step costs 5
+	// and a diagonal step 7, i.e. diagonals are approximated by 1.4.
+	CostApprox CostModel = iota
+	// CostOctile is exact octile distance in fixed point: a straight step
+	// costs octileUnit and a diagonal step octileUnit*sqrt(2), rounded.
+	// Costs are int32, so paths are limited to about 214000 straight or
+	// 150000 diagonal cells: Solve fails for a start and goal further apart,
+	// and costs beyond the limit saturate.
+	CostOctile
+	// CostOctileEuclidH keeps the CostOctile move costs but estimates the
+	// remaining distance by the straight-line length, as float based
+	// planners do. It is not a float cost model: costs stay fixed point,
+	// which on the 8-connected grid are the Euclidean lengths of the moves
+	// up to rounding. The estimate is weaker, so more nodes are expanded,
+	// and ties are broken towards the straight line to the goal. The range
+	// limit of CostOctile applies.
+	CostOctileEuclidH
+)
+
+const (
+	octileUnit = 10000
+	octileDiag = 14142 // round(octileUnit * sqrt(2))
+)
+
+// Unit returns the cost of one straight step under the model.
+func (m CostModel) Unit() int32 {
+	if m == CostApprox {
+		return 5
+	}
+	return octileUnit
+}
+
+// SetCostModel selects the cost model used by Solve and PathCost. The bucket
+// queue is only used with CostApprox, because the fixed-point models spread
+// keys over a much wider range; see SetQueue.
+func (ws *WorkSpace) SetCostModel(m CostModel) {
+	ws.topo.cost = m
+	ws.applyQueue()
+	if ws.landmarks != nil && ws.landmarks.Unit != m.Unit() {
+		ws.landmarks = nil
+	}
+	ws.setHeuristic()
+}
+
+// BuildLandmarks preprocesses the current map for the ALT heuristic with k
+// landmarks under the current cost model, see jps.BuildLandmarks.
+func (ws *WorkSpace) BuildLandmarks(k int) *jps.Landmarks {
+	ws.topo.rows.Invalidate()
+	return jps.BuildLandmarks(&ws.topo, ws.Map, k)
+}
+
+// SetLandmarks makes Solve use the ALT heuristic of l on top of the cost
+// model's own estimate, nil removes it. It reports false and changes nothing
+// if l was built under a cost model with other units. Selecting such a cost
+// model later removes l.
+func (ws *WorkSpace) SetLandmarks(l *jps.Landmarks) bool {
+	if l != nil && l.Unit != ws.topo.cost.Unit() {
+		return false
+	}
+	ws.landmarks = l
+	ws.setHeuristic()
+	return true
+}
+
+func (ws *WorkSpace) setHeuristic() {
+	var h jps.Heuristic
+	if ws.topo.cost == CostOctileEuclidH {
+		h = euclid
+	}
+	if ws.landmarks != nil {
+		if h == nil {
+			h = ws.topo.Dist
+		}
+		h = ws.landmarks.Heuristic(h)
+	}
+	ws.search.SetHeuristic(h)
+}
+
+// PathCost returns the cost of the path found by the last successful Solve
+// in cells, measured by the current cost model.
+func (ws *WorkSpace) PathCost() float64 {
+	return float64(ws.search.Cost()) / float64(ws.topo.cost.Unit())
+}
+
+// octile saturates at math.MaxInt32, which is reached after about 150000
+// diagonal or 214000 straight cells.
+func octile(x1, y1, x2, y2 int32) int32 {
+	dx, dy := int64(x2)-int64(x1), int64(y2)-int64(y1)
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	var d int64
+	if dy >= dx {
+		d = dx*octileDiag + (dy-dx)*octileUnit
+	} else {
+		d = dy*octileDiag + (dx-dy)*octileUnit
+	}
+	return int32(min(d, math.MaxInt32))
+}
+
+// euclid scales by octileDiag/sqrt(2), slightly below octileUnit, and rounds
+// down so that it never exceeds octile despite the rounded diagonal.
+func euclid(x1, y1, x2, y2 int32) int32 {
+	return int32(min(math.Hypot(float64(x2-x1), float64(y2-y1))*(octileDiag/math.Sqrt2), math.MaxInt32))
+}
@@ -0,0 +1,132 @@
+package sq
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/graph"
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/jps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEuclidAdmissible(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		x, y := rand.Int31n(2000)-1000, rand.Int31n(2000)-1000
+		assert.LessOrEqual(t, euclid(0, 0, x, y), octile(0, 0, x, y), "(%d, %d)", x, y)
+	}
+	assert.Equal(t, int32(3*octileDiag+2*octileUnit), octile(1, 1, 6, 4))
+}
+
+// 定点代价超出 int32 时饱和，远距离查询直接失败而不是回绕成负代价
+func TestWorkSpace_CostRange(t *testing.T) {
+	assert.Equal(t, int32(math.MaxInt32), octile(0, 0, 1<<24, 1<<24))
+	assert.Equal(t, int32(math.MaxInt32), euclid(0, 0, 1<<24, 1<<24))
+
+	world := grid.NewWorld(grid.WorldOptions{
+		Bounds: grid.Rect{MinX: -16, MinY: 0, MaxX: 230000, MaxY: 16},
+		Loader: func(bx, by int32) (*grid.Grid, bool) { return new(grid.Grid), true },
+	})
+	ws := NewWorkSpace(1024)
+	ws.Reset(world)
+	for _, m := range []CostModel{CostOctile, CostOctileEuclidH} {
+		ws.SetCostModel(m)
+		_, ok := ws.Solve(0, 0, 200000, 3)
+		require.True(t, ok, "model %d", m)
+		assert.InDelta(t, 199997+3*math.Sqrt2, ws.PathCost(), 1e-3, "model %d", m)
+		_, ok = ws.Solve(0, 0, 220000, 3)
+		assert.False(t, ok, "model %d", m)
+	}
+	ws.SetCostModel(CostApprox)
+	_, ok := ws.Solve(0, 0, 220000, 3)
+	require.True(t, ok)
+	assert.Equal(t, float64(219997*5+3*7)/5, ws.PathCost())
+}
+
+// octileGraph is the 8-connected grid with corner cutting disallowed and
+// exact float costs, matching the moves Solve may take.
+func octileGraph(m *grid.Local) graph.GraphFunc[grid.Gpos] {
+	return func(p grid.Gpos, f func(grid.Gpos, float64) bool) {
+		for d := int32(0); d < 8; d++ {
+			x, y := move(p.X, p.Y, d)
+			if !m.Available(x, y) {
+				continue
+			}
+			cost := 1.0
+			if diagonal(d) {
+				if !m.Available(x, p.Y) || !m.Available(p.X, y) {
+					continue
+				}
+				cost = math.Sqrt2
+			}
+			f(grid.Gpos{X: x, Y: y}, cost)
+		}
+	}
+}
+
+func octileLength(path []grid.PathGrid) float64 {
+	var l float64
+	for i := 1; i < len(path); i++ {
+		dx := math.Abs(float64(path[i].X - path[i-1].X))
+		dy := math.Abs(float64(path[i].Y - path[i-1].Y))
+		l += math.Min(dx, dy)*math.Sqrt2 + math.Abs(dx-dy)
+	}
+	return l
+}
+
+func TestWorkSpace_CostModels(t *testing.T) {
+	ref := graph.NewSearch[grid.Gpos](48 * 48)
+	for seed := int64(0); seed < 30; seed++ {
+		local := createDemoSquareMap(seed)
+		g := octileGraph(local)
+		_, want, ok := ref.Dijkstra(g, grid.Gpos{}, grid.Gpos{X: 47, Y: 47})
+
+		ws := NewWorkSpace(2304)
+		ws.Reset(local)
+		for _, m := range []CostModel{CostOctile, CostOctileEuclidH} {
+			ws.SetCostModel(m)
+			path, ok1 := ws.Solve(0, 0, 47, 47)
+			require.Equal(t, ok, ok1, "seed %d", seed)
+			if !ok {
+				continue
+			}
+			assert.InDelta(t, want, ws.PathCost(), 1e-3, "seed %d model %d", seed, m)
+			assert.InDelta(t, want, octileLength(path), 1e-9, "seed %d model %d", seed, m)
+		}
+
+		ws.SetCostModel(CostApprox)
+		path, ok1 := ws.Solve(0, 0, 47, 47)
+		require.Equal(t, ok, ok1)
+		if ok {
+			assert.InDelta(t, float64(pathCost(path))/5, ws.PathCost(), 1e-9)
+			assert.GreaterOrEqual(t, octileLength(path), want-1e-9)
+		}
+	}
+}
+
+func TestWorkSpace_BucketFallback(t *testing.T) {
+	m := createDemoSquareMap(3)
+	ws := NewWorkSpace(48 * 48)
+	ws.Reset(m)
+	ws.SetCostModel(CostOctile)
+	want, ok := ws.Solve(0, 0, 47, 47)
+	require.True(t, ok)
+	cost := ws.PathCost()
+
+	// 定点代价模型下桶队列退回二叉堆，切回 CostApprox 后恢复
+	ws.SetQueue(jps.QueueBucket)
+	assert.Equal(t, jps.QueueHeap, ws.search.Queue())
+	got, ok := ws.Solve(0, 0, 47, 47)
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+	assert.Equal(t, cost, ws.PathCost())
+
+	ws.SetCostModel(CostApprox)
+	assert.Equal(t, jps.QueueBucket, ws.search.Queue())
+	ws.SetCostModel(CostOctileEuclidH)
+	assert.Equal(t, jps.QueueHeap, ws.search.Queue())
+	ws.SetQueue(jps.QueueQuadHeap)
+	assert.Equal(t, jps.QueueQuadHeap, ws.search.Queue())
+}
@@ -0,0 +1,468 @@
+package sq
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// ErrStale is returned when a path database does not match the map.
+var ErrStale = errors.New("sq: path database does not match the map")
+
+var cpdMagic = [4]byte{'C', 'P', 'D', '1'}
+
+const (
+	cpdNone    = 0xf // no path
+	cpdDirBits = 4
+
+	maxCPDRuns = 1 << 28 // runs ReadCPD accepts
+)
+
+// CPDOptions configures BuildCPD.
+type CPDOptions struct {
+	// Cost is the cost model the stored moves are optimal for.
+	Cost CostModel
+	// Workers is the number of goroutines running searches. 0 means
+	// GOMAXPROCS.
+	Workers int
+	// Progress, if set, is called after every finished start cell with the
+	// number of finished and total start cells. Calls never overlap and done
+	// increases by one each time.
+	Progress func(done, total int)
+}
+
+// CPD is a compressed path database: for every start cell of a map it
+// stores the first move of a shortest path to every free cell, so a query
+// follows first moves without searching. Free cells are numbered in depth
+// first order, which keeps cells with the same first move together, and the
+// moves of each start cell are stored as runs over that numbering.
+//
+// Moves, corner rules and costs are those of Solve under the cost model the
+// database was built with. The map must not change after the build. Queries
+// do not modify the database, so any number of goroutines may share one.
+type CPD struct {
+	Rect grid.Rect
+	Cost CostModel
+
+	order []int32  // cell index by rank
+	rank  []int32  // rank by cell index, -1 for blocked cells
+	first []uint32 // runs of every start cell index start at first[i]
+	runs  []uint32 // rank << cpdDirBits | direction of the first move
+}
+
+// BuildCPD runs Dijkstra from every cell of m and stores the first moves.
+// It takes time quadratic in the number of cells, so it is meant for small
+// fixed maps built ahead of time, see CPD.WriteTo.
+func BuildCPD(m grid.Walkable, opt CPDOptions) *CPD {
+	if opt.Workers <= 0 {
+		opt.Workers = runtime.GOMAXPROCS(0)
+	}
+	c := &CPD{Rect: m.Bounds(), Cost: opt.Cost}
+	n := c.cells()
+	c.rank = make([]int32, n)
+	steps, free := cpdSteps(m, c.Rect)
+	c.order = cpdOrder(c.Rect, steps, free, c.rank)
+
+	rows := make([][]uint32, n)
+	var (
+		next atomic.Int64
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for w := min(opt.Workers, n); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := newCPDSearch(c, steps)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				rows[i] = d.row(i)
+				if opt.Progress != nil {
+					mu.Lock()
+					done++
+					opt.Progress(done, n)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.first = make([]uint32, n+1)
+	for i, r := range rows {
+		c.first[i+1] = c.first[i] + uint32(len(r))
+	}
+	c.runs = make([]uint32, 0, c.first[n])
+	for _, r := range rows {
+		c.runs = append(c.runs, r...)
+	}
+	return c
+}
+
+// FirstMove returns the cell after (sx, sy) on a shortest path to (ex, ey),
+// ok == false if there is no path or start and end are the same cell.
+func (c *CPD) FirstMove(sx, sy, ex, ey int32) (x, y int32, ok bool) {
+	d := c.move(sx, sy, ex, ey)
+	if d == cpdNone {
+		return sx, sy, false
+	}
+	x, y = move(sx, sy, d)
+	return x, y, true
+}
+
+// Solve returns a shortest path from start to end cell coordinates in the
+// format of WorkSpace.Solve, by following first moves.
+func (c *CPD) Solve(sx, sy, ex, ey int32) ([]grid.PathGrid, bool) {
+	p, _, ok := c.follow(sx, sy, ex, ey)
+	return p, ok
+}
+
+// Distance returns the cost of a shortest path in cells, measured by the
+// cost model of the database.
+func (c *CPD) Distance(sx, sy, ex, ey int32) (float64, bool) {
+	_, cost, ok := c.follow(sx, sy, ex, ey)
+	return float64(cost) / float64(c.Cost.Unit()), ok
+}
+
+// Runs returns the number of stored runs, a measure of the database size.
+func (c *CPD) Runs() int {
+	return len(c.runs)
+}
+
+// WriteTo encodes the database: the magic "CPD1", the rect, cost model,
+// free cell count and run count as little-endian integers, then the cells in
+// rank order, the run offsets of every start cell and the runs.
+func (c *CPD) WriteTo(wr io.Writer) (int64, error) {
+	bw := bufio.NewWriter(wr)
+	n := int64(0)
+	put := func(v any) {
+		_ = binary.Write(bw, binary.LittleEndian, v)
+		n += int64(binary.Size(v))
+	}
+	put(cpdMagic)
+	put([4]int32{c.Rect.MinX, c.Rect.MinY, c.Rect.MaxX, c.Rect.MaxY})
+	put(uint8(c.Cost))
+	put(uint32(len(c.order)))
+	put(uint32(len(c.runs)))
+	put(c.order)
+	put(c.first)
+	put(c.runs)
+	return n, bw.Flush()
+}
+
+// ReadCPD decodes a database written by CPD.WriteTo and checks it against
+// m, returning ErrStale if the free cells of m changed since the build.
+func ReadCPD(r io.Reader, m grid.Walkable) (*CPD, error) {
+	br := bufio.NewReader(r)
+	var (
+		head         [4]byte
+		rect         [4]int32
+		cost         uint8
+		nfree, nruns uint32
+	)
+	for _, v := range []any{&head, &rect, &cost, &nfree, &nruns} {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	c := &CPD{Rect: grid.Rect{MinX: rect[0], MinY: rect[1], MaxX: rect[2], MaxY: rect[3]}, Cost: CostModel(cost)}
+	n := int64(c.Rect.MaxX-c.Rect.MinX) * int64(c.Rect.MaxY-c.Rect.MinY)
+	if head != cpdMagic || n <= 0 || n > 1<<26 || int64(nfree) > n || nruns > maxCPDRuns ||
+		int64(nruns) > n*int64(nfree) || cost > uint8(CostOctileEuclidH) {
+		return nil, grid.ErrFormat
+	}
+	var err error
+	if c.order, err = grid.ReadSlice[int32](br, int(nfree)); err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	if c.first, err = grid.ReadSlice[uint32](br, int(n)+1); err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	if c.first[0] != 0 || c.first[n] != nruns || !slices.IsSorted(c.first) {
+		return nil, grid.ErrFormat
+	}
+	if c.runs, err = grid.ReadSlice[uint32](br, int(nruns)); err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	// runs of a start cell have increasing ranks and valid moves
+	for i := range n {
+		prev := int64(-1)
+		for _, r := range c.runs[c.first[i]:c.first[i+1]] {
+			k, d := int64(r>>cpdDirBits), r&(1<<cpdDirBits-1)
+			if k <= prev || k >= int64(nfree) || d >= 8 && d != cpdNone {
+				return nil, grid.ErrFormat
+			}
+			prev = k
+		}
+	}
+
+	c.rank = make([]int32, n)
+	for i := range c.rank {
+		c.rank[i] = -1
+	}
+	for k, i := range c.order {
+		if i < 0 || int64(i) >= n || c.rank[i] >= 0 {
+			return nil, grid.ErrFormat
+		}
+		c.rank[i] = int32(k)
+	}
+	if m.Bounds() != c.Rect {
+		return nil, ErrStale
+	}
+	for i, k := range c.rank {
+		if x, y := c.cell(i); m.Available(x, y) != (k >= 0) {
+			return nil, ErrStale
+		}
+	}
+	return c, nil
+}
+
+func (c *CPD) follow(sx, sy, ex, ey int32) ([]grid.PathGrid, int32, bool) {
+	if !c.Rect.Contains(sx, sy) || !c.Rect.Contains(ex, ey) || c.rank[c.index(ex, ey)] < 0 {
+		return nil, 0, false
+	}
+	p := []grid.PathGrid{{X: sx, Y: sy}}
+	x, y, last, cost := sx, sy, int32(-1), int32(0)
+	// a shortest path enters every free cell at most once, more steps mean
+	// the moves loop
+	for steps := 0; x != ex || y != ey; steps++ {
+		d := c.move(x, y, ex, ey)
+		if d == cpdNone || steps == len(c.order) {
+			return nil, 0, false
+		}
+		nx, ny := move(x, y, d)
+		cost += c.dist(x, y, nx, ny)
+		if d != last {
+			last = d
+		} else {
+			p = p[:len(p)-1] // (x, y) is not a turning point
+		}
+		p = append(p, grid.PathGrid{X: nx, Y: ny})
+		x, y = nx, ny
+	}
+	return p, cost, true
+}
+
+// move returns the first move from (sx, sy) to (ex, ey), cpdNone if there
+// is none.
+func (c *CPD) move(sx, sy, ex, ey int32) int32 {
+	if !c.Rect.Contains(sx, sy) || !c.Rect.Contains(ex, ey) || sx == ex && sy == ey {
+		return cpdNone
+	}
+	k := c.rank[c.index(ex, ey)]
+	if k < 0 {
+		return cpdNone
+	}
+	i := c.index(sx, sy)
+	runs := c.runs[c.first[i]:c.first[i+1]]
+	// the last run starting at or before k
+	j, _ := slices.BinarySearchFunc(runs, k+1, func(r uint32, k int32) int {
+		return int(int64(r>>cpdDirBits) - int64(k))
+	})
+	if j == 0 {
+		return cpdNone
+	}
+	return int32(runs[j-1] & (1<<cpdDirBits - 1))
+}
+
+func (c *CPD) dist(x1, y1, x2, y2 int32) int32 {
+	if c.Cost == CostApprox {
+		return dist(x1, y1, x2, y2)
+	}
+	return octile(x1, y1, x2, y2)
+}
+
+func (c *CPD) cells() int {
+	return max(int(c.Rect.MaxX-c.Rect.MinX), 0) * max(int(c.Rect.MaxY-c.Rect.MinY), 0)
+}
+
+func (c *CPD) index(x, y int32) int {
+	return int(y-c.Rect.MinY)*int(c.Rect.MaxX-c.Rect.MinX) + int(x-c.Rect.MinX)
+}
+
+func (c *CPD) cell(i int) (int32, int32) {
+	w := int(c.Rect.MaxX - c.Rect.MinX)
+	return c.Rect.MinX + int32(i%w), c.Rect.MinY + int32(i/w)
+}
+
+// cpdSteps returns the allowed moves of every cell of r as bits by
+// direction, and which cells are free.
+func cpdSteps(m grid.Walkable, r grid.Rect) (steps []uint8, free []bool) {
+	var t topology
+	t.reset(m)
+	t.rows.Invalidate()
+	w := int(r.MaxX - r.MinX)
+	steps = make([]uint8, w*int(r.MaxY-r.MinY))
+	free = make([]bool, len(steps))
+	for i := range steps {
+		x, y := r.MinX+int32(i%w), r.MinY+int32(i/w)
+		free[i] = t.available(x, y)
+		for d := int32(0); d < 8; d++ {
+			if nx, ny, ok := t.Step(x, y, d); ok && r.Contains(nx, ny) {
+				steps[i] |= 1 << d
+			}
+		}
+	}
+	return steps, free
+}
+
+// cpdOrder numbers the free cells of r in depth first order, fills rank and
+// returns the cells by rank.
+func cpdOrder(r grid.Rect, steps []uint8, free []bool, rank []int32) []int32 {
+	w := int32(r.MaxX - r.MinX)
+	for i := range rank {
+		rank[i] = -1
+	}
+	var order, stack []int32
+	for i := range rank {
+		if rank[i] >= 0 || !free[i] {
+			continue
+		}
+		stack = append(stack[:0], int32(i))
+		for len(stack) > 0 {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if rank[j] >= 0 {
+				continue
+			}
+			rank[j] = int32(len(order))
+			order = append(order, j)
+			x, y := r.MinX+j%w, r.MinY+j/w
+			for d := int32(7); d >= 0; d-- {
+				if steps[j]&(1<<d) == 0 {
+					continue
+				}
+				nx, ny := move(x, y, d)
+				if k := (ny-r.MinY)*w + nx - r.MinX; rank[k] < 0 {
+					stack = append(stack, k)
+				}
+			}
+		}
+	}
+	return order
+}
+
+// cpdSearch is the Dijkstra state of one build worker.
+type cpdSearch struct {
+	c     *CPD
+	steps []uint8
+	cost  [8]int32
+	delta [8]int32  // cell index offset by direction
+	nodes []cpdNode // by cell index
+	heap  *heap.Heap[*cpdNode]
+	moves []uint8 // first move by rank
+}
+
+// cpdNode is the Dijkstra state of one cell.
+type cpdNode struct {
+	i     int32
+	d     int32
+	dir   uint8 // first move from the source
+	index int32
+}
+
+// Compare implements heap.Node. Ties pop by cell index, which keeps the
+// first moves of a build deterministic.
+func (n *cpdNode) Compare(o *cpdNode) int32 {
+	if n.d != o.d {
+		return int32(cmp.Compare(n.d, o.d))
+	}
+	return int32(cmp.Compare(n.i, o.i))
+}
+
+// GetHeapIndex implements heap.Node.
+func (n *cpdNode) GetHeapIndex() int32 {
+	return n.index
+}
+
+// SetHeapIndex implements heap.Node.
+func (n *cpdNode) SetHeapIndex(i int32) {
+	n.index = i
+}
+
+func newCPDSearch(c *CPD, steps []uint8) *cpdSearch {
+	n := c.cells()
+	d := &cpdSearch{
+		c:     c,
+		steps: steps,
+		nodes: make([]cpdNode, n),
+		heap:  heap.NewHeap[*cpdNode](0),
+		moves: make([]uint8, len(c.order)),
+	}
+	w := c.Rect.MaxX - c.Rect.MinX
+	for dir := int32(0); dir < 8; dir++ {
+		x, y := move(0, 0, dir)
+		d.cost[dir] = c.dist(0, 0, x, y)
+		d.delta[dir] = y*w + x
+	}
+	return d
+}
+
+// row runs Dijkstra from cell index src and returns its runs.
+func (d *cpdSearch) row(src int) []uint32 {
+	c := d.c
+	for i := range d.nodes {
+		d.nodes[i] = cpdNode{i: int32(i), d: math.MaxInt32, dir: cpdNone, index: -1}
+	}
+	d.nodes[src].d = 0
+	d.heap.Push(&d.nodes[src])
+	for !d.heap.Empty() {
+		cur := d.heap.Pop()
+		i, g := int(cur.i), cur.d
+		for dir := int32(0); dir < 8; dir++ {
+			if d.steps[i]&(1<<dir) == 0 {
+				continue
+			}
+			next := &d.nodes[i+int(d.delta[dir])]
+			ng := g + d.cost[dir]
+			if ng >= next.d {
+				continue
+			}
+			next.d = ng
+			if i == src {
+				next.dir = uint8(dir)
+			} else {
+				next.dir = cur.dir
+			}
+			if d.heap.Contains(next) {
+				d.heap.Fix(next)
+			} else {
+				d.heap.Push(next)
+			}
+		}
+	}
+
+	for k, i := range c.order {
+		d.moves[k] = d.nodes[i].dir
+	}
+	// the start itself never needs a move, it joins a neighbouring run
+	if k := c.rank[src]; k >= 0 {
+		if k > 0 {
+			d.moves[k] = d.moves[k-1]
+		} else if len(d.moves) > 1 {
+			d.moves[k] = d.moves[k+1]
+		}
+	}
+	var row []uint32
+	for k, mv := range d.moves {
+		if k == 0 || mv != d.moves[k-1] {
+			row = append(row, uint32(k)<<cpdDirBits|uint32(mv))
+		}
+	}
+	return row
+}
@@ -0,0 +1,141 @@
+package sq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPD_MatchesSolve(t *testing.T) {
+	ws := NewWorkSpace(48 * 48)
+	for seed := int64(0); seed < 2; seed++ {
+		m := createDemoSquareMap(seed)
+		ws.Reset(m)
+		for _, cm := range []CostModel{CostApprox, CostOctile} {
+			ws.SetCostModel(cm)
+			c := BuildCPD(m, CPDOptions{Cost: cm})
+			// 游程压缩后远小于每对格子一项
+			assert.Less(t, c.Runs(), 48*48*48*48/20)
+
+			rng := rand.New(rand.NewSource(seed))
+			for q := 0; q < 200; q++ {
+				sx, sy, ex, ey := rng.Int31n(48), rng.Int31n(48), rng.Int31n(48), rng.Int31n(48)
+				_, ok := ws.Solve(sx, sy, ex, ey)
+				path, ok1 := c.Solve(sx, sy, ex, ey)
+				require.Equal(t, ok, ok1, "seed %d (%d,%d)->(%d,%d)", seed, sx, sy, ex, ey)
+				if !ok {
+					continue
+				}
+				d, _ := c.Distance(sx, sy, ex, ey)
+				assert.InDelta(t, ws.PathCost(), d, 1e-9, "seed %d (%d,%d)->(%d,%d)", seed, sx, sy, ex, ey)
+				assert.Equal(t, grid.PathGrid{X: ex, Y: ey}, path[len(path)-1])
+				// 起点可能是障碍，从第二个路点开始检查
+				assert.Equal(t, -1, ws.Validate(path[1:]), "seed %d: %v", seed, path)
+				if len(path) > 1 {
+					x, y, ok := c.FirstMove(sx, sy, ex, ey)
+					assert.True(t, ok)
+					assert.Equal(t, expandGridPath(path)[1], grid.PathGrid{X: x, Y: y})
+				}
+			}
+		}
+	}
+}
+
+func TestCPD_Build(t *testing.T) {
+	m := createDemoSquareMap(7)
+	var calls, last int
+	c := BuildCPD(m, CPDOptions{Cost: CostOctile, Workers: 4, Progress: func(done, total int) {
+		calls++
+		assert.Equal(t, last+1, done)
+		assert.Equal(t, 48*48, total)
+		last = done
+	}})
+	assert.Equal(t, 48*48, calls)
+
+	// 并行构建与单线程构建结果一致
+	serial := BuildCPD(m, CPDOptions{Cost: CostOctile, Workers: 1})
+	assert.Equal(t, serial.runs, c.runs)
+	assert.Equal(t, serial.first, c.first)
+
+	// 序列化往返
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	data := buf.Bytes()
+	c1, err := ReadCPD(bytes.NewReader(data), m)
+	require.NoError(t, err)
+	assert.Equal(t, c.runs, c1.runs)
+	assert.Equal(t, c.rank, c1.rank)
+	assert.Equal(t, CostOctile, c1.Cost)
+	p, ok := c.Solve(0, 0, 47, 47)
+	p1, ok1 := c1.Solve(0, 0, 47, 47)
+	assert.Equal(t, ok, ok1)
+	assert.Equal(t, p, p1)
+
+	_, err = ReadCPD(bytes.NewReader(data[:len(data)-2]), m)
+	assert.ErrorIs(t, err, grid.ErrFormat)
+
+	// 游程的方向无效或秩不递增时拒绝读入
+	runs := len(data) - 4*c.Runs()
+	bad := bytes.Clone(data)
+	bad[runs] = bad[runs]&^0xf | 9
+	_, err = ReadCPD(bytes.NewReader(bad), m)
+	assert.ErrorIs(t, err, grid.ErrFormat)
+	require.Greater(t, c.first[1], uint32(1))
+	bad = bytes.Clone(data)
+	copy(bad[runs+4:runs+8], bad[runs:runs+4])
+	_, err = ReadCPD(bytes.NewReader(bad), m)
+	assert.ErrorIs(t, err, grid.ErrFormat)
+
+	// 头部声明巨大的游程数但数据被截断：返回错误而不是按声明分配内存
+	for _, nruns := range []uint32{1 << 28, 1 << 31} {
+		var head bytes.Buffer
+		head.Write(cpdMagic[:])
+		binary.Write(&head, binary.LittleEndian, [4]int32{0, 0, 8192, 8192})
+		head.WriteByte(uint8(CostApprox))
+		binary.Write(&head, binary.LittleEndian, [2]uint32{1 << 26, nruns})
+		head.Write(make([]byte, 512<<10))
+		_, err = ReadCPD(&head, m)
+		assert.ErrorIs(t, err, grid.ErrFormat, "nruns %d", nruns)
+	}
+
+	// 地图改动后不能再读入
+	toggle(m, 10, 10)
+	_, err = ReadCPD(bytes.NewReader(data), m)
+	assert.ErrorIs(t, err, ErrStale)
+}
+
+// 首步互相指向时查询失败而不是死循环
+func TestCPD_Loop(t *testing.T) {
+	c := BuildCPD(createTestGrid(16, 16), CPDOptions{})
+	_, ok := c.Solve(0, 0, 2, 0)
+	require.True(t, ok)
+	i := c.index(1, 0)
+	for j := c.first[i]; j < c.first[i+1]; j++ {
+		c.runs[j] = c.runs[j]&^0xf | 6 // 一律向西
+	}
+	_, ok = c.Solve(0, 0, 2, 0)
+	assert.False(t, ok)
+}
+
+// 基准测试：首步查询与完整路径
+func BenchmarkCPD(b *testing.B) {
+	m := createDemoSquareMap(1)
+	c := BuildCPD(m, CPDOptions{Cost: CostOctile})
+	b.Run("first", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.FirstMove(0, 0, 47, 47)
+		}
+	})
+	b.Run("solve", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.Solve(0, 0, 47, 47)
+		}
+	})
+}
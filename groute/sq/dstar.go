@@ -0,0 +1,261 @@
+package sq
+
+import (
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+const dsInf = int64(1) << 60
+
+// DStar plans on the square grid with D* Lite: it searches backwards from
+// the goal and keeps its search state between calls, so after the agent
+// moves or some cells change only the affected part of the search is
+// repaired instead of running Solve again.
+//
+// Moves and corner rules are those of Solve, costs are the exact octile
+// costs of CostOctile. Cells are free or blocked as reported by the map,
+// which must be edited by the caller before passing the changed cells to
+// Update.
+type DStar struct {
+	Map grid.Walkable
+
+	start, last, goal grid.PathGrid
+	km                int64
+	nodes             map[grid.PathGrid]*dsNode
+	open              *heap.Heap[*dsNode]
+	expanded          int
+}
+
+type dsNode struct {
+	p      grid.PathGrid
+	g, rhs int64
+	k1, k2 int64
+	index  int32
+}
+
+// Compare implements heap.Node, ordering by the D* Lite key.
+func (n *dsNode) Compare(o *dsNode) int32 {
+	if n.k1 != o.k1 {
+		return cmp64(n.k1, o.k1)
+	}
+	return cmp64(n.k2, o.k2)
+}
+
+// GetHeapIndex implements heap.Node.
+func (n *dsNode) GetHeapIndex() int32 { return n.index }
+
+// SetHeapIndex implements heap.Node.
+func (n *dsNode) SetHeapIndex(i int32) { n.index = i }
+
+// NewDStar creates a planner from (sx, sy) to (ex, ey) on m. The first
+// search runs on the first call to Path.
+func NewDStar(m grid.Walkable, sx, sy, ex, ey int32) *DStar {
+	d := &DStar{
+		Map:   m,
+		start: grid.PathGrid{X: sx, Y: sy},
+		goal:  grid.PathGrid{X: ex, Y: ey},
+		nodes: make(map[grid.PathGrid]*dsNode),
+		open:  heap.NewHeap[*dsNode](64),
+	}
+	d.last = d.start
+	g := d.node(d.goal)
+	g.rhs = 0
+	d.push(g)
+	return d
+}
+
+// Move sets the current position of the agent, usually the next cell of
+// the last path.
+func (d *DStar) Move(x, y int32) {
+	d.start = grid.PathGrid{X: x, Y: y}
+}
+
+// Update tells the planner that the availability of cells changed in the
+// map. Only the neighbourhood of each cell is re-evaluated.
+func (d *DStar) Update(cells []grid.PathGrid) {
+	d.moved()
+	for _, c := range cells {
+		for dx := int32(-1); dx <= 1; dx++ {
+			for dy := int32(-1); dy <= 1; dy++ {
+				d.updateVertex(d.node(grid.PathGrid{X: c.X + dx, Y: c.Y + dy}))
+			}
+		}
+	}
+}
+
+// Path returns the shortest path from the current position to the goal as
+// turning points, like Solve.
+func (d *DStar) Path() ([]grid.PathGrid, bool) {
+	d.moved()
+	d.expanded = 0
+	d.computeShortestPath()
+	s := d.node(d.start)
+	if s.g >= dsInf || !d.Map.Available(d.start.X, d.start.Y) {
+		return nil, false
+	}
+
+	path := []grid.PathGrid{d.start}
+	cur, lastDir := d.start, int32(-1)
+	for steps := len(d.nodes); cur != d.goal; steps-- {
+		if steps < 0 {
+			return nil, false
+		}
+		best, bestDir, bestCost := cur, int32(-1), dsInf
+		for dir := int32(0); dir < 8; dir++ {
+			nx, ny, c := d.edge(cur, dir)
+			if c >= dsInf {
+				continue
+			}
+			n, ok := d.nodes[grid.PathGrid{X: nx, Y: ny}]
+			if !ok || n.g >= dsInf {
+				continue
+			}
+			if total := c + n.g; total < bestCost {
+				best, bestDir, bestCost = n.p, dir, total
+			}
+		}
+		if bestDir < 0 {
+			return nil, false
+		}
+		if bestDir == lastDir {
+			path[len(path)-1] = best
+		} else {
+			path = append(path, best)
+		}
+		cur, lastDir = best, bestDir
+	}
+	return path, true
+}
+
+// PathCost returns the cost of the last path in cells.
+func (d *DStar) PathCost() float64 {
+	return float64(d.node(d.start).g) / octileUnit
+}
+
+// Expanded returns the number of nodes expanded by the last Path call.
+func (d *DStar) Expanded() int {
+	return d.expanded
+}
+
+// moved accounts for the agent moving since the last search, so keys
+// already in the queue stay lower bounds.
+func (d *DStar) moved() {
+	if d.start != d.last {
+		d.km += d.h(d.last)
+		d.last = d.start
+	}
+}
+
+func (d *DStar) computeShortestPath() {
+	s := d.node(d.start)
+	for !d.open.Empty() {
+		u := d.open.Top()
+		k1, k2 := d.key(s)
+		if (u.k1 > k1 || u.k1 == k1 && u.k2 >= k2) && s.rhs == s.g {
+			return
+		}
+		d.expanded++
+		if n1, n2 := d.key(u); u.k1 < n1 || u.k1 == n1 && u.k2 < n2 {
+			u.k1, u.k2 = n1, n2
+			d.open.Fix(u)
+			continue
+		}
+		d.open.Remove(u)
+		if u.g > u.rhs {
+			u.g = u.rhs
+		} else {
+			u.g = dsInf
+			d.updateVertex(u)
+		}
+		for dir := int32(0); dir < 8; dir++ {
+			nx, ny, c := d.edge(u.p, dir)
+			if c < dsInf {
+				d.updateVertex(d.node(grid.PathGrid{X: nx, Y: ny}))
+			}
+		}
+	}
+}
+
+// updateVertex recomputes rhs of n from its successors and requeues n if it
+// is inconsistent.
+func (d *DStar) updateVertex(n *dsNode) {
+	if n.p != d.goal {
+		n.rhs = dsInf
+		for dir := int32(0); dir < 8; dir++ {
+			nx, ny, c := d.edge(n.p, dir)
+			if c >= dsInf {
+				continue
+			}
+			if s, ok := d.nodes[grid.PathGrid{X: nx, Y: ny}]; ok && s.g < dsInf {
+				n.rhs = min(n.rhs, c+s.g)
+			}
+		}
+	}
+	if n.index >= 0 {
+		d.open.Remove(n)
+	}
+	if n.g != n.rhs {
+		d.push(n)
+	}
+}
+
+func (d *DStar) push(n *dsNode) {
+	n.k1, n.k2 = d.key(n)
+	d.open.Push(n)
+}
+
+func (d *DStar) key(n *dsNode) (int64, int64) {
+	m := min(n.g, n.rhs)
+	if m >= dsInf {
+		return dsInf, dsInf
+	}
+	return m + d.h(n.p) + d.km, m
+}
+
+// h estimates the cost from the agent position to p.
+func (d *DStar) h(p grid.PathGrid) int64 {
+	dx, dy := abs64(int64(p.X)-int64(d.start.X)), abs64(int64(p.Y)-int64(d.start.Y))
+	return min(dx, dy)*octileDiag + (max(dx, dy)-min(dx, dy))*octileUnit
+}
+
+// edge returns the cell reached from p in direction dir and the move cost,
+// dsInf if the move is not allowed. Edges are symmetric.
+func (d *DStar) edge(p grid.PathGrid, dir int32) (int32, int32, int64) {
+	x, y := move(p.X, p.Y, dir)
+	if !d.Map.Available(p.X, p.Y) || !d.Map.Available(x, y) {
+		return x, y, dsInf
+	}
+	if !diagonal(dir) {
+		return x, y, octileUnit
+	}
+	if avoidCorner && (!d.Map.Available(x, p.Y) || !d.Map.Available(p.X, y)) {
+		return x, y, dsInf
+	}
+	return x, y, octileDiag
+}
+
+func (d *DStar) node(p grid.PathGrid) *dsNode {
+	n, ok := d.nodes[p]
+	if !ok {
+		n = &dsNode{p: p, g: dsInf, rhs: dsInf, index: -1}
+		d.nodes[p] = n
+	}
+	return n
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func cmp64(a, b int64) int32 {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package sq
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 翻转格子的可通行状态
+func toggle(m *grid.Local, x, y int32) {
+	m.Grids[x/16][y/16].Bits[y%16] ^= 1 << (x % 16)
+}
+
+func TestDStar_MatchesSolve(t *testing.T) {
+	ws := NewWorkSpace(48 * 48)
+	ws.SetCostModel(CostOctile)
+	for seed := int64(0); seed < 20; seed++ {
+		m := createDemoSquareMap(seed)
+		ws.Reset(m)
+		d := NewDStar(m, 0, 0, 47, 47)
+		rng := rand.New(rand.NewSource(seed))
+
+		for round := 0; round < 10; round++ {
+			path, ok := d.Path()
+			want, wantOK := ws.Solve(d.start.X, d.start.Y, 47, 47)
+			require.Equal(t, wantOK, ok, "seed %d round %d", seed, round)
+			if !ok {
+				break
+			}
+			assert.InDelta(t, ws.PathCost(), d.PathCost(), 1e-9, "seed %d round %d", seed, round)
+			assert.InDelta(t, octileLength(want), octileLength(path), 1e-9, "seed %d round %d", seed, round)
+			assert.Equal(t, -1, ws.Validate(path))
+
+			// 沿路径前进一步，然后随机改变一些格子
+			if len(path) > 1 {
+				next := expandGridPath(path)[1]
+				d.Move(next.X, next.Y)
+			}
+			var changed []grid.PathGrid
+			for k := 0; k < 8; k++ {
+				x, y := rng.Int31n(48), rng.Int31n(48)
+				if (x == d.start.X && y == d.start.Y) || (x == 47 && y == 47) {
+					continue
+				}
+				toggle(m, x, y)
+				changed = append(changed, grid.PathGrid{X: x, Y: y})
+			}
+			d.Update(changed)
+		}
+	}
+}
+
+func TestDStar_Incremental(t *testing.T) {
+	m := createTestGrid(64, 64)
+	for y := int32(10); y < 64; y++ {
+		m.Set(30, y)
+	}
+	d := NewDStar(m, 2, 2, 60, 60)
+	_, ok := d.Path()
+	require.True(t, ok)
+	full := d.Expanded()
+
+	// 与搜索无关的改动不需要展开任何节点
+	m.Set(20, 20)
+	d.Update([]grid.PathGrid{{X: 20, Y: 20}})
+	_, ok = d.Path()
+	require.True(t, ok)
+	assert.Zero(t, d.Expanded())
+
+	// 反向搜索：前进后在起点附近出现障碍，只需修复局部
+	d.Move(3, 3)
+	m.Set(5, 5)
+	d.Update([]grid.PathGrid{{X: 5, Y: 5}})
+	path, ok := d.Path()
+	require.True(t, ok)
+	fresh := NewDStar(m, 3, 3, 60, 60)
+	fresh.Path()
+	assert.Less(t, d.Expanded(), full/10)
+	assert.Less(t, d.Expanded(), fresh.Expanded()/10)
+	assert.InDelta(t, fresh.PathCost(), d.PathCost(), 1e-9)
+
+	ws := NewWorkSpace(64 * 64)
+	ws.Reset(m)
+	ws.SetCostModel(CostOctile)
+	ws.Solve(3, 3, 60, 60)
+	assert.InDelta(t, ws.PathCost(), d.PathCost(), 1e-9)
+	assert.Equal(t, -1, ws.Validate(path))
+
+	// 终点被围住则无路可走
+	for _, p := range []grid.PathGrid{{X: 59, Y: 59}, {X: 60, Y: 59}, {X: 61, Y: 59}, {X: 59, Y: 60}, {X: 61, Y: 60}, {X: 59, Y: 61}, {X: 60, Y: 61}, {X: 61, Y: 61}} {
+		m.Set(p.X, p.Y)
+	}
+	d.Update([]grid.PathGrid{{X: 60, Y: 60}})
+	_, ok = d.Path()
+	assert.False(t, ok)
+}
@@ -20,29 +20,35 @@ const naturalMargin = 0.05
 // keeps the natural path as a post-process of the discrete solver rather than
 // an independent full-map planner.
 func (ws *WorkSpace) SolveNatural(sx, sy, ex, ey float64) ([]grid.PathPoint, bool) {
+	path, _, ok := ws.solveNatural(sx, sy, ex, ey)
+	return path, ok
+}
+
+// solveNatural is SolveNatural that also returns the underlying JPS path.
+func (ws *WorkSpace) solveNatural(sx, sy, ex, ey float64) ([]grid.PathPoint, []grid.PathGrid, bool) {
 	if ws.Map == nil {
-		return nil, false
+		return nil, nil, false
 	}
 
 	startCellX, startCellY, ok := pointToWalkableGrid(ws.Map, sx, sy)
 	if !ok {
-		return nil, false
+		return nil, nil, false
 	}
 	endCellX, endCellY, ok := pointToWalkableGrid(ws.Map, ex, ey)
 	if !ok {
-		return nil, false
+		return nil, nil, false
 	}
 
 	gridPath, ok := ws.Solve(startCellX, startCellY, endCellX, endCellY)
 	if !ok {
-		return nil, false
+		return nil, nil, false
 	}
 
 	corridor := buildPathCorridor(ws.Map, expandGridPath(gridPath))
 	start := grid.PathPoint{X: sx, Y: sy}
 	end := grid.PathPoint{X: ex, Y: ey}
 	if segmentVisibleInCells(ws.Map, corridor, start, end) && segmentVisibleInMap(ws.Map, start, end) {
-		return []grid.PathPoint{start, end}, true
+		return []grid.PathPoint{start, end}, gridPath, true
 	}
 
 	nodes := make([]grid.PathPoint, 0, 2+len(corridor))
@@ -52,9 +58,9 @@ func (ws *WorkSpace) SolveNatural(sx, sy, ex, ey float64) ([]grid.PathPoint, boo
 
 	path, ok := shortestVisiblePathInCells(ws.Map, corridor, nodes)
 	if !ok {
-		return nil, false
+		return nil, nil, false
 	}
-	return compressNaturalPath(ws.Map, corridor, path), true
+	return compressNaturalPath(ws.Map, corridor, path), gridPath, true
 }
 
 type cellSet map[grid.Gpos]struct{}
@@ -75,7 +81,7 @@ type shortestState struct {
 
 type shortestHeap []shortestState
 
-func buildPathCorridor(m *grid.Local, path []grid.PathGrid) cellSet {
+func buildPathCorridor(m grid.Walkable, path []grid.PathGrid) cellSet {
 	cells := make(cellSet, len(path)*3)
 	for _, p := range path {
 		if cellInsideMap(m, p.X, p.Y) && m.Available(p.X, p.Y) {
@@ -102,7 +108,7 @@ func buildPathCorridor(m *grid.Local, path []grid.PathGrid) cellSet {
 	return dilateCorridor(m, cells)
 }
 
-func dilateCorridor(m *grid.Local, cells cellSet) cellSet {
+func dilateCorridor(m grid.Walkable, cells cellSet) cellSet {
 	expanded := make(cellSet, len(cells)*3)
 	for c := range cells {
 		expanded.add(c.X, c.Y)
@@ -230,7 +236,7 @@ func insetCornerPoint(cells cellSet, p grid.Gpos, mask boundaryDir, margin float
 	return grid.PathPoint{X: x, Y: y}
 }
 
-func shortestVisiblePathInCells(m *grid.Local, cells cellSet, nodes []grid.PathPoint) ([]grid.PathPoint, bool) {
+func shortestVisiblePathInCells(m grid.Walkable, cells cellSet, nodes []grid.PathPoint) ([]grid.PathPoint, bool) {
 	const eps = 1e-9
 
 	nodes = dedupePoints(nodes)
@@ -286,10 +292,9 @@ func shortestVisiblePathInCells(m *grid.Local, cells cellSet, nodes []grid.PathP
 	return path, true
 }
 
-func segmentVisibleInCells(m *grid.Local, cells cellSet, a, b grid.PathPoint) bool {
+func segmentVisibleInCells(m grid.Walkable, cells cellSet, a, b grid.PathPoint) bool {
 	return segmentVisibleWith(
-		m.Nx*16,
-		m.Ny*16,
+		m.Bounds(),
 		func(x, y int32) bool { return cells.has(x, y) },
 		0,
 		a,
@@ -297,22 +302,22 @@ func segmentVisibleInCells(m *grid.Local, cells cellSet, a, b grid.PathPoint) bo
 	)
 }
 
-func segmentVisibleInMap(m *grid.Local, a, b grid.PathPoint) bool {
-	return segmentVisibleWith(m.Nx*16, m.Ny*16, m.Available, naturalMargin, a, b)
+func segmentVisibleInMap(m grid.Walkable, a, b grid.PathPoint) bool {
+	return segmentVisibleWith(m.Bounds(), m.Available, naturalMargin, a, b)
 }
 
-func segmentVisibleWith(width, height int32, open func(int32, int32) bool, margin float64, a, b grid.PathPoint) bool {
-	if !pointInOpenSpace(width, height, open, a.X, a.Y) || !pointInOpenSpace(width, height, open, b.X, b.Y) {
+func segmentVisibleWith(r grid.Rect, open func(int32, int32) bool, margin float64, a, b grid.PathPoint) bool {
+	if !pointInOpenSpace(r, open, a.X, a.Y) || !pointInOpenSpace(r, open, b.X, b.Y) {
 		return false
 	}
 	if nearlyEqual(a.X, b.X) && nearlyEqual(a.Y, b.Y) {
 		return true
 	}
 	if nearlyEqual(a.X, b.X) && isIntegerCoord(a.X) {
-		return verticalBoundaryVisible(width, height, open, int32(math.Round(a.X)), a.Y, b.Y)
+		return verticalBoundaryVisible(r, open, int32(math.Round(a.X)), a.Y, b.Y)
 	}
 	if nearlyEqual(a.Y, b.Y) && isIntegerCoord(a.Y) {
-		return horizontalBoundaryVisible(width, height, open, int32(math.Round(a.Y)), a.X, b.X)
+		return horizontalBoundaryVisible(r, open, int32(math.Round(a.Y)), a.X, b.X)
 	}
 
 	ts := segmentBreakpoints(a, b)
@@ -320,7 +325,7 @@ func segmentVisibleWith(width, height int32, open func(int32, int32) bool, margi
 	for i := 1; i < len(ts); i++ {
 		tm := 0.5 * (ts[i-1] + ts[i])
 		x, y := segmentPoint(a, b, tm)
-		cell, ok := interiorCell(width, height, x, y, b.X-a.X, b.Y-a.Y)
+		cell, ok := interiorCell(r, x, y, b.X-a.X, b.Y-a.Y)
 		if !ok || !open(cell.X, cell.Y) {
 			return false
 		}
@@ -339,21 +344,21 @@ func segmentVisibleWith(width, height int32, open func(int32, int32) bool, margi
 		}
 		sideA := grid.Gpos{X: before.X, Y: after.Y}
 		sideB := grid.Gpos{X: after.X, Y: before.Y}
-		if !openCell(width, height, open, sideA.X, sideA.Y) && !openCell(width, height, open, sideB.X, sideB.Y) {
+		if !openCell(r, open, sideA.X, sideA.Y) && !openCell(r, open, sideB.X, sideB.Y) {
 			return false
 		}
 	}
 
-	return segmentHasMargin(width, height, open, a, b, margin)
+	return segmentHasMargin(r, open, a, b, margin)
 }
 
-func verticalBoundaryVisible(width, height int32, open func(int32, int32) bool, x int32, y1, y2 float64) bool {
+func verticalBoundaryVisible(r grid.Rect, open func(int32, int32) bool, x int32, y1, y2 float64) bool {
 	breaks := axisBreakpoints(y1, y2)
 	for i := 1; i < len(breaks); i++ {
 		ym := 0.5 * (breaks[i-1] + breaks[i])
 		row := int32(math.Floor(ym))
-		leftOpen := openCell(width, height, open, x-1, row)
-		rightOpen := openCell(width, height, open, x, row)
+		leftOpen := openCell(r, open, x-1, row)
+		rightOpen := openCell(r, open, x, row)
 		if !leftOpen && !rightOpen {
 			return false
 		}
@@ -361,10 +366,10 @@ func verticalBoundaryVisible(width, height int32, open func(int32, int32) bool,
 
 	for i := 1; i < len(breaks)-1; i++ {
 		vy := int32(math.Round(breaks[i]))
-		leftBelow := openCell(width, height, open, x-1, vy-1)
-		rightBelow := openCell(width, height, open, x, vy-1)
-		leftAbove := openCell(width, height, open, x-1, vy)
-		rightAbove := openCell(width, height, open, x, vy)
+		leftBelow := openCell(r, open, x-1, vy-1)
+		rightBelow := openCell(r, open, x, vy-1)
+		leftAbove := openCell(r, open, x-1, vy)
+		rightAbove := openCell(r, open, x, vy)
 
 		if leftBelow && rightAbove && !rightBelow && !leftAbove {
 			return false
@@ -376,13 +381,13 @@ func verticalBoundaryVisible(width, height int32, open func(int32, int32) bool,
 	return true
 }
 
-func horizontalBoundaryVisible(width, height int32, open func(int32, int32) bool, y int32, x1, x2 float64) bool {
+func horizontalBoundaryVisible(r grid.Rect, open func(int32, int32) bool, y int32, x1, x2 float64) bool {
 	breaks := axisBreakpoints(x1, x2)
 	for i := 1; i < len(breaks); i++ {
 		xm := 0.5 * (breaks[i-1] + breaks[i])
 		col := int32(math.Floor(xm))
-		bottomOpen := openCell(width, height, open, col, y-1)
-		topOpen := openCell(width, height, open, col, y)
+		bottomOpen := openCell(r, open, col, y-1)
+		topOpen := openCell(r, open, col, y)
 		if !bottomOpen && !topOpen {
 			return false
 		}
@@ -390,10 +395,10 @@ func horizontalBoundaryVisible(width, height int32, open func(int32, int32) bool
 
 	for i := 1; i < len(breaks)-1; i++ {
 		vx := int32(math.Round(breaks[i]))
-		leftBelow := openCell(width, height, open, vx-1, y-1)
-		leftAbove := openCell(width, height, open, vx-1, y)
-		rightBelow := openCell(width, height, open, vx, y-1)
-		rightAbove := openCell(width, height, open, vx, y)
+		leftBelow := openCell(r, open, vx-1, y-1)
+		leftAbove := openCell(r, open, vx-1, y)
+		rightBelow := openCell(r, open, vx, y-1)
+		rightAbove := openCell(r, open, vx, y)
 
 		if leftBelow && rightAbove && !leftAbove && !rightBelow {
 			return false
@@ -423,15 +428,15 @@ func axisBreakpoints(a, b float64) []float64 {
 	return dedupeFloats(points)
 }
 
-func segmentHasMargin(width, height int32, open func(int32, int32) bool, a, b grid.PathPoint, margin float64) bool {
+func segmentHasMargin(r grid.Rect, open func(int32, int32) bool, a, b grid.PathPoint, margin float64) bool {
 	if margin <= 0 {
 		return true
 	}
 
-	minX := clamp32(int32(math.Floor(minFloat(a.X, b.X)-margin))-1, 0, width-1)
-	maxX := clamp32(int32(math.Ceil(maxFloat(a.X, b.X)+margin))+1, 0, width-1)
-	minY := clamp32(int32(math.Floor(minFloat(a.Y, b.Y)-margin))-1, 0, height-1)
-	maxY := clamp32(int32(math.Ceil(maxFloat(a.Y, b.Y)+margin))+1, 0, height-1)
+	minX := clamp32(int32(math.Floor(minFloat(a.X, b.X)-margin))-1, r.MinX, r.MaxX-1)
+	maxX := clamp32(int32(math.Ceil(maxFloat(a.X, b.X)+margin))+1, r.MinX, r.MaxX-1)
+	minY := clamp32(int32(math.Floor(minFloat(a.Y, b.Y)-margin))-1, r.MinY, r.MaxY-1)
+	maxY := clamp32(int32(math.Ceil(maxFloat(a.Y, b.Y)+margin))+1, r.MinY, r.MaxY-1)
 
 	for x := minX; x <= maxX; x++ {
 		for y := minY; y <= maxY; y++ {
@@ -541,7 +546,7 @@ func segmentPoint(a, b grid.PathPoint, t float64) (float64, float64) {
 	return a.X + (b.X-a.X)*t, a.Y + (b.Y-a.Y)*t
 }
 
-func interiorCell(width, height int32, x, y, dx, dy float64) (grid.Gpos, bool) {
+func interiorCell(r grid.Rect, x, y, dx, dy float64) (grid.Gpos, bool) {
 	if isIntegerCoord(x) {
 		x = math.Nextafter(x, x+math.Copysign(1, dx))
 	}
@@ -550,23 +555,23 @@ func interiorCell(width, height int32, x, y, dx, dy float64) (grid.Gpos, bool) {
 	}
 	cx := int32(math.Floor(x))
 	cy := int32(math.Floor(y))
-	if !cellInsideBounds(width, height, cx, cy) {
+	if !cellInsideBounds(r, cx, cy) {
 		return grid.Gpos{}, false
 	}
 	return grid.Gpos{X: cx, Y: cy}, true
 }
 
-func pointInOpenSpace(width, height int32, open func(int32, int32) bool, x, y float64) bool {
+func pointInOpenSpace(r grid.Rect, open func(int32, int32) bool, x, y float64) bool {
 	if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
 		return false
 	}
-	if x < 0 || y < 0 || x > float64(width) || y > float64(height) {
+	if x < float64(r.MinX) || y < float64(r.MinY) || x > float64(r.MaxX) || y > float64(r.MaxY) {
 		return false
 	}
 
 	anyOpen := false
 	for _, c := range pointAdjacentCells(x, y) {
-		if !cellInsideBounds(width, height, c.X, c.Y) {
+		if !cellInsideBounds(r, c.X, c.Y) {
 			continue
 		}
 		if open(c.X, c.Y) {
@@ -580,8 +585,8 @@ func pointInOpenSpace(width, height int32, open func(int32, int32) bool, x, y fl
 	return anyOpen
 }
 
-func pointToWalkableGrid(m *grid.Local, x, y float64) (gx, gy int32, ok bool) {
-	if !pointInOpenSpace(m.Nx*16, m.Ny*16, m.Available, x, y) {
+func pointToWalkableGrid(m grid.Walkable, x, y float64) (gx, gy int32, ok bool) {
+	if !pointInOpenSpace(m.Bounds(), m.Available, x, y) {
 		return 0, 0, false
 	}
 	for _, c := range pointAdjacentCells(x, y) {
@@ -620,16 +625,16 @@ func pointAdjacentCells(x, y float64) []grid.Gpos {
 	return cells
 }
 
-func openCell(width, height int32, open func(int32, int32) bool, x, y int32) bool {
-	return cellInsideBounds(width, height, x, y) && open(x, y)
+func openCell(r grid.Rect, open func(int32, int32) bool, x, y int32) bool {
+	return cellInsideBounds(r, x, y) && open(x, y)
 }
 
-func cellInsideMap(m *grid.Local, x, y int32) bool {
-	return cellInsideBounds(m.Nx*16, m.Ny*16, x, y)
+func cellInsideMap(m grid.Walkable, x, y int32) bool {
+	return m.Bounds().Contains(x, y)
 }
 
-func cellInsideBounds(width, height, x, y int32) bool {
-	return uint32(x) < uint32(width) && uint32(y) < uint32(height)
+func cellInsideBounds(r grid.Rect, x, y int32) bool {
+	return r.Contains(x, y)
 }
 
 func expandGridPath(path []grid.PathGrid) []grid.PathGrid {
@@ -667,7 +672,7 @@ func gridPathCenters(path []grid.PathGrid) []grid.PathPoint {
 	return points
 }
 
-func compressNaturalPath(m *grid.Local, cells cellSet, path []grid.PathPoint) []grid.PathPoint {
+func compressNaturalPath(m grid.Walkable, cells cellSet, path []grid.PathPoint) []grid.PathPoint {
 	if len(path) < 3 {
 		return path
 	}
@@ -70,6 +70,34 @@ func TestWorkSpace_SolveNatural_WithObstacle(t *testing.T) {
 	assert.True(t, sawInset)
 }
 
+// 平移到负坐标的地图上，自然路径应整体平移
+func TestWorkSpace_SolveNatural_NegativeBounds(t *testing.T) {
+	const off = 100
+	local := createTestGrid(8, 8)
+	setObstacles(local, []grid.PathGrid{{X: 3, Y: 2}, {X: 3, Y: 3}, {X: 3, Y: 4}, {X: 4, Y: 4}})
+	shifted := grid.FuncMap{
+		R: grid.Rect{MinX: -off, MinY: -off, MaxX: 16 - off, MaxY: 16 - off},
+		F: func(x, y int32) bool { return local.Available(x+off, y+off) },
+	}
+
+	ws := NewWorkSpace(256)
+	ws.Reset(local)
+	want, ok := ws.SolveNatural(1.5, 3.5, 6.5, 3.5)
+	require.True(t, ok)
+
+	ws.Reset(shifted)
+	got, ok := ws.SolveNatural(1.5-off, 3.5-off, 6.5-off, 3.5-off)
+	require.True(t, ok)
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.InDelta(t, want[i].X-off, got[i].X, 1e-9)
+		assert.InDelta(t, want[i].Y-off, got[i].Y, 1e-9)
+	}
+
+	_, ok = ws.SolveNatural(-off-0.5, 0.5-off, 6.5-off, 3.5-off)
+	assert.False(t, ok, "start outside bounds")
+}
+
 func TestWorkSpace_SolveNatural_BlockedEndpoint(t *testing.T) {
 	local := createTestGrid(5, 5)
 	local.Set(1, 1)
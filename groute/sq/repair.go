@@ -0,0 +1,138 @@
+package sq
+
+import (
+	"math"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+)
+
+// repairMargin is how far, in cells, the local repair search may leave the
+// bounding box of the broken part of a path.
+const repairMargin = 16
+
+// Validate checks a path returned by Solve against the current map and
+// returns the index i of the first segment path[i] -> path[i+1] that is
+// blocked, or -1 if the whole path is walkable. Segments are checked between
+// cell centers with the line of sight rules of SolveNatural, which also
+// reject diagonal moves cutting a blocked corner. A single cell path is
+// blocked at 0 if its cell is.
+func (ws *WorkSpace) Validate(path []grid.PathGrid) int {
+	return ws.ValidateNatural(gridPathCenters(path))
+}
+
+// ValidateNatural is Validate for a path returned by SolveNatural.
+func (ws *WorkSpace) ValidateNatural(path []grid.PathPoint) int {
+	if len(path) == 1 && !segmentVisibleInMap(ws.Map, path[0], path[0]) {
+		return 0
+	}
+	for i := 1; i < len(path); i++ {
+		if !segmentVisibleInMap(ws.Map, path[i-1], path[i]) {
+			return i - 1
+		}
+	}
+	return -1
+}
+
+// Repair fixes a path returned by Solve whose segment path[from] ->
+// path[from+1] became blocked, e.g. the index returned by Validate. It
+// searches near the broken part from the last good waypoint to the first
+// later waypoint from which the rest of the path is still walkable, and
+// splices the result in. Only when that local search fails does it solve
+// again from the last good waypoint to the goal. The prefix up to the last
+// good waypoint is kept, the input path is not modified.
+func (ws *WorkSpace) Repair(path []grid.PathGrid, from int) ([]grid.PathGrid, bool) {
+	i, j, ok := repairRange(ws, gridPathCenters(path), from)
+	if !ok {
+		return nil, false
+	}
+	a, b := path[i], path[j]
+	if p, ok := solveIn(ws, ws.repairWindow(gridPathCenters(path[i:j+1])), func() ([]grid.PathGrid, bool) {
+		return ws.Solve(a.X, a.Y, b.X, b.Y)
+	}); ok {
+		return splice(path[:i], p, path[j+1:]), true
+	}
+	goal := path[len(path)-1]
+	p, ok := ws.Solve(a.X, a.Y, goal.X, goal.Y)
+	if !ok {
+		return nil, false
+	}
+	return splice(path[:i], p, nil), true
+}
+
+// RepairNatural is Repair for a path returned by SolveNatural.
+func (ws *WorkSpace) RepairNatural(path []grid.PathPoint, from int) ([]grid.PathPoint, bool) {
+	i, j, ok := repairRange(ws, path, from)
+	if !ok {
+		return nil, false
+	}
+	a, b := path[i], path[j]
+	if p, ok := solveIn(ws, ws.repairWindow(path[i:j+1]), func() ([]grid.PathPoint, bool) {
+		return ws.SolveNatural(a.X, a.Y, b.X, b.Y)
+	}); ok {
+		return splice(path[:i], p, path[j+1:]), true
+	}
+	goal := path[len(path)-1]
+	p, ok := ws.SolveNatural(a.X, a.Y, goal.X, goal.Y)
+	if !ok {
+		return nil, false
+	}
+	return splice(path[:i], p, nil), true
+}
+
+// repairRange returns the waypoints i <= from and j > from to reconnect:
+// path[:i+1] and path[j:] are walkable. j is the goal when no later
+// waypoint qualifies.
+func repairRange(ws *WorkSpace, path []grid.PathPoint, from int) (i, j int, ok bool) {
+	if ws.Map == nil || len(path) == 0 {
+		return 0, 0, false
+	}
+	i = min(max(from, 0), len(path)-1)
+	for i > 0 && ws.ValidateNatural(path[:i+1]) >= 0 {
+		i--
+	}
+	if ws.ValidateNatural(path[:1]) == 0 {
+		return 0, 0, false
+	}
+	last := len(path) - 1
+	for j = i + 1; j < last; j++ {
+		if ws.ValidateNatural(path[j:]) < 0 {
+			break
+		}
+	}
+	if j > last || ws.ValidateNatural(path[last:]) == 0 {
+		return 0, 0, false
+	}
+	return i, j, true
+}
+
+// repairWindow returns the map restricted to the bounding box of pts grown
+// by repairMargin.
+func (ws *WorkSpace) repairWindow(pts []grid.PathPoint) grid.Walkable {
+	b := ws.Map.Bounds()
+	r := grid.Rect{MinX: b.MaxX, MinY: b.MaxY, MaxX: b.MinX, MaxY: b.MinY}
+	for _, p := range pts {
+		x, y := int32(math.Floor(p.X)), int32(math.Floor(p.Y))
+		r.MinX, r.MinY = min(r.MinX, x-repairMargin), min(r.MinY, y-repairMargin)
+		r.MaxX, r.MaxY = max(r.MaxX, x+repairMargin+1), max(r.MaxY, y+repairMargin+1)
+	}
+	r.MinX, r.MinY = max(r.MinX, b.MinX), max(r.MinY, b.MinY)
+	r.MaxX, r.MaxY = min(r.MaxX, b.MaxX), min(r.MaxY, b.MaxY)
+	m := ws.Map
+	return grid.FuncMap{R: r, F: m.Available}
+}
+
+// solveIn runs solve with the workspace bound to window, then restores the
+// map.
+func solveIn[T any](ws *WorkSpace, window grid.Walkable, solve func() ([]T, bool)) ([]T, bool) {
+	m := ws.Map
+	ws.Reset(window)
+	defer ws.Reset(m)
+	return solve()
+}
+
+func splice[T any](head, mid, tail []T) []T {
+	out := make([]T, 0, len(head)+len(mid)+len(tail))
+	out = append(out, head...)
+	out = append(out, mid...)
+	return append(out, tail...)
+}
@@ -0,0 +1,90 @@
+package sq
+
+import (
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkSpace_Validate(t *testing.T) {
+	m := createTestGrid(32, 32)
+	ws := NewWorkSpace(32 * 32)
+	ws.Reset(m)
+
+	path := []grid.PathGrid{{X: 1, Y: 1}, {X: 5, Y: 5}, {X: 12, Y: 5}}
+	assert.Equal(t, -1, ws.Validate(path))
+	assert.Equal(t, -1, ws.Validate(path[:1]))
+
+	// 斜线切过障碍角也视为阻塞
+	m.Set(4, 3)
+	assert.Equal(t, 0, ws.Validate(path))
+	m.Grids[0][0].Bits[3] = 0
+	m.Set(8, 5)
+	assert.Equal(t, 1, ws.Validate(path))
+	m.Set(1, 1)
+	assert.Equal(t, 0, ws.Validate(path[:1]))
+
+	natural := []grid.PathPoint{{X: 1.5, Y: 8.5}, {X: 10.5, Y: 9.5}, {X: 10.5, Y: 20.5}}
+	assert.Equal(t, -1, ws.ValidateNatural(natural))
+	m.Set(10, 15)
+	assert.Equal(t, 1, ws.ValidateNatural(natural))
+}
+
+func TestWorkSpace_Repair(t *testing.T) {
+	m := createTestGrid(64, 64)
+	ws := NewWorkSpace(64 * 64)
+	ws.Reset(m)
+
+	path := []grid.PathGrid{{X: 1, Y: 1}, {X: 10, Y: 10}, {X: 30, Y: 10}, {X: 40, Y: 20}}
+	require.Equal(t, -1, ws.Validate(path))
+
+	// 局部修复：保留前缀和后缀，只替换损坏的一段
+	m.Set(20, 10)
+	i := ws.Validate(path)
+	require.Equal(t, 1, i)
+	fixed, ok := ws.Repair(path, i)
+	require.True(t, ok)
+	assert.Equal(t, -1, ws.Validate(fixed))
+	assert.Equal(t, path[:2], fixed[:2])
+	assert.Equal(t, path[2:], fixed[len(fixed)-2:])
+	assert.Equal(t, m, ws.Map, "map is restored")
+
+	// 墙超出局部窗口时退化为整段重新求解
+	for y := int32(0); y < 50; y++ {
+		m.Set(20, y)
+	}
+	fixed, ok = ws.Repair(path, ws.Validate(path))
+	require.True(t, ok)
+	assert.Equal(t, -1, ws.Validate(fixed))
+	assert.Equal(t, path[:2], fixed[:2])
+	assert.Equal(t, path[3], fixed[len(fixed)-1])
+
+	// 终点被堵死则修复失败
+	m.Set(40, 20)
+	_, ok = ws.Repair(path, ws.Validate(path))
+	assert.False(t, ok)
+}
+
+func TestWorkSpace_RepairNatural(t *testing.T) {
+	m := createTestGrid(64, 64)
+	ws := NewWorkSpace(64 * 64)
+	ws.Reset(m)
+
+	path, ok := ws.SolveNatural(2.5, 30.5, 60.5, 30.5)
+	require.True(t, ok)
+	require.Len(t, path, 2)
+
+	for y := int32(26); y < 36; y++ {
+		m.Set(30, y)
+	}
+	i := ws.ValidateNatural(path)
+	require.Equal(t, 0, i)
+	fixed, ok := ws.RepairNatural(path, i)
+	require.True(t, ok)
+	assert.Equal(t, -1, ws.ValidateNatural(fixed))
+	assert.Equal(t, path[0], fixed[0])
+	assert.Equal(t, path[1], fixed[len(fixed)-1])
+	assert.Greater(t, len(fixed), 2)
+}
@@ -1,10 +1,10 @@
 package sq
 
 import (
-	"slices"
+	"math"
 
 	"github.com/legamerdc/pathfinding/groute/grid"
-	"github.com/legamerdc/pathfinding/utils/heap"
+	"github.com/legamerdc/pathfinding/groute/jps"
 )
 
 /*
@@ -15,227 +15,228 @@ N, NE, E, SE, S, SW, W, NW
 	5  4  3		4  3  2
 */
 
-type dirSet uint32
-
 const (
-	_fullDirSetdirSet dirSet = (1 << 8) - 1
-	_emptyDirSet      dirSet = 0
-
-	_noDir = 0xff
+	_fullDirSet jps.DirSet = (1 << 8) - 1
 
 	avoidCorner = true
 )
 
-func (s *dirSet) dirAdd(d int32) {
-	*s |= 1 << d
-}
-func (s *dirSet) dirIter(f func(d int32) bool) {
-	for d := int32(0); d < 8; d++ {
-		if (*s)&(1<<d) != 0 {
-			if !f(d) {
-				return
-			}
-		}
-	}
-}
-
 type WorkSpace struct {
-	Map *grid.Local
+	Map grid.Walkable
 
-	pool       *grid.NodePool
-	heap       *heap.Heap[*grid.Gnode]
-	endX, endY int32
+	topo      topology
+	search    *jps.Search
+	queue     jps.QueueKind
+	landmarks *jps.Landmarks
+	resets    uint64 // counts Reset calls, so caches notice a rebound map
 }
 
 // NewWorkSpace creates a reusable square-grid search workspace.
 func NewWorkSpace(size int) *WorkSpace {
-	return &WorkSpace{
-		pool: grid.NewNodePool(int32(size)),
-		heap: heap.NewHeap[*grid.Gnode](size),
-	}
+	ws := &WorkSpace{}
+	ws.search = jps.NewSearch(&ws.topo, size)
+	return ws
 }
 
 // Reset binds the workspace to a map before running Solve or SolveNatural.
-func (ws *WorkSpace) Reset(m *grid.Local) {
+func (ws *WorkSpace) Reset(m grid.Walkable) {
 	ws.Map = m
+	ws.topo.reset(m)
+	ws.resets++
+}
+
+// SetQueue selects the open list implementation used by Solve. The bucket
+// queue needs one bucket per cost unit, so with the fixed-point cost models
+// Solve falls back to the binary heap until CostApprox is selected again.
+func (ws *WorkSpace) SetQueue(k jps.QueueKind) {
+	ws.queue = k
+	ws.applyQueue()
+}
+
+func (ws *WorkSpace) applyQueue() {
+	k := ws.queue
+	if k == jps.QueueBucket && ws.topo.cost != CostApprox {
+		k = jps.QueueHeap
+	}
+	if k != ws.search.Queue() {
+		ws.search.SetQueue(k)
+	}
+}
+
+// SetBidirectional switches Solve to bidirectional A*, see
+// jps.Search.SetBidirectional. It expands fewer nodes than a one-sided search
+// on large open maps and fails fast when the start or goal is enclosed.
+func (ws *WorkSpace) SetBidirectional(on bool) {
+	ws.search.SetBidirectional(on)
+}
+
+// SetWeight sets the heuristic weight of Solve for bounded suboptimal
+// search, see jps.Search.SetWeight.
+func (ws *WorkSpace) SetWeight(w float64) {
+	ws.search.SetWeight(w)
+}
+
+// Anytime starts an ARA* search that improves its path on every Step, see
+// jps.Search.Anytime. The map must not change until the search is dropped.
+func (ws *WorkSpace) Anytime(sx, sy, ex, ey int32, w, delta float64) *jps.Anytime {
+	ws.topo.rows.Invalidate()
+	return ws.search.Anytime(sx, sy, ex, ey, w, delta)
+}
+
+// SetTracer attaches a tracer that observes every following Solve. nil
+// disables tracing.
+func (ws *WorkSpace) SetTracer(t jps.Tracer) {
+	ws.search.SetTracer(t)
 }
 
 // Solve searches a path on the square grid from start to end cell coordinates.
 func (ws *WorkSpace) Solve(sx, sy, ex, ey int32) (p []grid.PathGrid, ok bool) {
-	ws.pool.Clear()
-	ws.heap.Clear()
-	ws.endX, ws.endY = ex, ey
-	ws.putInOpenSet(sx, sy, _noDir, sx, sy, 0)
-	for {
-		x, y, d, c, ok1 := ws.getOutOpenSet()
-		if !ok1 {
-			break
-		}
-		if x == ex && y == ey {
-			return ws.path(sx, sy)
-		}
-		set := ws.naturalDir(x, y, d) | ws.forceDir(x, y, d)
-		set.dirIter(func(nd int32) bool {
-			return !ws.jump(x, y, x, y, nd, c)
-		})
+	if ws.topo.cost != CostApprox && octile(sx, sy, ex, ey) == math.MaxInt32 {
+		return nil, false // out of range of the fixed-point costs
 	}
-	return nil, false
+	ws.topo.rows.Invalidate()
+	return ws.search.Solve(sx, sy, ex, ey)
 }
 
-func (ws *WorkSpace) jump(x, y, fx, fy, d, c int32) bool {
-	for {
-		x, y = move(x, y, d)
-		if !ws.Map.Available(x, y) {
-			return false
-		}
-		if avoidCorner && diagonal(d) {
-			if !(ws.walkable(x, y, d, 3) && ws.walkable(x, y, d, 5)) {
-				return false
-			}
-		}
-		if x == ws.endX && y == ws.endY {
-			ws.putInOpenSet(x, y, d, fx, fy, c)
-			return true
-		}
-		if ws.forceDir(x, y, d) > 0 {
-			ws.putInOpenSet(x, y, d, fx, fy, c)
-			return false
-		}
-		if diagonal(d) {
-			if ws.jump(x, y, fx, fy, (d+7)%8, c) {
-				return true
-			}
-			if ws.jump(x, y, fx, fy, (d+1)%8, c) {
-				return true
-			}
-		}
-	}
+// Exhausted reports whether the last Solve ran out of nodes, see
+// jps.Search.Exhausted.
+func (ws *WorkSpace) Exhausted() bool {
+	return ws.search.Exhausted()
 }
 
-func (ws *WorkSpace) getOutOpenSet() (x, y, d, c int32, ok bool) {
-	if ws.heap.Empty() {
-		return
-	}
-	node := ws.heap.Pop()
-	node.Status = grid.NodeClose
-	return node.Pos.X, node.Pos.Y, node.Dir, node.Cost, true
+// topology implements the square-grid jump rules for jps.Search.
+type topology struct {
+	m     grid.Walkable
+	local *grid.Local // m when it is a plain map, checked without dynamic dispatch
+	rows  grid.RowCache
+	// useRows tells that m implements grid.RowReader and is read through rows
+	useRows bool
+	cost    CostModel
 }
 
-func (ws *WorkSpace) putInOpenSet(x, y, d, fx, fy, c int32) {
-	node := ws.pool.GetNode(x, y)
-	if node == nil {
-		return
+// Step implements jps.Topology.
+func (t *topology) Step(x, y, d int32) (int32, int32, bool) {
+	x, y = move(x, y, d)
+	if !t.available(x, y) {
+		return x, y, false
 	}
-	switch node.Status {
-	case grid.NodeNew:
-		node.FPos = grid.Gpos{X: fx, Y: fy}
-		node.Dir = d
-		node.Cost = c + dist(x, y, fx, fy)
-		node.Total = node.Cost + dist(x, y, ws.endX, ws.endY)
-		node.Status = grid.NodeOpen
-		ws.heap.Push(node)
-	case grid.NodeOpen:
-		cost := c + dist(x, y, fx, fy)
-		if cost < node.Cost {
-			node.FPos = grid.Gpos{X: fx, Y: fy}
-			node.Dir = d
-			node.Cost = cost
-			node.Total = cost + dist(x, y, ws.endX, ws.endY)
-			ws.heap.Fix(node)
+	if avoidCorner && diagonal(d) {
+		if !(t.walkable(x, y, d, 3) && t.walkable(x, y, d, 5)) {
+			return x, y, false
 		}
-	case grid.NodeClose:
-		return
 	}
+	return x, y, true
 }
 
-func (ws *WorkSpace) naturalDir(x, y, curDir int32) (s dirSet) {
-	if curDir == _noDir {
-		return _fullDirSetdirSet
+// Natural implements jps.Topology.
+func (t *topology) Natural(x, y, curDir int32) (s jps.DirSet) {
+	if curDir == jps.NoDir {
+		return _fullDirSet
 	}
 	if avoidCorner {
 		if diagonal(curDir) {
-			if !ws.walkable(x, y, curDir, 7) {
-				s.dirAdd((curDir + 1) % 8)
-			} else if !ws.walkable(x, y, curDir, 1) {
-				s.dirAdd((curDir + 7) % 8)
+			if !t.walkable(x, y, curDir, 7) {
+				s.Add((curDir + 1) % 8)
+			} else if !t.walkable(x, y, curDir, 1) {
+				s.Add((curDir + 7) % 8)
 			} else {
-				s.dirAdd(curDir)
-				s.dirAdd((curDir + 1) % 8)
-				s.dirAdd((curDir + 7) % 8)
+				s.Add(curDir)
+				s.Add((curDir + 1) % 8)
+				s.Add((curDir + 7) % 8)
 			}
 		} else {
-			s.dirAdd(curDir)
+			s.Add(curDir)
 		}
 	} else {
-		s.dirAdd(curDir)
+		s.Add(curDir)
 		if diagonal(curDir) {
-			s.dirAdd((curDir + 1) % 8)
-			s.dirAdd((curDir + 7) % 8)
+			s.Add((curDir + 1) % 8)
+			s.Add((curDir + 7) % 8)
 		}
 	}
 	return s
 }
 
-func (ws *WorkSpace) forceDir(x, y, curDir int32) (s dirSet) {
-	if curDir == _noDir {
-		return _emptyDirSet
+// Forced implements jps.Topology.
+func (t *topology) Forced(x, y, curDir int32) (s jps.DirSet) {
+	if curDir == jps.NoDir {
+		return 0
 	}
 	if avoidCorner {
 		if !diagonal(curDir) {
-			if ws.walkable(x, y, curDir, 2) && !ws.walkable(x, y, curDir, 3) {
-				s.dirAdd((curDir + 2) % 8)
-				s.dirAdd((curDir + 1) % 8)
+			if t.walkable(x, y, curDir, 2) && !t.walkable(x, y, curDir, 3) {
+				s.Add((curDir + 2) % 8)
+				s.Add((curDir + 1) % 8)
 			}
-			if ws.walkable(x, y, curDir, 6) && !ws.walkable(x, y, curDir, 5) {
-				s.dirAdd((curDir + 6) % 8)
-				s.dirAdd((curDir + 7) % 8)
+			if t.walkable(x, y, curDir, 6) && !t.walkable(x, y, curDir, 5) {
+				s.Add((curDir + 6) % 8)
+				s.Add((curDir + 7) % 8)
 			}
 		}
 	} else {
 		if diagonal(curDir) {
-			if ws.walkable(x, y, curDir, 6) && !ws.walkable(x, y, curDir, 5) {
-				s.dirAdd((curDir + 6) % 8)
+			if t.walkable(x, y, curDir, 6) && !t.walkable(x, y, curDir, 5) {
+				s.Add((curDir + 6) % 8)
 			}
-			if ws.walkable(x, y, curDir, 2) && !ws.walkable(x, y, curDir, 3) {
-				s.dirAdd((curDir + 2) % 8)
+			if t.walkable(x, y, curDir, 2) && !t.walkable(x, y, curDir, 3) {
+				s.Add((curDir + 2) % 8)
 			}
 		} else {
-			if ws.walkable(x, y, curDir, 1) && !ws.walkable(x, y, curDir, 2) {
-				s.dirAdd((curDir + 1) % 8)
+			if t.walkable(x, y, curDir, 1) && !t.walkable(x, y, curDir, 2) {
+				s.Add((curDir + 1) % 8)
 			}
-			if ws.walkable(x, y, curDir, 7) && !ws.walkable(x, y, curDir, 6) {
-				s.dirAdd((curDir + 7) % 8)
+			if t.walkable(x, y, curDir, 7) && !t.walkable(x, y, curDir, 6) {
+				s.Add((curDir + 7) % 8)
 			}
 		}
 	}
 	return
 }
 
-func (ws *WorkSpace) walkable(x, y, curDir, nextDir int32) bool {
-	x, y = move(x, y, (curDir+nextDir)%8)
-	return ws.Map.Available(x, y)
+// Spread implements jps.Topology.
+func (t *topology) Spread(d int32) (int32, int32, bool) {
+	if !diagonal(d) {
+		return 0, 0, false
+	}
+	return (d + 7) % 8, (d + 1) % 8, true
 }
 
-func (ws *WorkSpace) path(sx, sy int32) (p []grid.PathGrid, ok bool) {
-	var (
-		fx, fy int32
-		x, y   = ws.endX, ws.endY
-		node   *grid.Gnode
-	)
-	for !(x == sx && y == sy) {
-		p = append(p, grid.PathGrid{X: x, Y: y})
-		if node = ws.pool.FindNode(x, y); node == nil {
-			return
-		}
-		fx, fy = node.FPos.X, node.FPos.Y
-		if mx, my, ok1 := midPoint(x, y, fx, fy); ok1 {
-			p = append(p, grid.PathGrid{X: mx, Y: my})
-		}
-		x, y = fx, fy
+// Dist implements jps.Topology.
+func (t *topology) Dist(x1, y1, x2, y2 int32) int32 {
+	if t.cost == CostApprox {
+		return dist(x1, y1, x2, y2)
 	}
-	p = append(p, grid.PathGrid{X: sx, Y: sy})
-	slices.Reverse(p)
-	return p, true
+	return octile(x1, y1, x2, y2)
+}
+
+// MidPoint implements jps.Topology.
+func (t *topology) MidPoint(x, y, fx, fy int32) (int32, int32, bool) {
+	return midPoint(x, y, fx, fy)
+}
+
+func (t *topology) reset(m grid.Walkable) {
+	t.m = m
+	t.local, _ = m.(*grid.Local)
+	r, ok := m.(grid.RowReader)
+	t.useRows = ok && t.local == nil
+	if t.useRows {
+		t.rows.Reset(r)
+	}
+}
+
+func (t *topology) available(x, y int32) bool {
+	switch {
+	case t.local != nil:
+		return t.local.Available(x, y)
+	case t.useRows:
+		return t.rows.Available(x, y)
+	}
+	return t.m.Available(x, y)
+}
+
+func (t *topology) walkable(x, y, curDir, nextDir int32) bool {
+	x, y = move(x, y, (curDir+nextDir)%8)
+	return t.available(x, y)
 }
 
 func move(x, y, d int32) (int32, int32) {
@@ -3,11 +3,13 @@ package sq
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand/v2"
 	"testing"
 	"time"
 
 	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/jps"
 	"github.com/legamerdc/pathfinding/utils/heap"
 )
 
@@ -181,6 +183,165 @@ func TestWorkSpace_ComplexMaze(t *testing.T) {
 	}
 }
 
+// 用文本地图描述同一类迷宫，路径叠加后直接比对
+func TestWorkSpace_TextMaze(t *testing.T) {
+	tm := grid.MustParseText(`
+S.#.....#...#.......
+..#.....#...#.......
+..#.....#.#####.....
+..#.#####...#.......
+..#.....#...#.......
+..#.....#...#..G....
+........#...........
+....................
+`)
+	ws := NewWorkSpace(400)
+	ws.Reset(tm.Map)
+
+	path, ok := solveWithTimeout(t, ws, tm.Start.X, tm.Start.Y, tm.Goal.X, tm.Goal.Y)
+	if !ok {
+		t.Fatal("应该找到穿过迷宫的路径")
+	}
+	want := `S.#.....#...#.......
+.*#.....#...#.......
+.*#.....#.#####.....
+.*#.#####...#.......
+.*#.....#...#.......
+.*#.....#...#.*G....
+.*......#.****......
+..********..........
+`
+	if got := grid.FormatText(tm.Map, tm.W, tm.H, grid.TextSquare, path); got != want {
+		t.Errorf("路径错误：\n%s期望：\n%s", got, want)
+	}
+}
+
+// 动态障碍叠加在静态地图上，不修改底图
+func TestWorkSpace_Overlay(t *testing.T) {
+	tm := grid.MustParseText(`
+S.....
+####..
+G.....
+`)
+	units := grid.NewLayer()
+	units.Block(4, 1, 1)
+	units.Block(5, 1, 2)
+	o := grid.NewOverlay(tm.Map, units)
+
+	ws := NewWorkSpace(400)
+	ws.Reset(o)
+	if _, ok := solveWithTimeout(t, ws, tm.Start.X, tm.Start.Y, tm.Goal.X, tm.Goal.Y); ok {
+		t.Fatal("通道被单位堵住，不应找到路径")
+	}
+
+	// 单位 2 忽略自己的占位
+	ws.Reset(o.Ignoring(2))
+	path, ok := solveWithTimeout(t, ws, tm.Start.X, tm.Start.Y, tm.Goal.X, tm.Goal.Y)
+	if !ok {
+		t.Fatal("忽略自身后应该找到路径")
+	}
+	want := `S*****
+#####*
+G*****
+`
+	if got := grid.FormatText(o, tm.W, tm.H, grid.TextSquare, path); got != want {
+		t.Errorf("路径错误：\n%s期望：\n%s", got, want)
+	}
+
+	units.Unblock(4, 1, 1)
+	ws.Reset(o)
+	if _, ok = solveWithTimeout(t, ws, tm.Start.X, tm.Start.Y, tm.Goal.X, tm.Goal.Y); !ok {
+		t.Fatal("移除单位后应该找到路径")
+	}
+	if !tm.Map.Available(5, 1) {
+		t.Error("底图不应被修改")
+	}
+}
+
+// 不同的 Walkable 实现（平移到负坐标的函数地图、走行缓存的叠加地图）应与 grid.Local 结果一致
+func TestWorkSpace_Walkable(t *testing.T) {
+	const off = 40
+	for seed := uint64(0); seed < 10; seed++ {
+		rng := rand.New(rand.NewPCG(seed, 0))
+		local := createTestGrid(48, 48)
+		for i := 0; i < 500; i++ {
+			local.Set(rng.Int32N(48), rng.Int32N(48))
+		}
+		sx, sy, ex, ey := int32(0), int32(0), int32(47), int32(47)
+		local.Grids[0][0].Bits[0] &^= 1
+		local.Grids[2][2].Bits[15] &^= 1 << 15
+
+		shifted := grid.FuncMap{
+			R: grid.Rect{MinX: -off, MinY: -off, MaxX: 48 - off, MaxY: 48 - off},
+			F: func(x, y int32) bool { return local.Available(x+off, y+off) },
+		}
+		maps := []grid.Walkable{local, grid.NewOverlay(local, grid.NewLayer()), shifted}
+
+		var want int32
+		for i, m := range maps {
+			ws := NewWorkSpace(48 * 48)
+			ws.Reset(m)
+			d := int32(0)
+			if i == 2 {
+				d = off
+			}
+			path, ok := solveWithTimeout(t, ws, sx-d, sy-d, ex-d, ey-d)
+			if i == 0 {
+				want = pathCost(path)
+				continue
+			}
+			if ok != (want > 0) || pathCost(path) != want {
+				t.Errorf("seed %d map %d: cost %d, want %d", seed, i, pathCost(path), want)
+			}
+		}
+	}
+}
+
+// 按需加载的分块世界，跨越负坐标搜索
+func TestWorkSpace_World(t *testing.T) {
+	blocked := func(x, y int32) bool {
+		h := uint32(x*73856093) ^ uint32(y*19349663)
+		return h%7 == 0
+	}
+	bounds := grid.Rect{MinX: -50, MinY: -50, MaxX: 50, MaxY: 50}
+	world := grid.NewWorld(grid.WorldOptions{
+		Bounds:   bounds,
+		Capacity: 16,
+		Loader: func(bx, by int32) (*grid.Grid, bool) {
+			g := new(grid.Grid)
+			for iy := int32(0); iy < 16; iy++ {
+				for ix := int32(0); ix < 16; ix++ {
+					if blocked(bx*16+ix, by*16+iy) {
+						g.Bits[iy] |= 1 << ix
+					}
+				}
+			}
+			return g, true
+		},
+	})
+	ref := grid.FuncMap{R: bounds, F: func(x, y int32) bool { return !blocked(x, y) }}
+
+	ws := NewWorkSpace(10000)
+	for _, q := range [][4]int32{{-45, -45, 45, 40}, {-3, 2, 30, -41}, {10, 10, -49, 49}} {
+		if blocked(q[0], q[1]) || blocked(q[2], q[3]) {
+			continue
+		}
+		ws.Reset(ref)
+		want, ok := solveWithTimeout(t, ws, q[0], q[1], q[2], q[3])
+		ws.Reset(world)
+		got, ok1 := solveWithTimeout(t, ws, q[0], q[1], q[2], q[3])
+		if !ok {
+			t.Fatalf("query %v: 应该找到路径", q)
+		}
+		if ok != ok1 || pathCost(got) != pathCost(want) {
+			t.Errorf("query %v: cost %d, want %d", q, pathCost(got), pathCost(want))
+		}
+	}
+	if s := world.Stats(); s.Resident > 16 || s.Evictions == 0 {
+		t.Errorf("LRU 未生效：%+v", s)
+	}
+}
+
 func TestWorkSpace_BoundaryCheck(t *testing.T) {
 	local := createTestGrid(10, 10)
 
@@ -202,6 +363,44 @@ func TestWorkSpace_BoundaryCheck(t *testing.T) {
 	}
 }
 
+// 路径代价（5/7 度量）
+func pathCost(path []grid.PathGrid) int32 {
+	var c int32
+	for i := 1; i < len(path); i++ {
+		c += dist(path[i-1].X, path[i-1].Y, path[i].X, path[i].Y)
+	}
+	return c
+}
+
+func TestWorkSpace_QueueKinds(t *testing.T) {
+	local := createComplexMaze()
+	queries := [][4]int32{{5, 5, 95, 95}, {0, 0, 99, 0}, {50, 0, 50, 99}, {99, 99, 0, 30}}
+
+	ws := NewWorkSpace(10000)
+	ws.Reset(local)
+	want := make([]int32, len(queries))
+	for i, q := range queries {
+		path, ok := solveWithTimeout(t, ws, q[0], q[1], q[2], q[3])
+		if !ok {
+			t.Fatalf("应该找到路径 %v", q)
+		}
+		want[i] = pathCost(path)
+	}
+
+	for _, kind := range []jps.QueueKind{jps.QueueQuadHeap, jps.QueueBucket} {
+		ws.SetQueue(kind)
+		for i, q := range queries {
+			path, ok := solveWithTimeout(t, ws, q[0], q[1], q[2], q[3])
+			if !ok {
+				t.Fatalf("queue %d: 应该找到路径 %v", kind, q)
+			}
+			if got := pathCost(path); got != want[i] {
+				t.Errorf("queue %d: 路径代价 %d，期望 %d", kind, got, want[i])
+			}
+		}
+	}
+}
+
 func TestMove(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -278,48 +477,255 @@ func TestDiagonal(t *testing.T) {
 	}
 }
 
-func TestDirSet(t *testing.T) {
-	var s dirSet
+func TestWorkSpace_Bidirectional(t *testing.T) {
+	ws := NewWorkSpace(2304)
+	bi := NewWorkSpace(2304)
+	bi.SetBidirectional(true)
+	for _, cm := range []CostModel{CostApprox, CostOctile} {
+		ws.SetCostModel(cm)
+		bi.SetCostModel(cm)
+		for seed := int64(0); seed < 20; seed++ {
+			m := createDemoSquareMap(seed)
+			ws.Reset(m)
+			bi.Reset(m)
+			_, ok := ws.Solve(0, 0, 47, 47)
+			got, ok1 := bi.Solve(0, 0, 47, 47)
+			if ok != ok1 {
+				t.Fatalf("seed %d: found %v, want %v", seed, ok1, ok)
+			}
+			if !ok {
+				continue
+			}
+			if ws.PathCost() != bi.PathCost() {
+				t.Errorf("seed %d model %d: cost %v, want %v", seed, cm, bi.PathCost(), ws.PathCost())
+			}
+			if i := bi.Validate(got); i >= 0 {
+				t.Errorf("seed %d: segment %d blocked: %v", seed, i, got)
+			}
+		}
+	}
 
-	// 测试添加方向
-	s.dirAdd(0)
-	s.dirAdd(2)
-	s.dirAdd(4)
+	// 终点被围住时，反向搜索很快耗尽
+	local := createTestGrid(64, 64)
+	for _, p := range []grid.PathGrid{{X: 59, Y: 59}, {X: 60, Y: 59}, {X: 61, Y: 59}, {X: 59, Y: 60}, {X: 61, Y: 60}, {X: 59, Y: 61}, {X: 60, Y: 61}, {X: 61, Y: 61}} {
+		local.Set(p.X, p.Y)
+	}
+	bi.Reset(local)
+	var closed int
+	bi.SetTracer(closedCounter{&closed})
+	if _, ok := bi.Solve(2, 2, 60, 60); ok {
+		t.Fatal("goal is enclosed")
+	}
+	if closed > 4 {
+		t.Errorf("expanded %d nodes", closed)
+	}
+}
 
-	// 测试迭代
-	var directions []int32
-	s.dirIter(func(d int32) bool {
-		directions = append(directions, d)
-		return true
-	})
+func TestWorkSpace_BidirectionalExhausted(t *testing.T) {
+	// 节点池小于地图：双向搜索耗尽节点后退回跳点搜索，而不是报告无路
+	local := createTestGrid(128, 128)
+	for y := int32(0); y < 120; y++ {
+		local.Set(64, y)
+	}
+	ws := NewWorkSpace(1200)
+	ws.Reset(local)
+	_, ok := ws.Solve(0, 0, 127, 0)
+	if !ok || ws.Exhausted() {
+		t.Fatalf("jps: found %v, exhausted %v", ok, ws.Exhausted())
+	}
+	ws.SetBidirectional(true)
+	got, ok := ws.Solve(0, 0, 127, 0)
+	if !ok {
+		t.Fatalf("bidirectional: no path, exhausted %v", ws.Exhausted())
+	}
+	want := ws.PathCost()
+	if i := ws.Validate(got); i >= 0 {
+		t.Errorf("segment %d blocked: %v", i, got)
+	}
+	ws.SetBidirectional(false)
+	ws.Solve(0, 0, 127, 0)
+	if ws.PathCost() != want {
+		t.Errorf("cost %v, want %v", want, ws.PathCost())
+	}
+	ws.SetBidirectional(true)
+
+	// 跳点搜索也放不下时，失败要能与不可达区分
+	small := NewWorkSpace(1)
+	small.Reset(local)
+	small.SetBidirectional(true)
+	if _, ok := small.Solve(0, 0, 127, 0); ok || !small.Exhausted() {
+		t.Errorf("found %v, exhausted %v", ok, small.Exhausted())
+	}
+	local.Set(127, 1)
+	local.Set(126, 0)
+	local.Set(126, 1)
+	ws.Reset(local)
+	if _, ok := ws.Solve(0, 0, 127, 0); ok || ws.Exhausted() {
+		t.Errorf("enclosed goal: found %v, exhausted %v", ok, ws.Exhausted())
+	}
+}
+
+type closedCounter struct{ n *int }
+
+func (c closedCounter) Opened(x, y, fx, fy, cost, total int32)  {}
+func (c closedCounter) Closed(x, y, cost int32)                 { *c.n++ }
+func (c closedCounter) Jump(jps.JumpScan)                       {}
+func (c closedCounter) Forced(x, y, d int32, forced jps.DirSet) {}
 
-	expected := []int32{0, 2, 4}
-	if len(directions) != len(expected) {
-		t.Errorf("方向数量错误，期望 %d，得到 %d", len(expected), len(directions))
+type jumpRecorder struct {
+	closedCounter
+	jumps *[]jps.JumpScan
+}
+
+func (r jumpRecorder) Jump(scan jps.JumpScan) { *r.jumps = append(*r.jumps, scan) }
+
+// 对角扫描的直线分支到达终点时，对角扫描本身也要报告
+func TestWorkSpace_TracerDiagonalGoal(t *testing.T) {
+	ws := NewWorkSpace(256)
+	ws.Reset(createTestGrid(16, 16))
+	var closed int
+	var jumps []jps.JumpScan
+	ws.SetTracer(jumpRecorder{closedCounter{&closed}, &jumps})
+	if _, ok := ws.Solve(0, 0, 3, 5); !ok {
+		t.Fatal("no path")
+	}
+	if len(jumps) < 2 {
+		t.Fatalf("got %d jumps", len(jumps))
 	}
+	branch, diag := jumps[len(jumps)-2], jumps[len(jumps)-1]
+	if branch.Result != jps.JumpGoal || branch.EndX != 3 || branch.EndY != 5 {
+		t.Errorf("branch %+v", branch)
+	}
+	if diag.Result != jps.JumpGoal || diag.X != 0 || diag.Y != 0 || diag.Len != 3 || diag.EndX != 3 || diag.EndY != 3 {
+		t.Errorf("diagonal %+v", diag)
+	}
+	if branch.X != diag.EndX || branch.Y != diag.EndY {
+		t.Errorf("branch %+v does not start where the diagonal stopped", branch)
+	}
+}
 
-	for i, d := range expected {
-		if i >= len(directions) || directions[i] != d {
-			t.Errorf("方向 %d：期望 %d，得到 %d", i, d, directions[i])
+func TestWorkSpace_Weighted(t *testing.T) {
+	ws := NewWorkSpace(2304)
+	ws.SetCostModel(CostOctile)
+	var optimal, weighted int
+	for seed := int64(0); seed < 20; seed++ {
+		m := createDemoSquareMap(seed)
+		ws.Reset(m)
+		ws.SetWeight(0)
+		ws.SetTracer(closedCounter{&optimal})
+		_, ok := ws.Solve(0, 0, 47, 47)
+		want := ws.PathCost()
+
+		ws.SetWeight(1.2)
+		ws.SetTracer(closedCounter{&weighted})
+		path, ok1 := ws.Solve(0, 0, 47, 47)
+		if ok != ok1 {
+			t.Fatalf("seed %d: found %v, want %v", seed, ok1, ok)
 		}
+		if !ok {
+			continue
+		}
+		if got := ws.PathCost(); got > 1.2*want+1e-9 || got < want-1e-9 {
+			t.Errorf("seed %d: cost %v, optimal %v", seed, got, want)
+		}
+		if i := ws.Validate(path); i >= 0 {
+			t.Errorf("seed %d: segment %d blocked", seed, i)
+		}
+	}
+	ws.SetTracer(nil)
+	if weighted >= optimal {
+		t.Errorf("weighted search expanded %d nodes, optimal %d", weighted, optimal)
 	}
 }
 
-// 测试提前终止迭代
-func TestDirSet_EarlyTermination(t *testing.T) {
-	var s dirSet
-	s.dirAdd(0)
-	s.dirAdd(1)
-	s.dirAdd(2)
+func TestWorkSpace_Anytime(t *testing.T) {
+	ws := NewWorkSpace(2304)
+	ws.SetCostModel(CostOctile)
+	for seed := int64(0); seed < 20; seed++ {
+		m := createDemoSquareMap(seed)
+		ws.Reset(m)
+		_, ok := ws.Solve(0, 0, 47, 47)
+		want := ws.PathCost()
+
+		a := ws.Anytime(0, 0, 47, 47, 3, 0.5)
+		last := math.Inf(1)
+		for i := 0; ; i++ {
+			path, bound, ok1 := a.Step()
+			if ok != ok1 {
+				t.Fatalf("seed %d: found %v, want %v", seed, ok1, ok)
+			}
+			if !ok {
+				break
+			}
+			cost := float64(a.Cost()) / octileUnit
+			if bound > last || bound > a.Weight() || cost > bound*want+1e-9 {
+				t.Fatalf("seed %d step %d: bound %v after %v, cost %v, optimal %v", seed, i, bound, last, cost, want)
+			}
+			if i := ws.Validate(path); i >= 0 {
+				t.Fatalf("seed %d: segment %d blocked", seed, i)
+			}
+			last = bound
+			if bound == 1 {
+				if math.Abs(cost-want) > 1e-9 {
+					t.Errorf("seed %d: final cost %v, optimal %v", seed, cost, want)
+				}
+				break
+			}
+			if i > 10 {
+				t.Fatalf("seed %d: no convergence", seed)
+			}
+		}
+	}
+}
 
-	var count int
-	s.dirIter(func(d int32) bool {
-		count++
-		return count < 2 // 只处理前2个方向
-	})
+func TestWorkSpace_Landmarks(t *testing.T) {
+	ws := NewWorkSpace(2304)
+	alt := NewWorkSpace(2304)
+	for _, cm := range []CostModel{CostApprox, CostOctile, CostOctileEuclidH} {
+		ws.SetCostModel(cm)
+		alt.SetCostModel(cm)
+		var plain, closed int
+		for seed := int64(0); seed < 20; seed++ {
+			m := createDemoSquareMap(seed)
+			ws.Reset(m)
+			alt.Reset(m)
+			if !alt.SetLandmarks(alt.BuildLandmarks(8)) {
+				t.Fatal("landmarks rejected")
+			}
+			ws.SetTracer(closedCounter{&plain})
+			alt.SetTracer(closedCounter{&closed})
+			_, ok := ws.Solve(0, 0, 47, 47)
+			path, ok1 := alt.Solve(0, 0, 47, 47)
+			if ok != ok1 {
+				t.Fatalf("model %d seed %d: found %v, want %v", cm, seed, ok1, ok)
+			}
+			if !ok {
+				continue
+			}
+			if ws.PathCost() != alt.PathCost() {
+				t.Errorf("model %d seed %d: cost %v, want %v", cm, seed, alt.PathCost(), ws.PathCost())
+			}
+			if i := alt.Validate(path); i >= 0 {
+				t.Errorf("model %d seed %d: segment %d blocked", cm, seed, i)
+			}
+		}
+		if closed >= plain {
+			t.Errorf("model %d: ALT expanded %d nodes, plain %d", cm, closed, plain)
+		}
+	}
 
-	if count != 2 {
-		t.Errorf("应该只处理2个方向，实际处理了 %d 个", count)
+	// 代价单位不同的表格不能使用，切换代价模型会移除表格
+	alt.SetCostModel(CostApprox)
+	l := alt.BuildLandmarks(2)
+	alt.SetCostModel(CostOctile)
+	if alt.SetLandmarks(l) {
+		t.Error("landmarks of CostApprox accepted under CostOctile")
+	}
+	alt.SetCostModel(CostApprox)
+	alt.SetLandmarks(l)
+	alt.SetCostModel(CostOctile)
+	if alt.landmarks != nil {
+		t.Error("landmarks kept after a unit change")
 	}
 }
 
@@ -365,8 +771,8 @@ func BenchmarkWorkSpace_DiagonalPath(b *testing.B) {
 	}
 }
 
-// 基准测试：复杂迷宫
-func BenchmarkWorkSpace_ComplexMaze(b *testing.B) {
+// 创建复杂迷宫（100x100）
+func createComplexMaze() *grid.Local {
 	local := createTestGrid(100, 100)
 
 	// 创建复杂迷宫
@@ -393,6 +799,12 @@ func BenchmarkWorkSpace_ComplexMaze(b *testing.B) {
 	}
 
 	setObstacles(local, obstacles)
+	return local
+}
+
+// 基准测试：复杂迷宫
+func BenchmarkWorkSpace_ComplexMaze(b *testing.B) {
+	local := createComplexMaze()
 
 	ws := NewWorkSpace(10000)
 	ws.Reset(local)
@@ -406,6 +818,60 @@ func BenchmarkWorkSpace_ComplexMaze(b *testing.B) {
 	}
 }
 
+// 基准测试：不同开放列表实现
+func BenchmarkWorkSpace_Queue(b *testing.B) {
+	local := createComplexMaze()
+	for _, q := range []struct {
+		name string
+		kind jps.QueueKind
+	}{
+		{"Heap", jps.QueueHeap},
+		{"QuadHeap", jps.QueueQuadHeap},
+		{"Bucket", jps.QueueBucket},
+	} {
+		b.Run(q.name, func(b *testing.B) {
+			ws := NewWorkSpace(10000)
+			ws.Reset(local)
+			ws.SetQueue(q.kind)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, ok := benchSolveWithTimeout(b, ws, 5, 5, 95, 95)
+				if !ok {
+					b.Fatal("应该找到路径")
+				}
+			}
+		})
+	}
+}
+
+// 基准测试：不同 Walkable 实现的开销
+func BenchmarkWorkSpace_Walkable(b *testing.B) {
+	local := createComplexMaze()
+	units := grid.NewLayer()
+	units.Block(50, 50, 1)
+	for _, m := range []struct {
+		name string
+		m    grid.Walkable
+	}{
+		{"Local", local},
+		{"Overlay", grid.NewOverlay(local, units)},
+		{"Func", grid.FuncMap{R: local.Bounds(), F: local.Available}},
+	} {
+		b.Run(m.name, func(b *testing.B) {
+			ws := NewWorkSpace(10000)
+			ws.Reset(m.m)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, ok := ws.Solve(5, 5, 95, 95); !ok {
+					b.Fatal("应该找到路径")
+				}
+			}
+		})
+	}
+}
+
 // 基准测试：大型网格
 func BenchmarkWorkSpace_LargeGrid(b *testing.B) {
 	local := createTestGrid(200, 200)
@@ -597,3 +1063,18 @@ func BenchmarkRealPath(b *testing.B) {
 	}
 	fmt.Println(ok)
 }
+
+// 基准测试：大型网格上的双向搜索
+func BenchmarkWorkSpace_Bidirectional(b *testing.B) {
+	local := createTestGrid(200, 200)
+	ws := NewWorkSpace(40000)
+	ws.Reset(local)
+	ws.SetBidirectional(true)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := ws.Solve(0, 0, 199, 199); !ok {
+			b.Fatal("应该找到路径")
+		}
+	}
+}
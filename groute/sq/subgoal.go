@@ -0,0 +1,385 @@
+package sq
+
+import (
+	"math"
+	"slices"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/utils/heap"
+)
+
+// SubgoalGraph answers queries on a static map with a Simple Subgoal Graph:
+// subgoals are placed at the convex corners of obstacles and connected when
+// one is directly h-reachable from the other, i.e. reachable by a shortest
+// move sequence that does not pass another subgoal. A query links start and
+// goal to the graph, searches the small graph and refines the result into
+// the waypoint format of Solve, with the same moves and corner rules, so it
+// is usually far faster than jump point search.
+//
+// The map must not change after the graph is built. A graph reuses its
+// search state, so it is not safe for concurrent queries.
+type SubgoalGraph struct {
+	Map  grid.Walkable
+	Rect grid.Rect
+
+	topo  topology
+	cells []grid.PathGrid // subgoal positions by id
+	id    []int32         // subgoal id + 1 by cell index, 0 for none
+	edges [][]sgEdge
+
+	// query state
+	nodes []sgNode
+	open  *heap.Heap[*sgNode]
+	gen   uint32
+	s, e  grid.PathGrid
+	links []sgLink // edges of the start
+	cost  int32
+}
+
+// sgEdge connects to a subgoal over a diagonal run to the cell mid followed
+// by a straight run. mid equals one end if the move is a single line.
+type sgEdge struct {
+	to  int32
+	mid grid.PathGrid
+}
+
+// sgLink is an edge of the start of a query, leaving over its first step via.
+type sgLink struct {
+	sgEdge
+	via  grid.PathGrid
+	cost int32
+}
+
+type sgNode struct {
+	id      int32
+	g, f    int32
+	parent  int32
+	mid     grid.PathGrid
+	via     grid.PathGrid // the cell the edge from parent starts at
+	gen     uint32
+	closed  bool
+	index   int32
+	toGoal  bool // directly h-reachable from a goal outside the graph
+	goalMid grid.PathGrid
+}
+
+// Compare implements heap.Node, preferring deeper nodes on ties.
+func (n *sgNode) Compare(o *sgNode) int32 {
+	if n.f != o.f {
+		return n.f - o.f
+	}
+	return o.g - n.g
+}
+
+// GetHeapIndex implements heap.Node.
+func (n *sgNode) GetHeapIndex() int32 { return n.index }
+
+// SetHeapIndex implements heap.Node.
+func (n *sgNode) SetHeapIndex(i int32) { n.index = i }
+
+// NewSubgoalGraph builds the subgoal graph of m. It keeps one int32 per cell
+// of m.Bounds(), so the bounds must be finite and reasonably small.
+func NewSubgoalGraph(m grid.Walkable) *SubgoalGraph {
+	g := &SubgoalGraph{Map: m, Rect: m.Bounds(), open: heap.NewHeap[*sgNode](64)}
+	g.topo.reset(m)
+	g.topo.rows.Invalidate()
+	r := g.Rect
+	w, h := int(r.MaxX-r.MinX), int(r.MaxY-r.MinY)
+	if w <= 0 || h <= 0 {
+		return g
+	}
+	g.id = make([]int32, w*h)
+	for y := r.MinY; y < r.MaxY; y++ {
+		for x := r.MinX; x < r.MaxX; x++ {
+			if g.corner(x, y) {
+				g.cells = append(g.cells, grid.PathGrid{X: x, Y: y})
+				g.id[g.index(x, y)] = int32(len(g.cells))
+			}
+		}
+	}
+
+	// direct-h-reachability is not symmetric, edges are
+	g.edges = make([][]sgEdge, len(g.cells))
+	for u, c := range g.cells {
+		g.scan(c.X, c.Y, g.subgoal, func(to, mid grid.PathGrid) {
+			v := g.id[g.index(to.X, to.Y)] - 1
+			g.edges[u] = append(g.edges[u], sgEdge{to: v, mid: mid})
+			g.edges[v] = append(g.edges[v], sgEdge{to: int32(u), mid: mid})
+		})
+	}
+	for u := range g.edges {
+		slices.SortFunc(g.edges[u], func(a, b sgEdge) int { return int(a.to - b.to) })
+		g.edges[u] = slices.CompactFunc(g.edges[u], func(a, b sgEdge) bool { return a.to == b.to })
+	}
+	g.nodes = make([]sgNode, len(g.cells)+2)
+	return g
+}
+
+// SetCostModel selects the cost model used by Solve and PathCost. Shortest
+// moves are the same under every model, so the graph needs no rebuild.
+func (g *SubgoalGraph) SetCostModel(m CostModel) {
+	g.topo.cost = m
+}
+
+// Subgoals returns the number of subgoals.
+func (g *SubgoalGraph) Subgoals() int {
+	return len(g.cells)
+}
+
+// Edges returns the number of directed edges between subgoals.
+func (g *SubgoalGraph) Edges() int {
+	n := 0
+	for _, es := range g.edges {
+		n += len(es)
+	}
+	return n
+}
+
+// PathCost returns the cost of the path found by the last successful Solve
+// in cells, measured by the current cost model.
+func (g *SubgoalGraph) PathCost() float64 {
+	return float64(g.cost) / float64(g.topo.cost.Unit())
+}
+
+// Solve searches a shortest path from start to end cell coordinates and
+// returns it in the format of WorkSpace.Solve.
+func (g *SubgoalGraph) Solve(sx, sy, ex, ey int32) ([]grid.PathGrid, bool) {
+	g.topo.rows.Invalidate()
+	// like Solve, only the goal has to be free
+	if !g.Rect.Contains(sx, sy) || !g.free(ex, ey) {
+		return nil, false
+	}
+	g.s, g.e = grid.PathGrid{X: sx, Y: sy}, grid.PathGrid{X: ex, Y: ey}
+	if g.s == g.e {
+		g.cost = 0
+		return []grid.PathGrid{g.s}, true
+	}
+	if g.gen++; g.gen == 0 {
+		for i := range g.nodes {
+			g.nodes[i].gen = 0
+		}
+		g.gen = 1
+	}
+
+	n := int32(len(g.cells))
+	src, dst := n, g.id[g.index(ex, ey)]-1
+	if dst < 0 {
+		dst = n + 1
+	}
+	g.links = g.links[:0]
+	link := func(via grid.PathGrid, c int32) {
+		g.scan(via.X, via.Y, func(x, y int32) bool { return x == ex && y == ey || g.subgoal(x, y) }, func(to, mid grid.PathGrid) {
+			v := dst
+			if to != g.e {
+				v = g.id[g.index(to.X, to.Y)] - 1
+			}
+			g.links = append(g.links, sgLink{sgEdge{v, mid}, via, c + g.topo.Dist(via.X, via.Y, to.X, to.Y)})
+		})
+	}
+	if g.free(sx, sy) {
+		link(g.s, 0)
+		// a goal in direct reach needs no graph search
+		for _, l := range g.links {
+			if l.to == dst {
+				g.cost = l.cost
+				return appendRun(nil, g.s, l.mid, g.e), true
+			}
+		}
+	} else {
+		// a blocked start misses the corners of its own cell, so link every
+		// first step instead
+		for d := int32(0); d < 8; d++ {
+			x, y, ok := g.topo.Step(sx, sy, d)
+			if !ok || !g.Rect.Contains(x, y) {
+				continue
+			}
+			if x == ex && y == ey {
+				g.cost = g.topo.Dist(sx, sy, ex, ey)
+				return []grid.PathGrid{g.s, g.e}, true
+			}
+			link(grid.PathGrid{X: x, Y: y}, g.topo.Dist(sx, sy, x, y))
+		}
+	}
+	if dst == n+1 {
+		g.scan(ex, ey, g.subgoal, func(to, mid grid.PathGrid) {
+			nd := g.node(g.id[g.index(to.X, to.Y)] - 1)
+			nd.toGoal, nd.goalMid = true, mid
+		})
+	}
+	if !g.search(src, dst) {
+		return nil, false
+	}
+	g.cost = g.nodes[dst].g
+
+	p := []grid.PathGrid{g.e}
+	for v := dst; v != src; v = g.nodes[v].parent {
+		nd := &g.nodes[v]
+		from := g.cell(nd.parent)
+		if nd.parent == src {
+			from = nd.via
+		}
+		if nd.mid != from && nd.mid != g.cell(v) {
+			p = append(p, nd.mid)
+		}
+		if from != g.s {
+			p = append(p, from)
+		}
+	}
+	p = append(p, g.s)
+	slices.Reverse(p)
+	return p, true
+}
+
+// search runs A* over the graph from the start node src to dst.
+func (g *SubgoalGraph) search(src, dst int32) bool {
+	g.open.Clear()
+	start := g.node(src)
+	start.g, start.f = 0, g.topo.Dist(g.s.X, g.s.Y, g.e.X, g.e.Y)
+	g.open.Push(start)
+	for !g.open.Empty() {
+		nd := g.open.Pop()
+		nd.closed = true
+		if nd.id == dst {
+			return true
+		}
+		if nd.id == src {
+			for _, l := range g.links {
+				g.relax(nd, l.to, l.cost, l.mid, l.via)
+			}
+			continue
+		}
+		c := g.cells[nd.id]
+		if nd.toGoal {
+			g.relax(nd, dst, nd.g+g.topo.Dist(c.X, c.Y, g.e.X, g.e.Y), nd.goalMid, c)
+		}
+		for _, ed := range g.edges[nd.id] {
+			to := g.cells[ed.to]
+			g.relax(nd, ed.to, nd.g+g.topo.Dist(c.X, c.Y, to.X, to.Y), ed.mid, c)
+		}
+	}
+	return false
+}
+
+// relax offers node v the cost c over the node from.
+func (g *SubgoalGraph) relax(from *sgNode, v, c int32, mid, via grid.PathGrid) {
+	nd := g.node(v)
+	if nd.closed || c >= nd.g {
+		return
+	}
+	cv := g.cell(v)
+	nd.g, nd.f, nd.parent = c, c+g.topo.Dist(cv.X, cv.Y, g.e.X, g.e.Y), from.id
+	nd.mid, nd.via = mid, via
+	if g.open.Contains(nd) {
+		g.open.Fix(nd)
+	} else {
+		g.open.Push(nd)
+	}
+}
+
+// node returns the search state of node id v, reset for the current query.
+func (g *SubgoalGraph) node(v int32) *sgNode {
+	nd := &g.nodes[v]
+	if nd.gen != g.gen {
+		*nd = sgNode{id: v, g: math.MaxInt32, parent: -1, gen: g.gen, index: -1}
+	}
+	return nd
+}
+
+// cell returns the position of node id v.
+func (g *SubgoalGraph) cell(v int32) grid.PathGrid {
+	switch n := int32(len(g.cells)); v {
+	case n:
+		return g.s
+	case n + 1:
+		return g.e
+	}
+	return g.cells[v]
+}
+
+// scan visits the targets directly h-reachable from (x, y): it follows every
+// straight direction, and every diagonal with straight runs to both sides,
+// up to the first target or obstacle. The straight runs off a diagonal never
+// reach further than those of the previous diagonal cell, which keeps to
+// targets whose shortest moves are not blocked by another target. visit
+// gets the target and the cell where the diagonal run turns.
+func (g *SubgoalGraph) scan(x, y int32, target func(x, y int32) bool, visit func(to, mid grid.PathGrid)) {
+	var reach [8]int32
+	from := grid.PathGrid{X: x, Y: y}
+	for d := int32(0); d < 8; d += 2 {
+		reach[d] = g.run(x, y, d, math.MaxInt32, target, from, visit)
+	}
+	for d := int32(1); d < 8; d += 2 {
+		c1, c2 := (d+7)%8, (d+1)%8
+		r1, r2 := reach[c1], reach[c2]
+		px, py := x, y
+		for {
+			nx, ny, ok := g.topo.Step(px, py, d)
+			if !ok || !g.Rect.Contains(nx, ny) {
+				break
+			}
+			px, py = nx, ny
+			p := grid.PathGrid{X: px, Y: py}
+			if target(px, py) {
+				visit(p, p)
+				break
+			}
+			r1 = g.run(px, py, c1, r1, target, p, visit)
+			r2 = g.run(px, py, c2, r2, target, p, visit)
+		}
+	}
+}
+
+// run scans up to limit cells from (x, y) in the straight direction d and
+// returns how many free cells precede the first target or obstacle.
+func (g *SubgoalGraph) run(x, y, d, limit int32, target func(x, y int32) bool, mid grid.PathGrid, visit func(to, mid grid.PathGrid)) int32 {
+	for k := int32(1); k <= limit; k++ {
+		x, y = move(x, y, d)
+		if !g.free(x, y) {
+			return k - 1
+		}
+		if target(x, y) {
+			visit(grid.PathGrid{X: x, Y: y}, mid)
+			return k - 1
+		}
+	}
+	return limit
+}
+
+// corner reports whether (x, y) is a free cell next to the convex corner of
+// an obstacle: a blocked diagonal neighbour whose two adjacent cells are
+// free, so shortest paths may bend there.
+func (g *SubgoalGraph) corner(x, y int32) bool {
+	if !g.free(x, y) {
+		return false
+	}
+	for d := int32(1); d < 8; d += 2 {
+		cx, cy := move(x, y, d)
+		ax, ay := move(x, y, (d+7)%8)
+		bx, by := move(x, y, (d+1)%8)
+		if !g.free(cx, cy) && g.free(ax, ay) && g.free(bx, by) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *SubgoalGraph) subgoal(x, y int32) bool {
+	return g.id[g.index(x, y)] != 0
+}
+
+func (g *SubgoalGraph) free(x, y int32) bool {
+	return g.Rect.Contains(x, y) && g.topo.available(x, y)
+}
+
+func (g *SubgoalGraph) index(x, y int32) int {
+	return int(y-g.Rect.MinY)*int(g.Rect.MaxX-g.Rect.MinX) + int(x-g.Rect.MinX)
+}
+
+// appendRun appends the move from a over the turning cell mid to b.
+func appendRun(p []grid.PathGrid, a, mid, b grid.PathGrid) []grid.PathGrid {
+	p = append(p, a)
+	if mid != a && mid != b {
+		p = append(p, mid)
+	}
+	return append(p, b)
+}
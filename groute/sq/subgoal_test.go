@@ -0,0 +1,88 @@
+package sq
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubgoalGraph_MatchesSolve(t *testing.T) {
+	ws := NewWorkSpace(48 * 48)
+	for seed := int64(0); seed < 20; seed++ {
+		m := createDemoSquareMap(seed)
+		ws.Reset(m)
+		sg := NewSubgoalGraph(m)
+		require.Positive(t, sg.Subgoals())
+		rng := rand.New(rand.NewSource(seed))
+		for _, cm := range []CostModel{CostApprox, CostOctile} {
+			ws.SetCostModel(cm)
+			sg.SetCostModel(cm)
+			for q := 0; q < 50; q++ {
+				sx, sy, ex, ey := rng.Int31n(48), rng.Int31n(48), rng.Int31n(48), rng.Int31n(48)
+				want, ok := ws.Solve(sx, sy, ex, ey)
+				got, ok1 := sg.Solve(sx, sy, ex, ey)
+				require.Equal(t, ok, ok1, "seed %d (%d,%d)->(%d,%d)", seed, sx, sy, ex, ey)
+				if !ok {
+					continue
+				}
+				assert.Equal(t, ws.PathCost(), sg.PathCost(), "seed %d (%d,%d)->(%d,%d)", seed, sx, sy, ex, ey)
+				assert.Equal(t, want[0], got[0])
+				assert.Equal(t, want[len(want)-1], got[len(got)-1])
+				// 起点可能是障碍，从第二个路点开始检查
+				assert.Equal(t, -1, ws.Validate(got[1:]), "seed %d: %v", seed, got)
+				// 每一段都是横竖或 45 度的直线，与 Solve 的格式一致
+				for i := 1; i < len(got); i++ {
+					dx, dy := got[i].X-got[i-1].X, got[i].Y-got[i-1].Y
+					assert.True(t, dx == 0 || dy == 0 || dx == dy || dx == -dy, "seed %d: %v", seed, got)
+				}
+			}
+		}
+	}
+}
+
+func TestSubgoalGraph_Corners(t *testing.T) {
+	tm := grid.MustParseText(`
+S . . . . .
+. . . . . .
+. . # # . .
+. . # # . .
+. . . . . .
+. . . . . G
+`)
+	sg := NewSubgoalGraph(tm.Map)
+	// 方块障碍的四个外角各有一个子目标
+	assert.Equal(t, 4, sg.Subgoals())
+	assert.Equal(t, 8, sg.Edges())
+
+	path, ok := sg.Solve(tm.Start.X, tm.Start.Y, tm.Goal.X, tm.Goal.Y)
+	require.True(t, ok)
+	assert.Len(t, path, 5)
+	assert.Equal(t, 8.8, sg.PathCost())
+
+	_, ok = sg.Solve(tm.Start.X, tm.Start.Y, 2, 2)
+	assert.False(t, ok)
+	path, ok = sg.Solve(1, 1, 1, 1)
+	assert.True(t, ok)
+	assert.Len(t, path, 1)
+}
+
+// 基准测试：子目标图与跳点搜索的查询速度
+func BenchmarkSubgoalGraph(b *testing.B) {
+	m := createDemoSquareMap(1)
+	sg := NewSubgoalGraph(m)
+	ws := NewWorkSpace(48 * 48)
+	ws.Reset(m)
+	b.Run("subgoal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sg.Solve(0, 0, 47, 47)
+		}
+	})
+	b.Run("jps", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ws.Solve(0, 0, 47, 47)
+		}
+	})
+}
@@ -0,0 +1,373 @@
+// Package tiled imports maps made with the Tiled editor (https://www.mapeditor.org)
+// as collision grids.
+//
+// Both the XML (.tmx) and JSON (.tmj) formats are read. Orthogonal maps
+// become square grids; hexagonal maps must stagger rows (staggeraxis "y")
+// with staggerindex "odd", which is the odd-r layout of package hex.
+//
+// A cell is blocked in a layer when
+//   - the layer is a tile layer with the property set to true and the cell
+//     holds a tile,
+//   - the tile in the cell has the property set to true in its tileset,
+//   - the cell centre lies inside an object of an object layer, unless the
+//     object or its layer sets the property to false.
+//
+// The property name is "blocked" unless Options.Property says otherwise.
+package tiled
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+)
+
+// ErrUnsupported is returned for map features the importer cannot express
+// as a grid.
+var ErrUnsupported = errors.New("tiled: unsupported map")
+
+// Map is an imported collision map.
+type Map struct {
+	// Width and Height are the map size in cells. Blocked and every layer
+	// mask are padded to whole blocks with blocked cells.
+	Width, Height int32
+	// Hex tells that the map uses the odd-r hex layout.
+	Hex bool
+	// Blocked is the union of all layers.
+	Blocked *grid.Local
+	// Layers holds one mask per named tile or object layer. Layers inside
+	// groups are keyed by their own name, so tile and object layers must
+	// have distinct names; the import fails with ErrUnsupported otherwise.
+	Layers map[string]*grid.Local
+}
+
+// Options controls the import.
+type Options struct {
+	// Property is the boolean property that marks blocked tiles, layers and
+	// objects. Empty means "blocked".
+	Property string
+	// Open opens external tilesets by their source attribute. It is
+	// required for maps with external tilesets; LoadFile sets it to open
+	// files next to the map.
+	Open func(source string) (io.ReadCloser, error)
+}
+
+// LoadFile reads a .tmx or .tmj file.
+func LoadFile(name string, opt Options) (*Map, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if opt.Open == nil {
+		dir := filepath.Dir(name)
+		opt.Open = func(source string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(dir, filepath.FromSlash(source)))
+		}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tmx":
+		return ReadTMX(f, opt)
+	case ".tmj", ".json":
+		return ReadTMJ(f, opt)
+	}
+	return nil, fmt.Errorf("%s: unknown map format", name)
+}
+
+// document is the format independent content of a map file.
+type document struct {
+	orientation  string
+	staggerAxis  string
+	staggerIndex string
+	infinite     bool
+	width        int32
+	height       int32
+	tileW, tileH float64
+	hexSide      float64
+	tilesets     []tileset
+	layers       []layer
+}
+
+type tileset struct {
+	firstGID uint32
+	source   string
+	tiles    map[uint32]properties // by local tile id
+}
+
+type layerKind uint8
+
+const (
+	tileLayer layerKind = iota
+	objectLayer
+	groupLayer
+)
+
+type layer struct {
+	kind    layerKind
+	name    string
+	props   properties
+	data    []uint32
+	objects []object
+	layers  []layer
+}
+
+type object struct {
+	x, y, w, h float64
+	rotation   float64
+	gid        uint32
+	ellipse    bool
+	point      bool
+	polygon    [][2]float64
+	props      properties
+}
+
+// properties holds custom properties as strings.
+type properties map[string]string
+
+// flag reads a boolean property.
+func (p properties) flag(name string) (v, ok bool) {
+	s, ok := p[name]
+	if !ok {
+		return false, false
+	}
+	return s == "true" || s == "1", true
+}
+
+// gid flag bits used by Tiled for flipped and rotated tiles.
+const gidMask = 0x0fffffff
+
+// build turns a decoded document into masks.
+func (d *document) build(opt Options) (*Map, error) {
+	prop := opt.Property
+	if prop == "" {
+		prop = "blocked"
+	}
+	if d.infinite {
+		return nil, fmt.Errorf("%w: infinite maps", ErrUnsupported)
+	}
+	if d.width <= 0 || d.height <= 0 || d.tileW <= 0 || d.tileH <= 0 {
+		return nil, fmt.Errorf("%w: bad map size", grid.ErrFormat)
+	}
+	if (int64(d.width)+15)/16*((int64(d.height)+15)/16) > grid.MaxBlocks {
+		return nil, fmt.Errorf("%w: map of %dx%d cells is too large", grid.ErrFormat, d.width, d.height)
+	}
+	m := &Map{Width: d.width, Height: d.height, Layers: make(map[string]*grid.Local)}
+	switch d.orientation {
+	case "orthogonal":
+	case "hexagonal":
+		if d.staggerAxis != "y" || d.staggerIndex != "odd" {
+			return nil, fmt.Errorf("%w: hexagonal maps need staggeraxis y and staggerindex odd", ErrUnsupported)
+		}
+		m.Hex = true
+	default:
+		return nil, fmt.Errorf("%w: %q orientation", ErrUnsupported, d.orientation)
+	}
+
+	blockedTiles := make(map[uint32]bool)
+	for _, ts := range d.tilesets {
+		for id, p := range ts.tiles {
+			if v, _ := p.flag(prop); v {
+				blockedTiles[ts.firstGID+id] = true
+			}
+		}
+	}
+
+	m.Blocked = d.newMask()
+	var walk func(ls []layer) error
+	walk = func(ls []layer) error {
+		for i := range ls {
+			l := &ls[i]
+			if l.kind == groupLayer {
+				if err := walk(l.layers); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, ok := m.Layers[l.name]; ok {
+				return fmt.Errorf("%w: duplicate layer name %q", ErrUnsupported, l.name)
+			}
+			mask := d.newMask()
+			switch l.kind {
+			case tileLayer:
+				if err := d.fillTiles(mask, l, prop, blockedTiles); err != nil {
+					return err
+				}
+			case objectLayer:
+				d.fillObjects(mask, l, prop)
+			}
+			m.Layers[l.name] = mask
+			d.merge(m.Blocked, mask)
+		}
+		return nil
+	}
+	if err := walk(d.layers); err != nil {
+		return nil, err
+	}
+	d.pad(m.Blocked)
+	for _, mask := range m.Layers {
+		d.pad(mask)
+	}
+	return m, nil
+}
+
+func (d *document) newMask() *grid.Local {
+	m := grid.NewLocal((d.width+15)/16, (d.height+15)/16)
+	for i := int32(0); i < m.Nx; i++ {
+		for j := int32(0); j < m.Ny; j++ {
+			m.SetGrid(i, j, new(grid.Grid))
+		}
+	}
+	return m
+}
+
+func (d *document) pad(m *grid.Local) {
+	for y := int32(0); y < m.Ny*16; y++ {
+		for x := int32(0); x < m.Nx*16; x++ {
+			if x >= d.width || y >= d.height {
+				m.Set(x, y)
+			}
+		}
+	}
+}
+
+func (d *document) merge(dst, src *grid.Local) {
+	for i := int32(0); i < dst.Nx; i++ {
+		for j := int32(0); j < dst.Ny; j++ {
+			a, b := dst.GetGrid(i, j), src.GetGrid(i, j)
+			for k := range a.Bits {
+				a.Bits[k] |= b.Bits[k]
+			}
+		}
+	}
+}
+
+func (d *document) fillTiles(mask *grid.Local, l *layer, prop string, blocked map[uint32]bool) error {
+	if len(l.data) != int(d.width)*int(d.height) {
+		return fmt.Errorf("%w: layer %q has %d tiles, want %d", grid.ErrFormat, l.name, len(l.data), d.width*d.height)
+	}
+	all, _ := l.props.flag(prop)
+	for i, gid := range l.data {
+		gid &= gidMask
+		if gid != 0 && (all || blocked[gid]) {
+			mask.Set(int32(i)%d.width, int32(i)/d.width)
+		}
+	}
+	return nil
+}
+
+func (d *document) fillObjects(mask *grid.Local, l *layer, prop string) {
+	if v, ok := l.props.flag(prop); ok && !v {
+		return
+	}
+	for i := range l.objects {
+		o := &l.objects[i]
+		if v, ok := o.props.flag(prop); ok && !v {
+			continue
+		}
+		if o.point {
+			continue
+		}
+		x0, y0, x1, y1 := d.cells(o.bounds())
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				if cx, cy := d.center(x, y); o.contains(cx, cy) {
+					mask.Set(x, y)
+				}
+			}
+		}
+	}
+}
+
+// center returns the pixel position of the centre of cell (x, y).
+func (d *document) center(x, y int32) (float64, float64) {
+	if d.orientation == "hexagonal" {
+		rowH := (d.tileH + d.hexSide) / 2
+		cx := (float64(x) + 0.5 + 0.5*float64(y&1)) * d.tileW
+		return cx, float64(y)*rowH + d.tileH/2
+	}
+	return (float64(x) + 0.5) * d.tileW, (float64(y) + 0.5) * d.tileH
+}
+
+// cells returns the range of cells whose centres may lie in the pixel
+// rectangle (minX, minY)-(maxX, maxY), clipped to the map. The range is
+// empty when x0 > x1 or y0 > y1.
+func (d *document) cells(minX, minY, maxX, maxY float64) (x0, y0, x1, y1 int32) {
+	if !(minX <= maxX && minY <= maxY) || maxX < 0 || maxY < 0 {
+		return 0, 0, -1, -1
+	}
+	rowH := d.tileH
+	if d.orientation == "hexagonal" {
+		rowH = (d.tileH + d.hexSide) / 2
+	}
+	clip := func(v float64, n int32) int32 {
+		return int32(max(0, min(v, float64(n-1))))
+	}
+	// a centre lies less than one cell right of and below its cell's origin
+	x0, x1 = clip(math.Floor(minX/d.tileW)-1, d.width), clip(math.Ceil(maxX/d.tileW), d.width)
+	y0, y1 = clip(math.Floor(minY/rowH)-1, d.height), clip(math.Ceil(maxY/rowH), d.height)
+	return x0, y0, x1, y1
+}
+
+// bounds returns the pixel bounding box of the object.
+func (o *object) bounds() (minX, minY, maxX, maxY float64) {
+	minX, minY, maxX, maxY = 0, 0, o.w, o.h
+	if len(o.polygon) >= 3 {
+		minX, minY, maxX, maxY = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, p := range o.polygon {
+			minX, maxX = min(minX, p[0]), max(maxX, p[0])
+			minY, maxY = min(minY, p[1]), max(maxY, p[1])
+		}
+	} else if o.gid != 0 {
+		minY, maxY = -o.h, 0
+	}
+	if o.rotation == 0 {
+		return o.x + minX, o.y + minY, o.x + maxX, o.y + maxY
+	}
+	s, c := math.Sincos(o.rotation * math.Pi / 180)
+	corners := [4][2]float64{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}}
+	minX, minY, maxX, maxY = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range corners {
+		x, y := o.x+p[0]*c-p[1]*s, o.y+p[0]*s+p[1]*c
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// contains reports whether pixel (px, py) lies inside the object.
+func (o *object) contains(px, py float64) bool {
+	x, y := o.x, o.y
+	if o.gid != 0 {
+		y -= o.h // tile objects are anchored at the bottom left
+	}
+	// undo the rotation around the object origin
+	if o.rotation != 0 {
+		s, c := math.Sincos(-o.rotation * math.Pi / 180)
+		dx, dy := px-o.x, py-o.y
+		px, py = o.x+dx*c-dy*s, o.y+dx*s+dy*c
+	}
+	px, py = px-x, py-y
+	switch {
+	case len(o.polygon) >= 3:
+		in := false
+		for i, j := 0, len(o.polygon)-1; i < len(o.polygon); j, i = i, i+1 {
+			a, b := o.polygon[i], o.polygon[j]
+			if (a[1] > py) != (b[1] > py) && px < (b[0]-a[0])*(py-a[1])/(b[1]-a[1])+a[0] {
+				in = !in
+			}
+		}
+		return in
+	case o.ellipse:
+		if o.w <= 0 || o.h <= 0 {
+			return false
+		}
+		dx, dy := px/o.w*2-1, py/o.h*2-1
+		return dx*dx+dy*dy <= 1
+	}
+	return px >= 0 && py >= 0 && px < o.w && py < o.h
+}
@@ -0,0 +1,265 @@
+package tiled
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+	"github.com/legamerdc/pathfinding/groute/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 6x4 正交地图：tile 2 (local id 1) 为墙，water 层整层阻挡，
+// objects 层的矩形覆盖 (4,0)-(5,1)，点对象和 blocked=false 的对象不阻挡
+const orthoTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" renderorder="right-down" width="6" height="4" tilewidth="32" tileheight="32" infinite="0">
+ <tileset firstgid="1" name="terrain" tilewidth="32" tileheight="32" tilecount="4" columns="4">
+  <tile id="1">
+   <properties>
+    <property name="blocked" type="bool" value="true"/>
+   </properties>
+  </tile>
+ </tileset>
+ <layer id="1" name="walls" width="6" height="4">
+  <data encoding="csv">
+1,2,1,1,1,1,
+1,2,1,1,1,1,
+1,1,1,2,1,1,
+1,1,1,1,1,1
+</data>
+ </layer>
+ <group id="5" name="extra">
+  <layer id="2" name="water" width="6" height="4">
+   <properties>
+    <property name="blocked" type="bool" value="true"/>
+   </properties>
+   <data encoding="csv">
+0,0,0,0,0,0,
+0,0,0,0,0,0,
+0,0,0,0,0,0,
+3,3,0,0,0,0
+</data>
+  </layer>
+ </group>
+ <objectgroup id="3" name="objects">
+  <object id="1" x="128" y="0" width="64" height="64"/>
+  <object id="2" x="16" y="80">
+   <point/>
+  </object>
+  <object id="3" x="0" y="64" width="64" height="32">
+   <properties>
+    <property name="blocked" type="bool" value="false"/>
+   </properties>
+  </object>
+ </objectgroup>
+</map>
+`
+
+const orthoWant = `
+.#..##
+.#..##
+...#..
+##....
+`
+
+func formatMask(m *grid.Local, w, h int32) string {
+	return "\n" + grid.FormatText(m, w, h, grid.TextSquare, nil)
+}
+
+func TestReadTMX_Orthogonal(t *testing.T) {
+	m, err := ReadTMX(strings.NewReader(orthoTMX), Options{})
+	require.NoError(t, err)
+	assert.False(t, m.Hex)
+	assert.Equal(t, int32(6), m.Width)
+	assert.Equal(t, int32(4), m.Height)
+	assert.Equal(t, orthoWant, formatMask(m.Blocked, 6, 4))
+	assert.False(t, m.Blocked.Available(6, 0)) // 补齐部分为障碍
+
+	require.Len(t, m.Layers, 3)
+	assert.Equal(t, "\n.#....\n.#....\n...#..\n......\n", formatMask(m.Layers["walls"], 6, 4))
+	assert.Equal(t, "\n......\n......\n......\n##....\n", formatMask(m.Layers["water"], 6, 4))
+	assert.Equal(t, "\n....##\n....##\n......\n......\n", formatMask(m.Layers["objects"], 6, 4))
+}
+
+func encodeGIDs(gids []uint32) string {
+	var raw, z bytes.Buffer
+	_ = binary.Write(&raw, binary.LittleEndian, gids)
+	w := zlib.NewWriter(&z)
+	_, _ = w.Write(raw.Bytes())
+	_ = w.Close()
+	return base64.StdEncoding.EncodeToString(z.Bytes())
+}
+
+func TestReadTMJ_Orthogonal(t *testing.T) {
+	walls := encodeGIDs([]uint32{
+		1, 2, 1, 1, 1, 1,
+		1, 2 | 0x80000000, 1, 1, 1, 1, // 翻转标记不影响 tile id
+		1, 1, 1, 2, 1, 1,
+		1, 1, 1, 1, 1, 1,
+	})
+	tmj := fmt.Sprintf(`{
+ "orientation": "orthogonal", "width": 6, "height": 4, "tilewidth": 32, "tileheight": 32, "infinite": false,
+ "tilesets": [{"firstgid": 1, "source": "terrain.tsj"}],
+ "layers": [
+  {"type": "tilelayer", "name": "walls", "width": 6, "height": 4,
+   "encoding": "base64", "compression": "zlib", "data": %q},
+  {"type": "group", "name": "extra", "layers": [
+   {"type": "tilelayer", "name": "water", "width": 6, "height": 4,
+    "properties": [{"name": "blocked", "type": "bool", "value": true}],
+    "data": [0,0,0,0,0,0, 0,0,0,0,0,0, 0,0,0,0,0,0, 3,3,0,0,0,0]}]},
+  {"type": "objectgroup", "name": "objects", "objects": [
+   {"id": 1, "x": 128, "y": 0, "width": 64, "height": 64},
+   {"id": 2, "x": 16, "y": 80, "point": true},
+   {"id": 3, "x": 0, "y": 64, "width": 64, "height": 32,
+    "properties": [{"name": "blocked", "type": "bool", "value": false}]}]}
+ ]}`, walls)
+
+	_, err := ReadTMJ(strings.NewReader(tmj), Options{})
+	assert.ErrorIs(t, err, ErrUnsupported) // 外部 tileset 需要 Open
+
+	opened := ""
+	opt := Options{Open: func(source string) (io.ReadCloser, error) {
+		opened = source
+		return io.NopCloser(strings.NewReader(`{"name": "terrain", "tiles": [
+			{"id": 1, "properties": [{"name": "blocked", "type": "bool", "value": true}]}]}`)), nil
+	}}
+	m, err := ReadTMJ(strings.NewReader(tmj), opt)
+	require.NoError(t, err)
+	assert.Equal(t, "terrain.tsj", opened)
+	assert.Equal(t, orthoWant, formatMask(m.Blocked, 6, 4))
+	assert.Len(t, m.Layers, 3)
+}
+
+func TestReadTMX_Property(t *testing.T) {
+	// 使用其他属性名时，默认的 blocked 属性不再生效：
+	// 墙和水不再阻挡，对象 3 也不再被排除
+	m, err := ReadTMX(strings.NewReader(orthoTMX), Options{Property: "solid"})
+	require.NoError(t, err)
+	assert.Equal(t, "\n....##\n....##\n##....\n......\n", formatMask(m.Blocked, 6, 4))
+}
+
+// 奇数行右移的六边形地图，与 hex.Move 的 odd-r 约定一致
+const hexTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="hexagonal" width="5" height="4" tilewidth="28" tileheight="32" hexsidelength="16" staggeraxis="y" staggerindex="%s" infinite="0">
+ <tileset firstgid="1" source="hex.tsx"/>
+ <layer id="1" name="ground" width="5" height="4">
+  <data>
+   <tile gid="1"/><tile gid="1"/><tile gid="1"/><tile gid="1"/><tile gid="1"/>
+   <tile gid="1"/><tile gid="2"/><tile gid="1"/><tile gid="1"/><tile gid="1"/>
+   <tile gid="1"/><tile gid="1"/><tile gid="1"/><tile gid="1"/><tile gid="1"/>
+   <tile gid="1"/><tile gid="1"/><tile gid="1"/><tile gid="1"/><tile gid="1"/>
+  </data>
+ </layer>
+ <objectgroup id="2" name="rocks">
+  <object id="1" x="100" y="36" width="20" height="8">
+   <ellipse/>
+  </object>
+  <object id="2" x="0" y="72">
+   <polygon points="0,0 30,0 30,30 0,30"/>
+  </object>
+ </objectgroup>
+</map>
+`
+
+func TestReadTMX_Hex(t *testing.T) {
+	opt := Options{Open: func(source string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<tileset name="hex" tilewidth="28" tileheight="32">
+ <tile id="1"><properties><property name="blocked" type="bool" value="true"/></properties></tile>
+</tileset>`)), nil
+	}}
+	m, err := ReadTMX(strings.NewReader(fmt.Sprintf(hexTMX, "odd")), opt)
+	require.NoError(t, err)
+	assert.True(t, m.Hex)
+	// 行高 (32+16)/2 = 24：第 1 行中心 y=40，右移半格，(2,1) 中心 x=84
+	// 椭圆覆盖 (3,1) 中心 (112,40)；多边形覆盖 (0,3) 中心 (28,88)
+	assert.Equal(t, `. . . . .
+ . # . # .
+. . . . .
+ # . . . .
+`, grid.FormatText(m.Blocked, 5, 4, grid.TextHex, nil))
+
+	// 与 hex 求解器直接配合
+	ws := hex.NewWorkSpace(256)
+	ws.Reset(m.Blocked)
+	path, ok := ws.Solve(0, 1, 4, 1)
+	require.True(t, ok)
+	for _, p := range path {
+		assert.True(t, m.Blocked.Available(p.X, p.Y))
+	}
+
+	_, err = ReadTMX(strings.NewReader(fmt.Sprintf(hexTMX, "even")), opt)
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestRead_Errors(t *testing.T) {
+	for _, s := range []string{
+		`<map orientation="isometric" width="2" height="2" tilewidth="32" tileheight="16"></map>`,
+		`<map orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32" infinite="1"></map>`,
+		`<map orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32"><objectgroup name="a"/><group name="g"><objectgroup name="a"/></group></map>`,
+	} {
+		_, err := ReadTMX(strings.NewReader(s), Options{})
+		assert.ErrorIs(t, err, ErrUnsupported, s)
+	}
+	for _, s := range []string{
+		`<map`,
+		`<map orientation="orthogonal" width="0" height="2" tilewidth="32" tileheight="32"></map>`,
+		// 超大尺寸在分配前拒绝，不溢出也不占用大量内存
+		`<map orientation="orthogonal" width="2147483647" height="2" tilewidth="32" tileheight="32"></map>`,
+		`<map orientation="orthogonal" width="200000" height="200000" tilewidth="32" tileheight="32"></map>`,
+		`<map orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32"><layer name="a"><data encoding="csv">1,2,3</data></layer></map>`,
+	} {
+		_, err := ReadTMX(strings.NewReader(s), Options{})
+		assert.ErrorIs(t, err, grid.ErrFormat, s)
+	}
+}
+
+// 只扫描对象包围盒内的格子，结果与逐格检查整张地图一致
+func TestFillObjects_Bounds(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, d := range []*document{
+		{orientation: "orthogonal", width: 40, height: 30, tileW: 32, tileH: 24},
+		{orientation: "hexagonal", width: 40, height: 30, tileW: 28, tileH: 32, hexSide: 16},
+	} {
+		l := &layer{kind: objectLayer}
+		for i := 0; i < 200; i++ {
+			o := object{
+				x: r.Float64()*1400 - 100, y: r.Float64()*900 - 100,
+				w: r.Float64() * 200, h: r.Float64() * 200,
+			}
+			switch i % 4 {
+			case 1:
+				o.ellipse = true
+			case 2:
+				o.polygon = [][2]float64{{0, 0}, {o.w, -o.h / 2}, {o.w / 2, o.h}}
+			case 3:
+				o.gid = 1
+			}
+			if r.Intn(2) == 0 {
+				o.rotation = r.Float64()*720 - 360
+			}
+			l.objects = append(l.objects, o)
+		}
+		mask := d.newMask()
+		d.fillObjects(mask, l, "blocked")
+
+		want := d.newMask()
+		for i := range l.objects {
+			for y := int32(0); y < d.height; y++ {
+				for x := int32(0); x < d.width; x++ {
+					if cx, cy := d.center(x, y); l.objects[i].contains(cx, cy) {
+						want.Set(x, y)
+					}
+				}
+			}
+		}
+		assert.Equal(t, formatMask(want, d.width, d.height), formatMask(mask, d.width, d.height), d.orientation)
+	}
+}
@@ -0,0 +1,179 @@
+package tiled
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+)
+
+type jsonProperty struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type jsonTileset struct {
+	FirstGID uint32 `json:"firstgid"`
+	Source   string `json:"source"`
+	Tiles    []struct {
+		ID         uint32         `json:"id"`
+		Properties []jsonProperty `json:"properties"`
+	} `json:"tiles"`
+}
+
+type jsonObject struct {
+	X          float64                  `json:"x"`
+	Y          float64                  `json:"y"`
+	Width      float64                  `json:"width"`
+	Height     float64                  `json:"height"`
+	Rotation   float64                  `json:"rotation"`
+	GID        uint32                   `json:"gid"`
+	Ellipse    bool                     `json:"ellipse"`
+	Point      bool                     `json:"point"`
+	Polygon    []struct{ X, Y float64 } `json:"polygon"`
+	Polyline   json.RawMessage          `json:"polyline"`
+	Properties []jsonProperty           `json:"properties"`
+}
+
+type jsonLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Properties  []jsonProperty  `json:"properties"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []jsonObject    `json:"objects"`
+	Layers      []jsonLayer     `json:"layers"`
+}
+
+type jsonMap struct {
+	Orientation   string        `json:"orientation"`
+	StaggerAxis   string        `json:"staggeraxis"`
+	StaggerIndex  string        `json:"staggerindex"`
+	Infinite      bool          `json:"infinite"`
+	Width         int32         `json:"width"`
+	Height        int32         `json:"height"`
+	TileWidth     float64       `json:"tilewidth"`
+	TileHeight    float64       `json:"tileheight"`
+	HexSideLength float64       `json:"hexsidelength"`
+	Tilesets      []jsonTileset `json:"tilesets"`
+	Layers        []jsonLayer   `json:"layers"`
+}
+
+// ReadTMJ imports a map in the Tiled JSON format.
+func ReadTMJ(r io.Reader, opt Options) (*Map, error) {
+	var j jsonMap
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	d := &document{
+		orientation:  j.Orientation,
+		staggerAxis:  j.StaggerAxis,
+		staggerIndex: j.StaggerIndex,
+		infinite:     j.Infinite,
+		width:        j.Width,
+		height:       j.Height,
+		tileW:        j.TileWidth,
+		tileH:        j.TileHeight,
+		hexSide:      j.HexSideLength,
+	}
+	for _, jt := range j.Tilesets {
+		ts, err := loadTileset(jt.FirstGID, jt.Source, opt, func() tileset { return jt.tileset() })
+		if err != nil {
+			return nil, err
+		}
+		d.tilesets = append(d.tilesets, ts)
+	}
+	var err error
+	if d.layers, err = jsonLayers(j.Layers); err != nil {
+		return nil, err
+	}
+	return d.build(opt)
+}
+
+func readJSONTileset(r io.Reader) (tileset, error) {
+	var jt jsonTileset
+	if err := json.NewDecoder(r).Decode(&jt); err != nil {
+		return tileset{}, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	return jt.tileset(), nil
+}
+
+func (jt *jsonTileset) tileset() tileset {
+	ts := tileset{tiles: make(map[uint32]properties)}
+	for _, t := range jt.Tiles {
+		ts.tiles[t.ID] = jsonProps(t.Properties)
+	}
+	return ts
+}
+
+func jsonProps(ps []jsonProperty) properties {
+	if len(ps) == 0 {
+		return nil
+	}
+	p := make(properties, len(ps))
+	for _, x := range ps {
+		p[x.Name] = fmt.Sprint(x.Value)
+	}
+	return p
+}
+
+func jsonLayers(js []jsonLayer) ([]layer, error) {
+	var ls []layer
+	for _, j := range js {
+		l := layer{name: j.Name, props: jsonProps(j.Properties)}
+		switch j.Type {
+		case "tilelayer":
+			l.kind = tileLayer
+			var err error
+			if l.data, err = j.gids(); err != nil {
+				return nil, fmt.Errorf("layer %q: %w", j.Name, err)
+			}
+		case "objectgroup":
+			l.kind = objectLayer
+			for _, jo := range j.Objects {
+				if len(jo.Polyline) > 0 {
+					continue // lines enclose no cells
+				}
+				o := object{
+					x: jo.X, y: jo.Y, w: jo.Width, h: jo.Height,
+					rotation: jo.Rotation,
+					gid:      jo.GID & gidMask,
+					ellipse:  jo.Ellipse,
+					point:    jo.Point,
+					props:    jsonProps(jo.Properties),
+				}
+				for _, p := range jo.Polygon {
+					o.polygon = append(o.polygon, [2]float64{p.X, p.Y})
+				}
+				l.objects = append(l.objects, o)
+			}
+		case "group":
+			l.kind = groupLayer
+			var err error
+			if l.layers, err = jsonLayers(j.Layers); err != nil {
+				return nil, err
+			}
+		default:
+			continue // imagelayer
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+func (j *jsonLayer) gids() ([]uint32, error) {
+	if j.Encoding == "base64" {
+		var s string
+		if err := json.Unmarshal(j.Data, &s); err != nil {
+			return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+		}
+		return decodeBase64(s, j.Compression)
+	}
+	var gids []uint32
+	if err := json.Unmarshal(j.Data, &gids); err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	return gids, nil
+}
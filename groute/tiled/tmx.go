@@ -0,0 +1,291 @@
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/legamerdc/pathfinding/groute/grid"
+)
+
+type xmlProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+type xmlTileset struct {
+	FirstGID uint32 `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+	Tiles    []struct {
+		ID         uint32        `xml:"id,attr"`
+		Properties []xmlProperty `xml:"properties>property"`
+	} `xml:"tile"`
+}
+
+type xmlData struct {
+	Encoding    string `xml:"encoding,attr"`
+	Compression string `xml:"compression,attr"`
+	Text        string `xml:",chardata"`
+	Tiles       []struct {
+		GID uint32 `xml:"gid,attr"`
+	} `xml:"tile"`
+}
+
+type xmlObject struct {
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Rotation   float64       `xml:"rotation,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	Properties []xmlProperty `xml:"properties>property"`
+	Ellipse    *struct{}     `xml:"ellipse"`
+	Point      *struct{}     `xml:"point"`
+	Polygon    *struct {
+		Points string `xml:"points,attr"`
+	} `xml:"polygon"`
+	Polyline *struct{} `xml:"polyline"`
+}
+
+// xmlLayer decodes layer, objectgroup and group elements in document order.
+type xmlLayer struct {
+	XMLName    xml.Name
+	Name       string        `xml:"name,attr"`
+	Properties []xmlProperty `xml:"properties>property"`
+	Data       xmlData       `xml:"data"`
+	Objects    []xmlObject   `xml:"object"`
+	Layers     []xmlLayer    `xml:",any"`
+}
+
+type xmlMap struct {
+	Orientation   string       `xml:"orientation,attr"`
+	StaggerAxis   string       `xml:"staggeraxis,attr"`
+	StaggerIndex  string       `xml:"staggerindex,attr"`
+	Infinite      int          `xml:"infinite,attr"`
+	Width         int32        `xml:"width,attr"`
+	Height        int32        `xml:"height,attr"`
+	TileWidth     float64      `xml:"tilewidth,attr"`
+	TileHeight    float64      `xml:"tileheight,attr"`
+	HexSideLength float64      `xml:"hexsidelength,attr"`
+	Tilesets      []xmlTileset `xml:"tileset"`
+	Layers        []xmlLayer   `xml:",any"`
+}
+
+// ReadTMX imports a map in the Tiled XML format.
+func ReadTMX(r io.Reader, opt Options) (*Map, error) {
+	var x xmlMap
+	if err := xml.NewDecoder(r).Decode(&x); err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	d := &document{
+		orientation:  x.Orientation,
+		staggerAxis:  x.StaggerAxis,
+		staggerIndex: x.StaggerIndex,
+		infinite:     x.Infinite != 0,
+		width:        x.Width,
+		height:       x.Height,
+		tileW:        x.TileWidth,
+		tileH:        x.TileHeight,
+		hexSide:      x.HexSideLength,
+	}
+	for _, xt := range x.Tilesets {
+		ts, err := loadTileset(xt.FirstGID, xt.Source, opt, func() tileset { return xt.tileset() })
+		if err != nil {
+			return nil, err
+		}
+		d.tilesets = append(d.tilesets, ts)
+	}
+	var err error
+	if d.layers, err = xmlLayers(x.Layers); err != nil {
+		return nil, err
+	}
+	return d.build(opt)
+}
+
+// loadTileset resolves an external tileset, or returns the embedded one.
+func loadTileset(firstGID uint32, source string, opt Options, embedded func() tileset) (tileset, error) {
+	if source == "" {
+		ts := embedded()
+		ts.firstGID = firstGID
+		return ts, nil
+	}
+	if opt.Open == nil {
+		return tileset{}, fmt.Errorf("%w: external tileset %q without Options.Open", ErrUnsupported, source)
+	}
+	f, err := opt.Open(source)
+	if err != nil {
+		return tileset{}, err
+	}
+	defer f.Close()
+
+	var ts tileset
+	if strings.HasSuffix(strings.ToLower(source), ".tsx") {
+		var xt xmlTileset
+		if err = xml.NewDecoder(f).Decode(&xt); err != nil {
+			return tileset{}, fmt.Errorf("%w: %s: %v", grid.ErrFormat, source, err)
+		}
+		ts = xt.tileset()
+	} else {
+		if ts, err = readJSONTileset(f); err != nil {
+			return tileset{}, fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	ts.firstGID, ts.source = firstGID, source
+	return ts, nil
+}
+
+func (xt *xmlTileset) tileset() tileset {
+	ts := tileset{tiles: make(map[uint32]properties)}
+	for _, t := range xt.Tiles {
+		ts.tiles[t.ID] = xmlProps(t.Properties)
+	}
+	return ts
+}
+
+func xmlProps(ps []xmlProperty) properties {
+	if len(ps) == 0 {
+		return nil
+	}
+	p := make(properties, len(ps))
+	for _, x := range ps {
+		v := x.Value
+		if v == "" {
+			v = strings.TrimSpace(x.Text)
+		}
+		p[x.Name] = v
+	}
+	return p
+}
+
+func xmlLayers(xs []xmlLayer) ([]layer, error) {
+	var ls []layer
+	for _, x := range xs {
+		l := layer{name: x.Name, props: xmlProps(x.Properties)}
+		switch x.XMLName.Local {
+		case "layer":
+			l.kind = tileLayer
+			var err error
+			if l.data, err = x.Data.gids(); err != nil {
+				return nil, fmt.Errorf("layer %q: %w", x.Name, err)
+			}
+		case "objectgroup":
+			l.kind = objectLayer
+			for _, xo := range x.Objects {
+				if xo.Polyline != nil {
+					continue // lines enclose no cells
+				}
+				o := object{
+					x: xo.X, y: xo.Y, w: xo.Width, h: xo.Height,
+					rotation: xo.Rotation,
+					gid:      xo.GID & gidMask,
+					ellipse:  xo.Ellipse != nil,
+					point:    xo.Point != nil,
+					props:    xmlProps(xo.Properties),
+				}
+				if xo.Polygon != nil {
+					var err error
+					if o.polygon, err = parsePoints(xo.Polygon.Points); err != nil {
+						return nil, err
+					}
+				}
+				l.objects = append(l.objects, o)
+			}
+		case "group":
+			l.kind = groupLayer
+			var err error
+			if l.layers, err = xmlLayers(x.Layers); err != nil {
+				return nil, err
+			}
+		default:
+			continue // tileset, properties, imagelayer, ...
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+func (x *xmlData) gids() ([]uint32, error) {
+	switch x.Encoding {
+	case "":
+		gids := make([]uint32, len(x.Tiles))
+		for i, t := range x.Tiles {
+			gids[i] = t.GID
+		}
+		return gids, nil
+	case "csv":
+		var gids []uint32
+		for _, f := range strings.Split(x.Text, ",") {
+			if f = strings.TrimSpace(f); f == "" {
+				continue
+			}
+			v, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+			}
+			gids = append(gids, uint32(v))
+		}
+		return gids, nil
+	case "base64":
+		return decodeBase64(strings.TrimSpace(x.Text), x.Compression)
+	}
+	return nil, fmt.Errorf("%w: %q encoding", ErrUnsupported, x.Encoding)
+}
+
+// decodeBase64 decodes base64 tile data, optionally compressed, into gids.
+func decodeBase64(s, compression string) ([]uint32, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	var r io.Reader = bytes.NewReader(raw)
+	switch compression {
+	case "":
+	case "zlib":
+		if r, err = zlib.NewReader(r); err != nil {
+			return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+		}
+	case "gzip":
+		if r, err = gzip.NewReader(r); err != nil {
+			return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q compression", ErrUnsupported, compression)
+	}
+	if raw, err = io.ReadAll(r); err != nil {
+		return nil, fmt.Errorf("%w: %v", grid.ErrFormat, err)
+	}
+	if len(raw)%4 != 0 {
+		return nil, fmt.Errorf("%w: truncated tile data", grid.ErrFormat)
+	}
+	gids := make([]uint32, len(raw)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(raw[i*4:])
+	}
+	return gids, nil
+}
+
+// parsePoints parses the "x1,y1 x2,y2 ..." points attribute.
+func parsePoints(s string) ([][2]float64, error) {
+	var pts [][2]float64
+	for _, f := range strings.Fields(s) {
+		xs, ys, ok := strings.Cut(f, ",")
+		if !ok {
+			return nil, fmt.Errorf("%w: bad point %q", grid.ErrFormat, f)
+		}
+		x, err1 := strconv.ParseFloat(xs, 64)
+		y, err2 := strconv.ParseFloat(ys, 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%w: bad point %q", grid.ErrFormat, f)
+		}
+		pts = append(pts, [2]float64{x, y})
+	}
+	return pts, nil
+}
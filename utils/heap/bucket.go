@@ -0,0 +1,181 @@
+package heap
+
+// KeyNode is a Node with a non-negative integer priority. Compare must order
+// nodes consistently with HeapKey.
+type KeyNode[T any] interface {
+	Node[T]
+	HeapKey() int32
+}
+
+// Queue is the open list contract shared by Heap and BucketQueue.
+//
+// Both track membership through the heap index. A node that is not queued
+// must have an index of zero or below: the zero value of a node qualifies,
+// and Pop and Remove leave -1 behind. Contains and Remove are then safe on
+// any node.
+type Queue[T any] interface {
+	Len() int
+	Push(x T)
+	Pop() T
+	Top() T
+	Fix(x T)
+	Remove(x T)
+	Contains(x T) bool
+	Empty() bool
+	Clear()
+}
+
+// BucketQueue is a monotone bucket queue (Dial's queue) for integer keys.
+//
+// Every key owns a bucket, so Push, Fix and Remove are O(1) and Pop is
+// amortised O(1) as long as popped keys are non-decreasing, which holds for
+// A* with a consistent heuristic. Keys below the current minimum are still
+// accepted at the cost of moving the bucket window.
+//
+// The heap index of a queued node holds its current key plus one, so that it
+// stays positive; Fix on a node leaves a stale entry behind that is skipped
+// once reached. Nodes with equal
+// keys pop in LIFO order.
+type BucketQueue[T KeyNode[T]] struct {
+	buckets [][]T
+	base    int32 // key of buckets[0]
+	cur     int   // no live entry below buckets[cur]
+	n       int
+}
+
+// NewBucketQueue creates an empty bucket queue.
+func NewBucketQueue[T KeyNode[T]](size int) *BucketQueue[T] {
+	return &BucketQueue[T]{
+		buckets: make([][]T, 0, max(size/8, 16)),
+	}
+}
+
+// Len returns the number of queued nodes.
+func (q *BucketQueue[T]) Len() int {
+	return q.n
+}
+
+// Push inserts x with key x.HeapKey().
+func (q *BucketQueue[T]) Push(x T) {
+	if q.n == 0 {
+		q.Clear()
+	}
+	q.insert(x, x.HeapKey())
+	q.n++
+}
+
+// Pop removes and returns the node with the lowest key.
+func (q *BucketQueue[T]) Pop() T {
+	var zero T
+	if !q.skip() {
+		return zero
+	}
+	b := q.buckets[q.cur]
+	x := b[len(b)-1]
+	b[len(b)-1] = zero
+	q.buckets[q.cur] = b[:len(b)-1]
+	x.SetHeapIndex(-1)
+	q.n--
+	return x
+}
+
+// Top returns the node with the lowest key without removing it.
+func (q *BucketQueue[T]) Top() T {
+	var zero T
+	if !q.skip() {
+		return zero
+	}
+	b := q.buckets[q.cur]
+	return b[len(b)-1]
+}
+
+// Fix moves x to the bucket of its updated key.
+func (q *BucketQueue[T]) Fix(x T) {
+	if k := x.HeapKey(); k+1 != x.GetHeapIndex() {
+		q.insert(x, k)
+	}
+}
+
+// Remove deletes x from the queue. It does nothing if x is not queued.
+func (q *BucketQueue[T]) Remove(x T) {
+	if !q.Contains(x) {
+		return
+	}
+	x.SetHeapIndex(-1)
+	q.n--
+}
+
+// Contains reports whether x is queued, see Queue for the index rule.
+func (q *BucketQueue[T]) Contains(x T) bool {
+	return x.GetHeapIndex() > 0
+}
+
+// Empty reports whether the queue holds no nodes.
+func (q *BucketQueue[T]) Empty() bool {
+	return q.n == 0
+}
+
+// Clear removes every node while keeping bucket storage for reuse.
+func (q *BucketQueue[T]) Clear() {
+	var zero T
+	for i, b := range q.buckets {
+		for j := range b {
+			b[j] = zero
+		}
+		q.buckets[i] = b[:0]
+	}
+	q.buckets = q.buckets[:0]
+	q.cur, q.n = 0, 0
+}
+
+func (q *BucketQueue[T]) insert(x T, k int32) {
+	if len(q.buckets) == 0 {
+		q.base = k
+	}
+	if k < q.base {
+		shift := int(q.base - k)
+		q.buckets = append(q.buckets, make([][]T, shift)...)
+		copy(q.buckets[shift:], q.buckets)
+		for i := 0; i < shift; i++ {
+			q.buckets[i] = nil
+		}
+		q.base = k
+		q.cur += shift
+	}
+	i := int(k - q.base)
+	for len(q.buckets) <= i {
+		if len(q.buckets) < cap(q.buckets) {
+			q.buckets = q.buckets[:len(q.buckets)+1]
+		} else {
+			q.buckets = append(q.buckets, nil)
+		}
+	}
+	q.buckets[i] = append(q.buckets[i], x)
+	x.SetHeapIndex(k + 1)
+	if i < q.cur {
+		q.cur = i
+	}
+}
+
+// skip advances cur to the first bucket whose last entry is live and reports
+// whether such a bucket exists.
+func (q *BucketQueue[T]) skip() bool {
+	var zero T
+	if q.n == 0 {
+		return false
+	}
+	for ; q.cur < len(q.buckets); q.cur++ {
+		b := q.buckets[q.cur]
+		key := q.base + int32(q.cur)
+		for len(b) > 0 {
+			if b[len(b)-1].GetHeapIndex() == key+1 {
+				q.buckets[q.cur] = b
+				return true
+			}
+			b[len(b)-1] = zero
+			b = b[:len(b)-1]
+		}
+		q.buckets[q.cur] = b
+	}
+	return false
+}
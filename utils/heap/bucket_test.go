@@ -0,0 +1,289 @@
+package heap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func (n *testNode) HeapKey() int32 { return int32(n.val) }
+
+var (
+	_ Queue[*testNode] = (*Heap[*testNode])(nil)
+	_ Queue[*testNode] = (*BucketQueue[*testNode])(nil)
+)
+
+func TestBucketQueue_PushPopSorted(t *testing.T) {
+	q := NewBucketQueue[*testNode](0)
+	const N = 256
+	vals := make([]int, 0, N)
+	for i := 0; i < N; i++ {
+		v := rand.Intn(1000)
+		vals = append(vals, v)
+		q.Push(&testNode{val: v, idx: -1})
+	}
+	sort.Ints(vals)
+	for i := 0; i < N; i++ {
+		if top := q.Top(); top.val != vals[i] {
+			t.Fatalf("expected top %d, got %d at %d", vals[i], top.val, i)
+		}
+		n := q.Pop()
+		if n.GetHeapIndex() != -1 {
+			t.Fatalf("popped node index not -1: %d", n.GetHeapIndex())
+		}
+		if n.val != vals[i] {
+			t.Fatalf("expected %d, got %d at %d", vals[i], n.val, i)
+		}
+	}
+	if !q.Empty() || q.Top() != nil {
+		t.Fatalf("queue should be empty")
+	}
+}
+
+func TestBucketQueue_FixRemove(t *testing.T) {
+	q := NewBucketQueue[*testNode](0)
+	a := &testNode{val: 50, idx: -1}
+	b := &testNode{val: 40, idx: -1}
+	c := &testNode{val: 60, idx: -1}
+	d := &testNode{val: 45, idx: -1}
+	q.Push(a)
+	q.Push(b)
+	q.Push(c)
+	q.Push(d)
+
+	c.val = 10 // below the current minimum
+	q.Fix(c)
+	a.val = 70 // increase-key leaves a stale entry behind
+	q.Fix(a)
+	q.Remove(d)
+	q.Remove(d) // already removed
+	if q.Contains(d) || !q.Contains(a) {
+		t.Fatalf("contains mismatch")
+	}
+	if q.Len() != 3 {
+		t.Fatalf("expected len 3, got %d", q.Len())
+	}
+
+	for _, want := range []*testNode{c, b, a} {
+		if got := q.Pop(); got != want {
+			t.Fatalf("expected %+v, got %+v", want, got)
+		}
+	}
+	if !q.Empty() || q.Pop() != nil {
+		t.Fatalf("queue should be empty")
+	}
+}
+
+func TestBucketQueue_RandomOps(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	q := NewBucketQueue[*testNode](0)
+	var live []*testNode
+	for step := 0; step < 5000; step++ {
+		switch op := rng.Intn(5); {
+		case op < 2 || len(live) == 0:
+			n := &testNode{val: rng.Intn(500), idx: -1}
+			q.Push(n)
+			live = append(live, n)
+		case op == 2:
+			n := live[rng.Intn(len(live))]
+			n.val = max(0, n.val-rng.Intn(50))
+			q.Fix(n)
+		case op == 3:
+			k := rng.Intn(len(live))
+			q.Remove(live[k])
+			live[k] = live[len(live)-1]
+			live = live[:len(live)-1]
+		default:
+			x := q.Pop()
+			k := -1
+			for i, n := range live {
+				if n.val < x.val {
+					t.Fatalf("step %d: popped %d while %d is queued", step, x.val, n.val)
+				}
+				if n == x {
+					k = i
+				}
+			}
+			if k < 0 {
+				t.Fatalf("step %d: popped node %+v not queued", step, x)
+			}
+			live[k] = live[len(live)-1]
+			live = live[:len(live)-1]
+		}
+		if q.Len() != len(live) {
+			t.Fatalf("step %d: len %d != %d", step, q.Len(), len(live))
+		}
+	}
+}
+
+// ---------------- Benchmarks: bucket queue vs heap ----------------
+
+// monotoneKeys mimics an A* open list: keys stay within a small window
+// above the last popped key.
+func monotoneKeys(n int) []int {
+	rng := rand.New(rand.NewSource(1))
+	vals := make([]int, n)
+	for i := range vals {
+		vals[i] = i/4 + rng.Intn(64)
+	}
+	return vals
+}
+
+func BenchmarkOurHeap_PushPopMonotone_4096(b *testing.B) {
+	b.ReportAllocs()
+	const N = 4096
+	vals := monotoneKeys(N)
+	h := NewHeap[*testNode](N)
+	nodes := make([]testNode, N)
+	b.ResetTimer()
+	for it := 0; it < b.N; it++ {
+		for i := 0; i < N; i++ {
+			nodes[i] = testNode{val: vals[i], idx: -1}
+			h.Push(&nodes[i])
+			if i%2 == 1 {
+				_ = h.Pop()
+			}
+		}
+		for !h.Empty() {
+			_ = h.Pop()
+		}
+	}
+}
+
+func BenchmarkOurHeap4_PushPopMonotone_4096(b *testing.B) {
+	b.ReportAllocs()
+	const N = 4096
+	vals := monotoneKeys(N)
+	h := NewDHeap[*testNode](4, N)
+	nodes := make([]testNode, N)
+	b.ResetTimer()
+	for it := 0; it < b.N; it++ {
+		for i := 0; i < N; i++ {
+			nodes[i] = testNode{val: vals[i], idx: -1}
+			h.Push(&nodes[i])
+			if i%2 == 1 {
+				_ = h.Pop()
+			}
+		}
+		for !h.Empty() {
+			_ = h.Pop()
+		}
+	}
+}
+
+func BenchmarkBucketQueue_PushPopMonotone_4096(b *testing.B) {
+	b.ReportAllocs()
+	const N = 4096
+	vals := monotoneKeys(N)
+	q := NewBucketQueue[*testNode](N)
+	nodes := make([]testNode, N)
+	b.ResetTimer()
+	for it := 0; it < b.N; it++ {
+		for i := 0; i < N; i++ {
+			nodes[i] = testNode{val: vals[i], idx: -1}
+			q.Push(&nodes[i])
+			if i%2 == 1 {
+				_ = q.Pop()
+			}
+		}
+		for !q.Empty() {
+			_ = q.Pop()
+		}
+	}
+}
+
+func BenchmarkOurHeap_DecreaseKey(b *testing.B) {
+	b.ReportAllocs()
+	const (
+		N = 4096
+		K = 4096
+	)
+	rng := rand.New(rand.NewSource(2))
+	idxs := make([]int, K)
+	for i := 0; i < K; i++ {
+		idxs[i] = rng.Intn(N)
+	}
+	for it := 0; it < b.N; it++ {
+		b.StopTimer()
+		h := NewHeap[*testNode](N)
+		nodes := make([]*testNode, N)
+		for i := 0; i < N; i++ {
+			nodes[i] = &testNode{val: 1000 + rng.Intn(1000), idx: -1}
+			h.Push(nodes[i])
+		}
+		b.StartTimer()
+		for i := 0; i < K; i++ {
+			n := nodes[idxs[i]]
+			n.val = max(n.val-rng.Intn(8), 0)
+			h.Fix(n)
+		}
+	}
+}
+
+func BenchmarkBucketQueue_DecreaseKey(b *testing.B) {
+	b.ReportAllocs()
+	const (
+		N = 4096
+		K = 4096
+	)
+	rng := rand.New(rand.NewSource(2))
+	idxs := make([]int, K)
+	for i := 0; i < K; i++ {
+		idxs[i] = rng.Intn(N)
+	}
+	for it := 0; it < b.N; it++ {
+		b.StopTimer()
+		q := NewBucketQueue[*testNode](N)
+		nodes := make([]*testNode, N)
+		for i := 0; i < N; i++ {
+			nodes[i] = &testNode{val: 1000 + rng.Intn(1000), idx: -1}
+			q.Push(nodes[i])
+		}
+		b.StartTimer()
+		for i := 0; i < K; i++ {
+			n := nodes[idxs[i]]
+			n.val = max(n.val-rng.Intn(8), 0)
+			q.Fix(n)
+		}
+	}
+}
+
+// 全新的零值节点（索引为 0）在两种实现中都不算在队列里
+func TestQueue_ZeroValueNodes(t *testing.T) {
+	for name, q := range map[string]Queue[*testNode]{
+		"heap":   NewHeap[*testNode](4),
+		"bucket": NewBucketQueue[*testNode](4),
+	} {
+		nodes := []*testNode{{val: 0}, {val: 3}, {val: 1}, {val: 2}}
+		for _, n := range nodes {
+			if q.Contains(n) {
+				t.Fatalf("%s: fresh node %d contained", name, n.val)
+			}
+			q.Remove(n)
+		}
+		if q.Len() != 0 {
+			t.Fatalf("%s: removing fresh nodes changed the length to %d", name, q.Len())
+		}
+		for _, n := range nodes {
+			q.Push(n)
+		}
+		for _, n := range nodes {
+			if !q.Contains(n) {
+				t.Fatalf("%s: pushed node %d not contained", name, n.val)
+			}
+		}
+		q.Remove(nodes[1])
+		if q.Contains(nodes[1]) || q.Len() != 3 {
+			t.Fatalf("%s: removal failed", name)
+		}
+		for want := 0; want < 3; want++ {
+			n := q.Pop()
+			if n.val != want || q.Contains(n) {
+				t.Fatalf("%s: popped %d, want %d", name, n.val, want)
+			}
+		}
+		if !q.Empty() {
+			t.Fatalf("%s: queue not empty", name)
+		}
+	}
+}
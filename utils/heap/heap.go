@@ -6,8 +6,10 @@ type Node[T any] interface {
 	SetHeapIndex(int32)
 }
 
+// Heap is an indexed d-ary min-heap. The zero value is an empty binary heap.
 type Heap[T Node[T]] struct {
 	nodes []T
+	d     int // arity, 0 means 2
 }
 
 func NewHeap[T Node[T]](size int) *Heap[T] {
@@ -16,25 +18,33 @@ func NewHeap[T Node[T]](size int) *Heap[T] {
 	}
 }
 
+// NewDHeap creates a heap where every node has d children. A 4-ary heap
+// does fewer levels of sift-down per Pop than a binary one, which usually
+// pays off for search open lists with many decrease-key operations.
+func NewDHeap[T Node[T]](d, size int) *Heap[T] {
+	if d < 2 {
+		d = 2
+	}
+	return &Heap[T]{
+		nodes: make([]T, 0, size+1),
+		d:     d,
+	}
+}
+
+func (q *Heap[T]) arity() int {
+	if q.d == 0 {
+		return 2
+	}
+	return q.d
+}
+
 func (q *Heap[T]) Len() int {
 	return len(q.nodes)
 }
 
 func (q *Heap[T]) Push(x T) {
 	q.nodes = append(q.nodes, x)
-	i := len(q.nodes) - 1
-	for i > 0 {
-		p := (i - 1) / 2
-		y := q.nodes[p]
-		if x.Compare(y) >= 0 {
-			break
-		}
-		q.nodes[i] = y
-		y.SetHeapIndex(int32(i))
-		i = p
-	}
-	q.nodes[i] = x
-	x.SetHeapIndex(int32(i))
+	q.up(x, len(q.nodes)-1)
 }
 
 func (q *Heap[T]) Pop() T {
@@ -45,31 +55,7 @@ func (q *Heap[T]) Pop() T {
 	q.nodes[n-1] = zero
 	q.nodes = q.nodes[:n-1]
 	if n-1 > 0 {
-		i := 0
-		for {
-			l := 2*i + 1
-			if l >= len(q.nodes) {
-				break
-			}
-			r := l + 1
-			m := l
-			y := q.nodes[l]
-			if r < len(q.nodes) {
-				z := q.nodes[r]
-				if z.Compare(y) < 0 {
-					m = r
-					y = z
-				}
-			}
-			if x.Compare(y) <= 0 {
-				break
-			}
-			q.nodes[i] = y
-			y.SetHeapIndex(int32(i))
-			i = m
-		}
-		q.nodes[i] = x
-		x.SetHeapIndex(int32(i))
+		q.down(x, 0)
 	}
 	ret.SetHeapIndex(-1)
 	return ret
@@ -84,38 +70,111 @@ func (q *Heap[T]) Top() T {
 }
 
 func (q *Heap[T]) Fix(x T) {
+	i := int(x.GetHeapIndex())
+	if i > 0 && x.Compare(q.nodes[(i-1)/q.arity()]) < 0 {
+		q.up(x, i)
+		return
+	}
+	q.down(x, i)
+}
+
+// Remove deletes x, located by its heap index, from the heap. It does
+// nothing if x is not in the heap.
+func (q *Heap[T]) Remove(x T) {
+	if !q.Contains(x) {
+		return
+	}
+	var zero T
+	i := int(x.GetHeapIndex())
+	n := len(q.nodes) - 1
+	y := q.nodes[n]
+	q.nodes[n] = zero
+	q.nodes = q.nodes[:n]
+	x.SetHeapIndex(-1)
+	if i == n {
+		return
+	}
+	q.nodes[i] = y
+	y.SetHeapIndex(int32(i))
+	q.Fix(y)
+}
+
+// Contains reports whether x is currently stored in the heap, see Queue for
+// the index rule. The index is checked against the stored node, so a stale
+// positive index is tolerated as well.
+func (q *Heap[T]) Contains(x T) bool {
+	i := int(x.GetHeapIndex())
+	return i >= 0 && i < len(q.nodes) && any(q.nodes[i]) == any(x)
+}
+
+// Init replaces the heap content with nodes and restores the heap order in
+// O(n). The heap takes ownership of the slice.
+func (q *Heap[T]) Init(nodes []T) {
+	q.nodes = nodes
+	for i, x := range q.nodes {
+		x.SetHeapIndex(int32(i))
+	}
+	d := q.arity()
+	for i := (len(q.nodes) - 2) / d; i >= 0; i-- {
+		q.down(q.nodes[i], i)
+	}
+}
+
+// Iter calls f for every node in unspecified order until f returns false.
+// The heap must not be modified during iteration.
+func (q *Heap[T]) Iter(f func(x T) bool) {
+	for _, x := range q.nodes {
+		if !f(x) {
+			return
+		}
+	}
+}
+
+func (q *Heap[T]) Empty() bool {
+	return len(q.nodes) == 0
+}
+
+func (q *Heap[T]) Clear() {
+	var zero T
+	for i := range q.nodes {
+		q.nodes[i] = zero
+	}
+	q.nodes = q.nodes[:0]
+}
+
+// up moves x, logically stored at i, towards the root.
+func (q *Heap[T]) up(x T, i int) {
+	d := q.arity()
+	for i > 0 {
+		p := (i - 1) / d
+		y := q.nodes[p]
+		if x.Compare(y) >= 0 {
+			break
+		}
+		q.nodes[i] = y
+		y.SetHeapIndex(int32(i))
+		i = p
+	}
+	q.nodes[i] = x
+	x.SetHeapIndex(int32(i))
+}
+
+// down moves x, logically stored at i, towards the leaves.
+func (q *Heap[T]) down(x T, i int) {
 	var (
-		i = int(x.GetHeapIndex())
+		d = q.arity()
 		n = len(q.nodes)
-		p int
 	)
-	if i > 0 && x.Compare(q.nodes[(i-1)/2]) < 0 {
-		for i > 0 {
-			p = (i - 1) / 2
-			y := q.nodes[p]
-			if x.Compare(y) >= 0 {
-				break
-			}
-			q.nodes[i] = y
-			y.SetHeapIndex(int32(i))
-			i = p
-		}
-		q.nodes[i] = x
-		x.SetHeapIndex(int32(i))
-		return
-	}
 	for {
-		l := 2*i + 1
+		l := d*i + 1
 		if l >= n {
 			break
 		}
-		r := l + 1
 		m := l
 		y := q.nodes[l]
-		if r < n {
-			z := q.nodes[r]
-			if z.Compare(y) < 0 {
-				m = r
+		for c := l + 1; c < l+d && c < n; c++ {
+			if z := q.nodes[c]; z.Compare(y) < 0 {
+				m = c
 				y = z
 			}
 		}
@@ -129,15 +188,3 @@ func (q *Heap[T]) Fix(x T) {
 	q.nodes[i] = x
 	x.SetHeapIndex(int32(i))
 }
-
-func (q *Heap[T]) Empty() bool {
-	return len(q.nodes) == 0
-}
-
-func (q *Heap[T]) Clear() {
-	var zero T
-	for i := range q.nodes {
-		q.nodes[i] = zero
-	}
-	q.nodes = q.nodes[:0]
-}
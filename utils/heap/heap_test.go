@@ -151,6 +151,135 @@ func TestIndexesConsistent(t *testing.T) {
 	}
 }
 
+func checkIndexes(t *testing.T, h *Heap[*testNode]) {
+	t.Helper()
+	d := h.arity()
+	for i := range h.nodes {
+		if int32(i) != h.nodes[i].GetHeapIndex() {
+			t.Fatalf("node index mismatch at %d: got %d", i, h.nodes[i].GetHeapIndex())
+		}
+		if i > 0 && h.nodes[i].val < h.nodes[(i-1)/d].val {
+			t.Fatalf("heap order violated at %d", i)
+		}
+	}
+}
+
+func TestDHeapPushPopSorted(t *testing.T) {
+	for _, d := range []int{2, 3, 4, 8} {
+		h := NewDHeap[*testNode](d, 0)
+		const N = 257
+		vals := make([]int, 0, N)
+		for i := 0; i < N; i++ {
+			v := rand.Intn(10000)
+			vals = append(vals, v)
+			h.Push(&testNode{val: v, idx: -1})
+		}
+		checkIndexes(t, h)
+		sort.Ints(vals)
+		for i := 0; i < N; i++ {
+			if n := h.Pop(); n.val != vals[i] {
+				t.Fatalf("d=%d: expected %d, got %d at %d", d, vals[i], n.val, i)
+			}
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	for _, d := range []int{2, 4} {
+		h := NewDHeap[*testNode](d, 0)
+		ns := make([]*testNode, 0, 64)
+		for i := 0; i < 64; i++ {
+			n := &testNode{val: rand.Intn(1000), idx: -1}
+			ns = append(ns, n)
+			h.Push(n)
+		}
+		removed := make(map[*testNode]bool)
+		for _, k := range rand.Perm(len(ns))[:32] {
+			h.Remove(ns[k])
+			removed[ns[k]] = true
+			if ns[k].GetHeapIndex() != -1 {
+				t.Fatalf("removed node index not -1")
+			}
+			checkIndexes(t, h)
+		}
+		for _, n := range ns {
+			if h.Contains(n) == removed[n] {
+				t.Fatalf("contains mismatch for %+v", n)
+			}
+		}
+		prev := -1
+		for !h.Empty() {
+			n := h.Pop()
+			if removed[n] || n.val < prev {
+				t.Fatalf("unexpected pop %+v", n)
+			}
+			prev = n.val
+		}
+	}
+}
+
+func TestRemoveAbsent(t *testing.T) {
+	var h Heap[*testNode]
+	a := &testNode{val: 1, idx: -1}
+	h.Remove(a) // empty heap
+	b := &testNode{val: 2, idx: -1}
+	h.Push(b)
+	h.Remove(a)                         // not in the heap
+	h.Remove(&testNode{val: 3, idx: 0}) // stale index pointing at b
+	if h.Len() != 1 || !h.Contains(b) {
+		t.Fatalf("absent removal changed the heap")
+	}
+	h.Remove(b)
+	h.Remove(b)
+	if !h.Empty() {
+		t.Fatalf("heap not empty")
+	}
+}
+
+func TestContains(t *testing.T) {
+	var h Heap[*testNode]
+	a := &testNode{val: 1, idx: -1}
+	b := &testNode{val: 2, idx: 0} // stale index pointing at a
+	h.Push(a)
+	if !h.Contains(a) || h.Contains(b) {
+		t.Fatalf("contains mismatch")
+	}
+	h.Pop()
+	if h.Contains(a) {
+		t.Fatalf("popped node still contained")
+	}
+}
+
+func TestInit(t *testing.T) {
+	for _, d := range []int{2, 4} {
+		h := NewDHeap[*testNode](d, 0)
+		ns := make([]*testNode, 100)
+		vals := make([]int, 100)
+		for i := range ns {
+			vals[i] = rand.Intn(1000)
+			ns[i] = &testNode{val: vals[i], idx: -1}
+		}
+		h.Init(ns)
+		checkIndexes(t, h)
+
+		seen := 0
+		h.Iter(func(*testNode) bool {
+			seen++
+			return true
+		})
+		if seen != len(vals) {
+			t.Fatalf("iter visited %d of %d", seen, len(vals))
+		}
+
+		sort.Ints(vals)
+		for i := range vals {
+			if n := h.Pop(); n.val != vals[i] {
+				t.Fatalf("expected %d, got %d at %d", vals[i], n.val, i)
+			}
+		}
+	}
+}
+
 // ---------------- Benchmarks: our heap vs container/heap ----------------
 
 type cNode struct {
@@ -280,3 +409,53 @@ func BenchmarkStdHeap_Fix(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkOurHeap4_PushPop_4096(b *testing.B) {
+	b.ReportAllocs()
+	const N = 4096
+	rng := rand.New(rand.NewSource(1))
+	vals := make([]int, N)
+	for i := 0; i < N; i++ {
+		vals[i] = rng.Int()
+	}
+	b.ResetTimer()
+	for it := 0; it < b.N; it++ {
+		h := NewDHeap[*testNode](4, N)
+		for i := 0; i < N; i++ {
+			h.Push(&testNode{val: vals[i], idx: -1})
+		}
+		for i := 0; i < N; i++ {
+			_ = h.Pop()
+		}
+	}
+}
+
+func BenchmarkOurHeap4_Fix(b *testing.B) {
+	b.ReportAllocs()
+	const (
+		N = 4096
+		K = 4096
+	)
+	rng := rand.New(rand.NewSource(2))
+	idxs := make([]int, K)
+	deltas := make([]int, K)
+	for i := 0; i < K; i++ {
+		idxs[i] = rng.Intn(N)
+		deltas[i] = rng.Intn(201) - 100
+	}
+	for it := 0; it < b.N; it++ {
+		b.StopTimer()
+		h := NewDHeap[*testNode](4, N)
+		nodes := make([]*testNode, N)
+		for i := 0; i < N; i++ {
+			nodes[i] = &testNode{val: rng.Intn(10000), idx: -1}
+			h.Push(nodes[i])
+		}
+		b.StartTimer()
+		for i := 0; i < K; i++ {
+			n := nodes[idxs[i]]
+			n.val += deltas[i]
+			h.Fix(n)
+		}
+	}
+}